@@ -0,0 +1,100 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// apiscan renders the chart with every example values file for every supported Kubernetes
+// version and fails when a rendered object uses an API the cluster no longer serves.
+//
+// The supported versions are read from ci-matrix.json, which update_k8s_versions keeps up to
+// date. Each render sees the Kubernetes version and the API versions of that cluster in
+// .Capabilities, so capability-gated templates are checked in the branch the cluster takes.
+// Objects using deprecated APIs that are still served are reported without failing.
+//
+// Usage (from functional_tests, after make dep-update):
+//
+//	go run ./cmd/apiscan [-matrix ../ci-matrix.json] [-examples ../examples] [-kube-version v1.33.4]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+)
+
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func main() {
+	var kubeVersions, valuesFiles stringList
+	chartPath := flag.String("chart", filepath.Join("..", "helm-charts", "splunk-otel-collector"), "chart directory")
+	matrixPath := flag.String("matrix", filepath.Join("..", "ci-matrix.json"), "ci-matrix.json listing the supported Kubernetes versions")
+	examplesDir := flag.String("examples", filepath.Join("..", "examples"), "directory of the examples whose values files are rendered")
+	flag.Var(&kubeVersions, "kube-version", "Kubernetes version to render for instead of the supported ones (repeatable)")
+	flag.Var(&valuesFiles, "values", "values file to render instead of the examples (repeatable)")
+	flag.Parse()
+
+	if len(kubeVersions) == 0 {
+		versions, err := internal.SupportedKubeVersions(*matrixPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		kubeVersions = versions
+	}
+	if len(valuesFiles) == 0 {
+		files, err := internal.ExampleValuesFiles(*examplesDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		valuesFiles = files
+	}
+
+	removed, err := scan(os.Stdout, *chartPath, valuesFiles, kubeVersions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if removed > 0 {
+		log.Fatalf("%d objects use APIs removed in a supported Kubernetes version", removed)
+	}
+	log.Printf("scanned %d values files for Kubernetes %s", len(valuesFiles), strings.Join(kubeVersions, ", "))
+}
+
+// scan prints every finding and returns the number of objects using a removed API.
+func scan(out io.Writer, chartPath string, valuesFiles, kubeVersions []string) (int, error) {
+	removed := 0
+	for _, valuesFile := range valuesFiles {
+		values, err := internal.ReadValuesFile(valuesFile)
+		if err != nil {
+			return 0, err
+		}
+		for _, kubeVersion := range kubeVersions {
+			files, err := internal.RenderChartFor(chartPath, values, kubeVersion)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", valuesFile, err)
+			}
+			findings, err := internal.ScanRemovedAPIs(files, kubeVersion)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", valuesFile, err)
+			}
+			for _, finding := range findings {
+				level := "deprecated"
+				if finding.Removed {
+					level = "removed"
+					removed++
+				}
+				fmt.Fprintf(out, "%s %s: %s\n", level, valuesFile, finding)
+			}
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,306 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// golden2assertion converts expected_*.yaml golden metrics files into pmetricassert
+// snapshot files and prints the internal.AssertMetricsSnapshot options each test needs.
+//
+// Usage:
+//
+//	go run ./cmd/golden2assertion [flags] <file-or-dir>...
+//
+// Directories are scanned for expected_*.yaml files that are not already assertions.
+// Files that do not contain metrics (traces, logs) are skipped, files that cannot be read fail
+// the conversion.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/golden"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+)
+
+const assertionSuffix = "_assertion.yaml"
+
+// cliTB satisfies the testing.TB parameter of WriteMetricsAssertion, which only calls Helper.
+type cliTB struct {
+	testing.TB
+}
+
+func (cliTB) Helper() {}
+
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
+type regexList map[string]string
+
+func (r regexList) String() string { return fmt.Sprint(map[string]string(r)) }
+
+func (r regexList) Set(v string) error {
+	key, pattern, ok := strings.Cut(v, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=pattern, got %q", v)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid pattern for %s: %w", key, err)
+	}
+	r[key] = pattern
+	return nil
+}
+
+type config struct {
+	existsAttrs        []string
+	regexAttrs         map[string]string
+	firstDatapointOnly []string
+	targetMetric       string
+	dryRun             bool
+}
+
+// plan is what the conversion of one golden file needs.
+type plan struct {
+	source, output     string
+	targetMetric       string
+	firstDatapointOnly []string
+	histogramBounds    bool
+	scopeVersionRegex  bool
+}
+
+func main() {
+	var extraExists stringList
+	var firstOnly stringList
+	extraRegex := regexList{}
+	cfg := config{}
+	flag.Var(&extraExists, "exists", "additional attributes written as <key>/exists (comma-separated, repeatable)")
+	flag.Var(extraRegex, "regex", "additional key=pattern attributes written as <key>/regex (repeatable)")
+	flag.Var(&firstOnly, "first-datapoint-only", "metrics to reduce to their first datapoint, in addition to the detected ones")
+	flag.StringVar(&cfg.targetMetric, "target", "", "target metric used to select the snapshot batch; defaults to the first metric in each file")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "print the plan without writing assertion files")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg.existsAttrs = internal.ExtendMetricAssertionAttrs(internal.CommonK8sMetricAssertionExistsAttrs, extraExists...)
+	cfg.regexAttrs = internal.ExtendMetricAssertionRegexAttrs(internal.CommonK8sMetricAssertionRegexAttrs, extraRegex)
+	cfg.firstDatapointOnly = firstOnly
+
+	files, err := expandInputs(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	failed := false
+	for _, file := range files {
+		if err := convert(file, cfg); err != nil {
+			log.Printf("%s: %v", file, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// expandInputs resolves directories to the golden metric candidates they contain.
+func expandInputs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "expected_*.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !strings.HasSuffix(m, assertionSuffix) {
+				files = append(files, m)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func convert(file string, cfg config) error {
+	metrics, err := golden.ReadMetrics(file)
+	if err != nil {
+		return fmt.Errorf("read golden metrics: %w", err)
+	}
+	if metrics.MetricCount() == 0 {
+		log.Printf("skipping %s: no metrics", file)
+		return nil
+	}
+	p := buildPlan(file, metrics, cfg)
+	if !cfg.dryRun {
+		if err := internal.WriteMetricsAssertion(cliTB{}, p.output, metrics, p.options(cfg)...); err != nil {
+			return err
+		}
+	}
+	fmt.Print(p.snippet())
+	return nil
+}
+
+func buildPlan(file string, metrics pmetric.Metrics, cfg config) plan {
+	p := plan{
+		source:       file,
+		output:       strings.TrimSuffix(file, ".yaml") + assertionSuffix,
+		targetMetric: cfg.targetMetric,
+	}
+	flexible := map[string]struct{}{}
+	for _, attr := range cfg.existsAttrs {
+		flexible[attr] = struct{}{}
+	}
+	for attr := range cfg.regexAttrs {
+		flexible[attr] = struct{}{}
+	}
+	firstOnly := map[string]struct{}{}
+	for _, name := range cfg.firstDatapointOnly {
+		firstOnly[name] = struct{}{}
+	}
+	collectorVersion := regexp.MustCompile(`^` + internal.OtelCollectorVersionRegex + `$`)
+
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			if collectorVersion.MatchString(sm.Scope().Version()) {
+				p.scopeVersionRegex = true
+			}
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				if p.targetMetric == "" {
+					p.targetMetric = metric.Name()
+				}
+				if metric.Type() == pmetric.MetricTypeHistogram {
+					p.histogramBounds = true
+				}
+				if hasDuplicateSeries(metric, flexible) {
+					firstOnly[metric.Name()] = struct{}{}
+				}
+			}
+		}
+	}
+	for name := range firstOnly {
+		p.firstDatapointOnly = append(p.firstDatapointOnly, name)
+	}
+	sort.Strings(p.firstDatapointOnly)
+	return p
+}
+
+// hasDuplicateSeries reports whether two datapoints of metric become indistinguishable
+// once the flexible attributes are masked. The snapshot cannot tell such series apart,
+// which is what pmetrictest.IgnoreSubsequentDataPoints used to paper over.
+func hasDuplicateSeries(metric pmetric.Metric, flexible map[string]struct{}) bool {
+	seen := map[string]struct{}{}
+	duplicate := false
+	visit := func(attrs pcommon.Map) {
+		var parts []string
+		attrs.Range(func(k string, v pcommon.Value) bool {
+			if _, ok := flexible[k]; ok {
+				parts = append(parts, k)
+			} else {
+				parts = append(parts, k+"="+v.AsString())
+			}
+			return true
+		})
+		sort.Strings(parts)
+		key := strings.Join(parts, "\x00")
+		if _, ok := seen[key]; ok {
+			duplicate = true
+		}
+		seen[key] = struct{}{}
+	}
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			visit(metric.Gauge().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			visit(metric.Sum().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			visit(metric.Histogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			visit(metric.ExponentialHistogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			visit(metric.Summary().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeEmpty:
+	}
+	return duplicate
+}
+
+func (p plan) options(cfg config) []internal.MetricsAssertionOption {
+	opts := []internal.MetricsAssertionOption{
+		internal.WithVolatileAttributes(cfg.existsAttrs...),
+		internal.WithRegexAttributes(cfg.regexAttrs),
+	}
+	if len(p.firstDatapointOnly) > 0 {
+		opts = append(opts, internal.WithFirstDatapointOnly(p.firstDatapointOnly...))
+	}
+	if p.histogramBounds {
+		opts = append(opts, internal.WithHistogramExplicitBounds())
+	}
+	if p.scopeVersionRegex {
+		opts = append(opts, internal.WithScopeVersionRegex(internal.OtelCollectorVersionRegex))
+	}
+	return opts
+}
+
+// snippet renders the AssertMetricsSnapshot call the migrated test should make.
+// Extra -exists/-regex flags must be mirrored with ExtendMetricAssertion* near the test.
+func (p plan) snippet() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s -> %s\n", p.source, p.output)
+	fmt.Fprintf(&sb, "internal.AssertMetricsSnapshot(t, sink, %q, assertionFile, 3*time.Minute, 10*time.Second,\n", p.targetMetric)
+	sb.WriteString("\tinternal.WithVolatileAttributes(existsAttrs...),\n")
+	sb.WriteString("\tinternal.WithRegexAttributes(regexAttrs),\n")
+	if len(p.firstDatapointOnly) > 0 {
+		quoted := make([]string, len(p.firstDatapointOnly))
+		for i, name := range p.firstDatapointOnly {
+			quoted[i] = fmt.Sprintf("%q", name)
+		}
+		fmt.Fprintf(&sb, "\tinternal.WithFirstDatapointOnly(%s),\n", strings.Join(quoted, ", "))
+	}
+	if p.histogramBounds {
+		sb.WriteString("\tinternal.WithHistogramExplicitBounds(),\n")
+	}
+	if p.scopeVersionRegex {
+		sb.WriteString("\tinternal.WithScopeVersionRegex(internal.OtelCollectorVersionRegex),\n")
+	}
+	sb.WriteString(")\n\n")
+	return sb.String()
+}
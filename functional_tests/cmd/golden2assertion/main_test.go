@@ -0,0 +1,81 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/golden"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestBuildPlan(t *testing.T) {
+	t.Parallel()
+
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Scope().SetVersion("v0.150.0")
+
+	// Two pods: indistinguishable once k8s.pod.name is masked.
+	perPod := sm.Metrics().AppendEmpty()
+	perPod.SetName("container.cpu.usage")
+	perPod.SetEmptyGauge()
+	for _, pod := range []string{"a-1", "b-2"} {
+		perPod.Gauge().DataPoints().AppendEmpty().Attributes().PutStr("k8s.pod.name", pod)
+	}
+
+	// Distinct state values stay distinct.
+	perState := sm.Metrics().AppendEmpty()
+	perState.SetName("system.memory.usage")
+	perState.SetEmptySum()
+	for _, state := range []string{"used", "free"} {
+		perState.Sum().DataPoints().AppendEmpty().Attributes().PutStr("state", state)
+	}
+
+	sm.Metrics().AppendEmpty().SetName("http.server.duration")
+	sm.Metrics().At(2).SetEmptyHistogram().DataPoints().AppendEmpty()
+
+	p := buildPlan("dir/expected_foo.yaml", md, config{
+		regexAttrs:         map[string]string{"k8s.pod.name": ".*"},
+		firstDatapointOnly: []string{"extra.metric"},
+	})
+	require.Equal(t, "dir/expected_foo_assertion.yaml", p.output)
+	require.Equal(t, "container.cpu.usage", p.targetMetric)
+	require.Equal(t, []string{"container.cpu.usage", "extra.metric"}, p.firstDatapointOnly)
+	require.True(t, p.histogramBounds)
+	require.True(t, p.scopeVersionRegex)
+	require.Contains(t, p.snippet(), `internal.WithFirstDatapointOnly("container.cpu.usage", "extra.metric")`)
+}
+
+func TestConvertSkipsNonMetricsAndFailsOnMalformedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	traces := filepath.Join(dir, "expected_traces.yaml")
+	require.NoError(t, os.WriteFile(traces, []byte("resourceSpans:\n  - scopeSpans:\n      - spans:\n          - name: GET /\n"), 0o600))
+	require.NoError(t, convert(traces, config{}))
+	require.NoFileExists(t, filepath.Join(dir, "expected_traces"+assertionSuffix))
+
+	malformed := filepath.Join(dir, "expected_malformed.yaml")
+	require.NoError(t, os.WriteFile(malformed, []byte("resourceMetrics: [\n"), 0o600))
+	require.ErrorContains(t, convert(malformed, config{}), "read golden metrics")
+}
+
+func TestConvertWritesAssertion(t *testing.T) {
+	t.Parallel()
+
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("k8s.pod.phase")
+	metric.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(2)
+	file := filepath.Join(t.TempDir(), "expected_pods.yaml")
+	require.NoError(t, golden.WriteMetricsToFile(file, md))
+
+	require.NoError(t, convert(file, config{}))
+	require.FileExists(t, strings.TrimSuffix(file, ".yaml")+assertionSuffix)
+}
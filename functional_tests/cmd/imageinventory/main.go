@@ -0,0 +1,184 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// imageinventory lists every image a functional test run pulls and, for air-gapped runs,
+// checks them against a directory of image archives and loads them into the cluster.
+//
+// The inventory covers the rendered chart for each values file (including the instrumentation
+// images the operator injects), test workload manifests and the suites' helper images.
+// Values files and manifests may be Go templates as used by the suites; template fields are
+// rendered with a placeholder URL.
+//
+// Usage (from functional_tests):
+//
+//	go run ./cmd/imageinventory -values functional/testdata/values/test_values.yaml.tmpl \
+//	    -manifests functional/testdata/nodejs [-archives /path/to/images [-load]]
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+)
+
+const templatePlaceholder = "http://localhost:0"
+
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func main() {
+	var valuesFiles, manifestPaths, extraImages stringList
+	chartPath := flag.String("chart", filepath.Join("..", "helm-charts", "splunk-otel-collector"), "chart directory")
+	flag.Var(&valuesFiles, "values", "chart values file or values template (repeatable; each is rendered separately)")
+	flag.Var(&manifestPaths, "manifests", "test workload manifest file or directory (repeatable)")
+	flag.Var(&extraImages, "image", "additional image reference (repeatable)")
+	helpers := flag.Bool("helpers", true, "include the helper images the suites start outside the chart")
+	archiveDir := flag.String("archives", "", "directory of docker-archive/OCI archives and OCI layouts to check the images against")
+	load := flag.Bool("load", false, "load the archives into the cluster selected by CLUSTER_PROVIDER (requires -archives)")
+	flag.Parse()
+
+	images, err := inventory(*chartPath, valuesFiles, manifestPaths)
+	if err != nil {
+		log.Fatal(err)
+	}
+	images = append(images, extraImages...)
+	if *helpers {
+		images = append(images, internal.TestHelperImages()...)
+	}
+	slices.Sort(images)
+	images = slices.Compact(images)
+
+	if *archiveDir == "" {
+		for _, image := range images {
+			fmt.Println(image)
+		}
+		return
+	}
+
+	store, err := internal.ScanImageArchives(*archiveDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, image := range images {
+		if path, ok := store.Lookup(image); ok {
+			fmt.Printf("present %s %s\n", image, path)
+		} else {
+			fmt.Printf("missing %s\n", image)
+		}
+	}
+	paths, missing := store.Check(images)
+	if len(missing) > 0 {
+		log.Fatalf("%d of %d images are missing from %s", len(missing), len(images), *archiveDir)
+	}
+	if !*load {
+		return
+	}
+	provider, err := internal.NewClusterProvider(os.Getenv("CLUSTER_PROVIDER"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmpDir, err := os.MkdirTemp("", "imageinventory")
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("loading %d archives into %s", len(paths), provider.Name())
+	err = internal.LoadImageArchives(context.Background(), provider, tmpDir, paths)
+	os.RemoveAll(tmpDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func inventory(chartPath string, valuesFiles, manifestPaths []string) ([]string, error) {
+	var images []string
+	if len(valuesFiles) == 0 {
+		valuesFiles = []string{""}
+	}
+	for _, valuesFile := range valuesFiles {
+		values := map[string]any{}
+		if valuesFile != "" {
+			data, err := renderTemplateFile(valuesFile)
+			if err != nil {
+				return nil, err
+			}
+			if err = yaml.Unmarshal(data, &values); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", valuesFile, err)
+			}
+		}
+		manifest, coalesced, err := internal.RenderChart(chartPath, values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", valuesFile, err)
+		}
+		chartImages, err := internal.ChartImages(manifest, coalesced)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", valuesFile, err)
+		}
+		images = append(images, chartImages...)
+	}
+	for _, root := range manifestPaths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !isManifest(path) {
+				return err
+			}
+			data, err := renderTemplateFile(path)
+			if err != nil {
+				return err
+			}
+			manifestImages, err := internal.ImagesInManifest(string(data))
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			images = append(images, manifestImages...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+func isManifest(path string) bool {
+	for _, ext := range []string{".yaml", ".yml", ".yaml.tmpl", ".tmpl"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTemplateFile renders the suites' values and manifest templates without their
+// runtime replacements, which never carry image references.
+func renderTemplateFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, map[string]any{}); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", path, err)
+	}
+	return bytes.ReplaceAll(buf.Bytes(), []byte("<no value>"), []byte(templatePlaceholder)), nil
+}
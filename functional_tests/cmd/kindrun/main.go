@@ -0,0 +1,220 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// kindrun runs a functional test suite against a fresh kind cluster for each Kubernetes
+// version ci-matrix.json lists for that suite, so that local runs and CI share one flow:
+// create the cluster, approve the kubelet serving CSRs, update chart dependencies, load
+// local images, run the suite and delete the cluster.
+//
+// Usage (from functional_tests):
+//
+//	go run ./cmd/kindrun -suite histogram [-k8s-version v1.36.1] [-image repo:tag]... [-- go test flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+)
+
+// Test app images built by the kind-build-test-images Makefile target.
+var testAppImages = map[string]string{
+	"quay.io/splunko11ytest/python_test:latest": "functional/testdata/python",
+	"quay.io/splunko11ytest/nodejs_test:latest": "functional/testdata/nodejs",
+}
+
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+type config struct {
+	suite           string
+	version         string
+	allVersions     bool
+	matrixPath      string
+	clusterName     string
+	kindConfig      string
+	kubeConfig      string
+	images          []string
+	buildTestImages bool
+	depUpdate       bool
+	keepCluster     bool
+	testTimeout     time.Duration
+	goTestArgs      []string
+}
+
+func main() {
+	var images stringList
+	cfg := config{}
+	flag.StringVar(&cfg.suite, "suite", "", "functional test suite (directory) to run; must be listed in the matrix test-job list unless -k8s-version is set")
+	flag.StringVar(&cfg.version, "k8s-version", "", "kind node version; defaults to the first matrix version for the suite")
+	flag.BoolVar(&cfg.allVersions, "all-versions", false, "run the suite once per matrix version, each on a fresh cluster")
+	flag.StringVar(&cfg.matrixPath, "matrix", filepath.Join("..", "ci-matrix.json"), "path to ci-matrix.json")
+	flag.StringVar(&cfg.clusterName, "name", "kind", "kind cluster name")
+	flag.StringVar(&cfg.kindConfig, "config", filepath.Join("..", ".github", "workflows", "configs", "kind-config.yaml"), "kind cluster config")
+	flag.StringVar(&cfg.kubeConfig, "kubeconfig", "/tmp/kube-config-splunk-otel-collector-chart-functional-testing", "kubeconfig file written for the cluster")
+	flag.Var(&images, "image", "local image to load into the cluster (repeatable)")
+	flag.BoolVar(&cfg.buildTestImages, "build-test-images", true, "build and load the python and nodejs test app images")
+	flag.BoolVar(&cfg.depUpdate, "dep-update", true, "run make dep-update before the suite")
+	flag.BoolVar(&cfg.keepCluster, "keep-cluster", false, "leave the cluster running after the suite")
+	flag.DurationVar(&cfg.testTimeout, "timeout", 45*time.Minute, "go test timeout")
+	flag.Parse()
+	cfg.images = images
+	cfg.goTestArgs = flag.Args()
+	if cfg.suite == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, cfg); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			log.Printf("suite %s failed: %v", cfg.suite, err)
+			os.Exit(exitErr.ExitCode())
+		}
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, cfg config) error {
+	versions, err := kindVersions(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.depUpdate {
+		if err = runStreaming(ctx, "..", nil, "make", "dep-update"); err != nil {
+			return err
+		}
+	}
+	if cfg.buildTestImages {
+		for image, dir := range testAppImages {
+			if err = runStreaming(ctx, ".", nil, "docker", "build", "-t", image, dir); err != nil {
+				return err
+			}
+			cfg.images = append(cfg.images, image)
+		}
+	}
+
+	var errs []error
+	for _, version := range versions {
+		if err = runVersion(ctx, cfg, version); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// kindVersions returns the versions to run the suite on: the requested one, or the matrix
+// versions of the suite. CI jobs pass the version, as their matrix already expanded it.
+func kindVersions(cfg config) ([]string, error) {
+	if cfg.version != "" {
+		if _, err := os.Stat(cfg.suite); err != nil {
+			return nil, fmt.Errorf("suite %q: %w", cfg.suite, err)
+		}
+		return []string{cfg.version}, nil
+	}
+	entry, err := internal.ReadCIMatrixEntry(cfg.matrixPath, internal.CIMatrixKindWorkflow)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := entry.KindVersionsFor(cfg.suite)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.allVersions {
+		versions = versions[:1]
+	}
+	return versions, nil
+}
+
+func runVersion(ctx context.Context, cfg config, version string) (err error) {
+	cluster := internal.KindCluster{
+		Name:       cfg.clusterName,
+		Version:    version,
+		ConfigPath: cfg.kindConfig,
+		KubeConfig: cfg.kubeConfig,
+	}
+	exists, err := cluster.Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("kind cluster %q already exists; delete it with make kind-delete first", cluster.Name)
+	}
+
+	log.Printf("creating kind cluster %s with %s", cluster.Name, cluster.NodeImage())
+	if err = cluster.Create(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if cfg.keepCluster {
+			log.Printf("keeping kind cluster %s, KUBECONFIG=%s", cluster.Name, cluster.KubeConfig)
+			return
+		}
+		// The run context may already be cancelled by an interrupt; teardown still has to happen.
+		deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+		defer cancel()
+		err = errors.Join(err, cluster.Delete(deleteCtx))
+	}()
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", cluster.KubeConfig)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	log.Print("approving kubelet serving certificates")
+	if err = internal.ApproveKubeletServingCSRs(ctx, clientset, 2*time.Minute); err != nil {
+		return err
+	}
+	if len(cfg.images) > 0 {
+		log.Printf("loading images %v", cfg.images)
+		if err = internal.NewKindClusterProvider(cluster.Name).LoadImages(ctx, cfg.images...); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("running suite %s on %s", cfg.suite, version)
+	args := append([]string{"test", "-v", "-timeout", cfg.testTimeout.String(), "./" + cfg.suite + "/..."}, cfg.goTestArgs...)
+	return runStreaming(ctx, ".", []string{
+		"KUBECONFIG=" + cluster.KubeConfig,
+		"KUBE_TEST_ENV=kind",
+		"K8S_VERSION=" + version,
+		"CLUSTER_PROVIDER=" + internal.ClusterProviderKind,
+		"KIND_CLUSTER_NAME=" + cluster.Name,
+		"TEARDOWN_BEFORE_SETUP=true",
+	}, "go", args...)
+}
+
+func runStreaming(ctx context.Context, dir string, env []string, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,67 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// leaseholders shows who holds which functional test lease on the cluster and who is queued
+// for it. With -break it releases the lease of a scope whose holder is gone without waiting
+// for the lease to expire; the first waiter then takes it.
+//
+// Usage (from functional_tests, with KUBECONFIG set):
+//
+//	go run ./cmd/leaseholders [-break cluster]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+)
+
+func main() {
+	breakScope := flag.String("break", "", "release the lease of this scope whoever holds it")
+	flag.Parse()
+
+	kubeConfig := os.Getenv("KUBECONFIG")
+	if kubeConfig == "" {
+		log.Fatal("the environment variable KUBECONFIG must be set")
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if *breakScope != "" {
+		holder, breakErr := internal.BreakLease(ctx, clientset, *breakScope)
+		if breakErr != nil {
+			log.Fatalf("failed to break the %s lease: %v", *breakScope, breakErr)
+		}
+		log.Printf("released the %s lease held by %s", *breakScope, holder)
+	}
+
+	statuses, err := internal.ListLeases(ctx, clientset)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(statuses) == 0 {
+		fmt.Println("no functional test leases")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tHOLDER\tHELD/MAX\tQUEUE")
+	fmt.Fprint(tw, internal.FormatLeases(statuses, time.Now()))
+	tw.Flush()
+}
@@ -0,0 +1,243 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// suiterunner runs the functional test suites the current cluster can host. Each suite
+// declares its requirements in its package (see internal.SuiteRequirements); suites whose
+// requirements are not met are reported as skipped instead of failing mid-run. The cluster
+// lease is only taken for cluster-exclusive suites, and results are written as JUnit XML
+// together with a per-test and per-subtest timing report.
+//
+// Usage (from functional_tests, with KUBECONFIG and KUBE_TEST_ENV set):
+//
+//	go run ./cmd/suiterunner -list
+//	go run ./cmd/suiterunner [-suites histogram,logs] [-capabilities -internet] \
+//	    [-junit results.xml] [-timing timing.txt] [-- go test flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	configurationswitching "github.com/signalfx/splunk-otel-collector-chart/functional_tests/configuration_switching"
+	discovery "github.com/signalfx/splunk-otel-collector-chart/functional_tests/discovery"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/functional"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/gateway"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/histogram"
+	instrumentationcr "github.com/signalfx/splunk-otel-collector-chart/functional_tests/instrumentation_cr"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/istio"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/k8sentities"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/k8sevents"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/logs"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/obi"
+	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/secureapp"
+)
+
+// suites lists every suite package in run order.
+var suites = []internal.SuiteRequirements{
+	functional.Requirements,
+	histogram.Requirements,
+	configurationswitching.Requirements,
+	k8sevents.Requirements,
+	k8sentities.Requirements,
+	istio.Requirements,
+	discovery.Requirements,
+	gateway.Requirements,
+	logs.Requirements,
+	obi.Requirements,
+	secureapp.Requirements,
+	instrumentationcr.Requirements,
+}
+
+const modulePath = "github.com/signalfx/splunk-otel-collector-chart/functional_tests/"
+
+func main() {
+	list := flag.Bool("list", false, "list the suites, their requirements and whether the cluster meets them")
+	only := flag.String("suites", "", "comma-separated suites to consider; defaults to all")
+	overrides := flag.String("capabilities", os.Getenv("CLUSTER_CAPABILITIES"), "capabilities to add, or remove with a leading -, e.g. node-ports,-internet")
+	noLease := flag.Bool("no-lease", false, "never take the cluster lease, e.g. on a private kind cluster")
+	junitPath := flag.String("junit", "", "write JUnit XML results to this file")
+	timingPath := flag.String("timing", "", "write per-test timing, slowest first, to this file")
+	timeout := flag.Duration("timeout", 45*time.Minute, "go test timeout per suite")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	selected, err := selectSuites(*only)
+	if err != nil {
+		log.Fatal(err)
+	}
+	kubeConfig := os.Getenv("KUBECONFIG")
+	caps, err := detectCapabilities(ctx, kubeConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	caps.ApplyCapabilityOverrides(*overrides)
+	log.Printf("KUBE_TEST_ENV=%q capabilities: %s", caps.Env, strings.Join(caps.List(), ","))
+
+	if *list {
+		printSuites(os.Stdout, selected, caps)
+		return
+	}
+
+	r := newReport()
+	for _, suite := range selected {
+		if reasons := suite.Unmet(caps); len(reasons) > 0 {
+			log.Printf("skipping suite %s: %s", suite.Name, strings.Join(reasons, "; "))
+			r.addSuiteResult(modulePath+suite.Name, suite.Name, "skip", strings.Join(reasons, "; "))
+			continue
+		}
+		if err = runSuite(ctx, r, suite, kubeConfig, !*noLease, *timeout, flag.Args()); err != nil {
+			log.Printf("suite %s: %v", suite.Name, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if *junitPath != "" {
+		if err = writeFile(*junitPath, r.writeJUnit); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *timingPath != "" {
+		if err = writeFile(*timingPath, r.writeTiming); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if r.failed() {
+		os.Exit(1)
+	}
+}
+
+func selectSuites(only string) ([]internal.SuiteRequirements, error) {
+	if only == "" {
+		return suites, nil
+	}
+	var selected []internal.SuiteRequirements
+	for _, name := range strings.Split(only, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(suites, func(s internal.SuiteRequirements) bool { return s.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown suite %q", name)
+		}
+		selected = append(selected, suites[i])
+	}
+	return selected, nil
+}
+
+func detectCapabilities(ctx context.Context, kubeConfig string) (internal.ClusterCapabilities, error) {
+	if kubeConfig == "" {
+		return internal.ClusterCapabilities{}, errors.New("the environment variable KUBECONFIG must be set")
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+	if err != nil {
+		return internal.ClusterCapabilities{}, err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return internal.ClusterCapabilities{}, err
+	}
+	provider, err := internal.NewClusterProvider(os.Getenv("CLUSTER_PROVIDER"))
+	if err != nil {
+		return internal.ClusterCapabilities{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return internal.DetectClusterCapabilities(ctx, clientset, os.Getenv("KUBE_TEST_ENV"), provider)
+}
+
+func printSuites(w io.Writer, selected []internal.SuiteRequirements, caps internal.ClusterCapabilities) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUITE\tLEASE\tENVIRONMENTS\tCAPABILITIES\tRUNNABLE")
+	for _, suite := range selected {
+		envs := "any"
+		if len(suite.Environments) > 0 {
+			envs = strings.Join(suite.Environments, ",")
+		}
+		capabilities := make([]string, len(suite.Capabilities))
+		for i, capability := range suite.Capabilities {
+			capabilities[i] = string(capability)
+		}
+		lease := "-"
+		if suite.ClusterExclusive {
+			lease = internal.LeaseName(suite.LeaseScope)
+		}
+		runnable := "yes"
+		if reasons := suite.Unmet(caps); len(reasons) > 0 {
+			runnable = "no: " + strings.Join(reasons, "; ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", suite.Name, lease, envs, strings.Join(capabilities, ","), runnable)
+	}
+	tw.Flush()
+}
+
+// errLeaseLost cancels a suite whose lease was taken over or broken while it ran.
+var errLeaseLost = errors.New("lost the cluster lease while the suite ran")
+
+func runSuite(ctx context.Context, r *report, suite internal.SuiteRequirements, kubeConfig string, lease bool, timeout time.Duration, goTestArgs []string) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	env := os.Environ()
+	if lease && suite.ClusterExclusive {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		identity := fmt.Sprintf("%s:suiterunner:%s:%d", hostname, suite.Name, time.Now().UnixNano())
+		log.Printf("acquiring the cluster lease for suite %s", suite.Name)
+		// The suite cannot need the lease for longer than its go test timeout.
+		release, err := internal.AcquireLease(ctx, kubeConfig, identity, log.Printf,
+			internal.WithLeaseScope(suite.LeaseScope), internal.WithLeaseMaxHold(timeout+5*time.Minute),
+			internal.WithLeaseLost(func() { cancel(errLeaseLost) }))
+		if err != nil {
+			r.addSuiteResult(modulePath+suite.Name, suite.Name, "fail", err.Error())
+			return err
+		}
+		defer release()
+		env = append(env, internal.LeaseHolderEnv+"="+identity)
+	}
+
+	args := append([]string{"test", "-json", "-v", "-timeout", timeout.String(), "./" + suite.Name + "/..."}, goTestArgs...)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Env = env
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	consumeErr := r.consume(stdout, os.Stdout)
+	waitErr := cmd.Wait()
+	if cause := context.Cause(ctx); errors.Is(cause, errLeaseLost) {
+		r.addSuiteResult(modulePath+suite.Name, suite.Name, "fail", cause.Error())
+		return errors.Join(consumeErr, cause)
+	}
+	return errors.Join(consumeErr, waitErr)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return errors.Join(write(f), f.Close())
+}
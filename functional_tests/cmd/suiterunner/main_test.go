@@ -0,0 +1,78 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	events := strings.Join([]string{
+		`{"Action":"start","Package":"m/histogram"}`,
+		`{"Action":"run","Package":"m/histogram","Test":"Test_Histogram"}`,
+		`{"Action":"output","Package":"m/histogram","Test":"Test_Histogram","Output":"=== RUN   Test_Histogram\n"}`,
+		`{"Action":"run","Package":"m/histogram","Test":"Test_Histogram/etcd"}`,
+		`{"Action":"output","Package":"m/histogram","Test":"Test_Histogram/etcd","Output":"    histogram_test.go:10: missing etcd_disk\n"}`,
+		`{"Action":"fail","Package":"m/histogram","Test":"Test_Histogram/etcd","Elapsed":12.5}`,
+		`{"Action":"run","Package":"m/histogram","Test":"Test_Histogram/coredns"}`,
+		`{"Action":"pass","Package":"m/histogram","Test":"Test_Histogram/coredns","Elapsed":3}`,
+		`{"Action":"fail","Package":"m/histogram","Test":"Test_Histogram","Elapsed":16}`,
+		`{"Action":"fail","Package":"m/histogram","Elapsed":17}`,
+		`# m/logs [build failed]`,
+		`{"Action":"output","Package":"m/logs","Output":"FAIL\tm/logs [build failed]\n"}`,
+		`{"Action":"fail","Package":"m/logs","Elapsed":0}`,
+	}, "\n")
+
+	r := newReport()
+	var echo bytes.Buffer
+	require.NoError(t, r.consume(strings.NewReader(events), &echo))
+	r.addSuiteResult("m/istio", "istio", "skip", "missing capability internet")
+	require.Contains(t, echo.String(), "missing etcd_disk")
+	require.Contains(t, echo.String(), "# m/logs [build failed]")
+	require.True(t, r.failed())
+
+	var junit bytes.Buffer
+	require.NoError(t, r.writeJUnit(&junit))
+	out := junit.String()
+	require.Contains(t, out, `<testsuite name="m/histogram" tests="3" failures="2" skipped="0" time="17.000">`)
+	require.Contains(t, out, `<testcase classname="m/histogram" name="Test_Histogram/etcd" time="12.500">`)
+	require.Contains(t, out, `<testsuite name="m/logs" tests="1" failures="1" skipped="0" time="0.000">`)
+	require.Contains(t, out, `<skipped message="missing capability internet">`)
+
+	var timing bytes.Buffer
+	require.NoError(t, r.writeTiming(&timing))
+	lines := strings.Split(strings.TrimRight(timing.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	require.Equal(t, "    16.00s\tfail\tm/histogram\tTest_Histogram", lines[0])
+	require.Equal(t, "    12.50s\tfail\tm/histogram\tTest_Histogram/etcd", lines[1])
+}
+
+func TestSuitesCoverEverySuiteDirectory(t *testing.T) {
+	t.Parallel()
+
+	declared := map[string]bool{}
+	for _, suite := range suites {
+		declared[suite.Name] = true
+	}
+	entries, err := os.ReadDir(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "internal" || entry.Name() == "cmd" {
+			continue
+		}
+		tests, err := filepath.Glob(filepath.Join("..", "..", entry.Name(), "*_test.go"))
+		require.NoError(t, err)
+		if len(tests) > 0 {
+			require.True(t, declared[entry.Name()], "suite %s is missing from the runner suites list", entry.Name())
+		}
+	}
+}
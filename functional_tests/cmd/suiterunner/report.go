@@ -0,0 +1,240 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// testEvent is a go test -json (test2json) event.
+type testEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+}
+
+type testResult struct {
+	Package string
+	Test    string
+	Status  string // pass, fail or skip
+	Elapsed float64
+	Output  strings.Builder
+}
+
+// report accumulates the results of every suite in a run.
+type report struct {
+	results []*testResult
+	byKey   map[string]*testResult
+	// packageElapsed is the package wall time reported by go test, keyed by package.
+	packageElapsed map[string]float64
+	// packageOutput keeps package-level output such as build failures and panics.
+	packageOutput map[string]*strings.Builder
+	packages      []string
+}
+
+func newReport() *report {
+	return &report{
+		byKey:          map[string]*testResult{},
+		packageElapsed: map[string]float64{},
+		packageOutput:  map[string]*strings.Builder{},
+	}
+}
+
+// consume reads go test -json output, mirroring the test output to echo.
+func (r *report) consume(in io.Reader, echo io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// Not an event, e.g. build output; keep it visible.
+			if echo != nil {
+				fmt.Fprintln(echo, scanner.Text())
+			}
+			continue
+		}
+		if ev.Output != "" && echo != nil {
+			fmt.Fprint(echo, ev.Output)
+		}
+		r.add(ev)
+	}
+	return scanner.Err()
+}
+
+func (r *report) add(ev testEvent) {
+	if ev.Package == "" {
+		return
+	}
+	if _, ok := r.packageOutput[ev.Package]; !ok {
+		r.packageOutput[ev.Package] = &strings.Builder{}
+		r.packages = append(r.packages, ev.Package)
+	}
+	if ev.Test == "" {
+		switch ev.Action {
+		case "output":
+			r.packageOutput[ev.Package].WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			r.packageElapsed[ev.Package] = ev.Elapsed
+			if ev.Action == "fail" && !r.hasFailure(ev.Package) {
+				// The package failed without a failing test: build error, panic or timeout.
+				r.addSuiteResult(ev.Package, "", "fail", r.packageOutput[ev.Package].String())
+			}
+		}
+		return
+	}
+	key := ev.Package + "\x00" + ev.Test
+	res, ok := r.byKey[key]
+	if !ok {
+		res = &testResult{Package: ev.Package, Test: ev.Test}
+		r.byKey[key] = res
+		r.results = append(r.results, res)
+	}
+	switch ev.Action {
+	case "output":
+		res.Output.WriteString(ev.Output)
+	case "pass", "fail", "skip":
+		res.Status = ev.Action
+		res.Elapsed = ev.Elapsed
+	}
+}
+
+func (r *report) hasFailure(pkg string) bool {
+	for _, res := range r.results {
+		if res.Package == pkg && (res.Status == "fail" || res.Status == "") {
+			return true
+		}
+	}
+	return false
+}
+
+// addSuiteResult records a suite-level result, used for suites filtered out by their
+// requirements and for packages that failed outside of any test.
+func (r *report) addSuiteResult(pkg, test, status, message string) {
+	if _, ok := r.packageOutput[pkg]; !ok {
+		r.packageOutput[pkg] = &strings.Builder{}
+		r.packages = append(r.packages, pkg)
+	}
+	if test == "" {
+		test = pkg
+	}
+	res := &testResult{Package: pkg, Test: test, Status: status}
+	res.Output.WriteString(message)
+	r.results = append(r.results, res)
+}
+
+// failed reports whether any test failed.
+func (r *report) failed() bool {
+	for _, res := range r.results {
+		if res.Status == "fail" || res.Status == "" {
+			return true
+		}
+	}
+	return false
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit writes one testsuite per package and one testcase per test and subtest.
+// Tests that never reported a result (the package timed out or crashed) are failures.
+func (r *report) writeJUnit(w io.Writer) error {
+	out := junitTestSuites{}
+	for _, pkg := range r.packages {
+		suite := junitTestSuite{Name: pkg, Time: seconds(r.packageElapsed[pkg])}
+		for _, res := range r.results {
+			if res.Package != pkg {
+				continue
+			}
+			tc := junitTestCase{ClassName: pkg, Name: res.Test, Time: seconds(res.Elapsed)}
+			switch res.Status {
+			case "fail", "":
+				suite.Failures++
+				msg := "failed"
+				if res.Status == "" {
+					msg = "no result reported (timeout or crash)"
+				}
+				tc.Failure = &junitMessage{Message: msg, Body: res.Output.String()}
+			case "skip":
+				suite.Skipped++
+				tc.Skipped = &junitMessage{Message: lastLine(res.Output.String())}
+			default:
+				tc.SystemOut = res.Output.String()
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+		out.Suites = append(out.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeTiming writes the elapsed time of every test and subtest, slowest first.
+func (r *report) writeTiming(w io.Writer) error {
+	sorted := make([]*testResult, 0, len(r.results))
+	for _, res := range r.results {
+		if res.Status != "" {
+			sorted = append(sorted, res)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Elapsed > sorted[j].Elapsed })
+	for _, res := range sorted {
+		if _, err := fmt.Fprintf(w, "%9.2fs\t%s\t%s\t%s\n", res.Elapsed, res.Status, res.Package, res.Test); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
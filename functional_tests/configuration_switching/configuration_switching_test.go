@@ -75,6 +75,7 @@ func teardown(t *testing.T) {
 }
 
 func Test_Functions(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	globalSinks = &sinks{
 		logsConsumer:        internal.SetupHECLogsSink(t),
 		hecMetricsConsumer:  internal.SetupHECMetricsSink(t),
@@ -174,10 +175,8 @@ func testIndexSwitch(t *testing.T) {
 		internal.WaitForMetrics(t, 3, hecMetricsConsumer)
 		internal.WaitForLogs(t, 3, agentLogsConsumer)
 
-		var sourcetypes, originalSourcetypes []string
-		var indices []string
-		logs := agentLogsConsumer.AllLogs()
-		originalSourcetypes, indices = getLogsIndexAndSourceType(logs)
+		var sourcetypes []string
+		originalSourcetypes, _ := getLogsIndexAndSourceType(agentLogsConsumer.AllLogs())
 
 		// This is to avoid flaky test failures as logs are also coming from cluster receiver events
 		eventPrefix := "kube:event"
@@ -196,12 +195,8 @@ func testIndexSwitch(t *testing.T) {
 			}
 		}
 		assert.NotContains(t, sourcetypes, nonDefaultSourcetype)
-		assert.Len(t, indices, 1)
-		assert.Equal(t, logsIndex, indices[0])
-
-		_, mIndices := getMetricsIndexAndSourceType(hecMetricsConsumer.AllMetrics())
-		assert.Len(t, mIndices, 1)
-		assert.Equal(t, metricsIndex, mIndices[0])
+		internal.AssertAllLogs(t, agentLogsConsumer, fmt.Sprintf(`resource.attributes["com.splunk.index"] == %q`, logsIndex))
+		internal.AssertAllMetrics(t, hecMetricsConsumer, fmt.Sprintf(`resource.attributes["com.splunk.index"] == %q`, metricsIndex))
 	})
 
 	t.Run("non_default_source_type for both logs and another one for metrics", func(t *testing.T) {
@@ -425,142 +420,46 @@ func listPodsInNamespace(t *testing.T, namespace string) *corev1.PodList {
 }
 
 func getLogsIndexAndSourceType(logs []plog.Logs) ([]string, []string) {
-	var sourcetypes []string
-	var indices []string
-
-	for i := 0; i < len(logs); i++ {
-		l := logs[i]
-		for j := 0; j < l.ResourceLogs().Len(); j++ {
-			rl := l.ResourceLogs().At(j)
-			if value, ok := rl.Resource().Attributes().Get("com.splunk.sourcetype"); ok {
-				sourcetype := value.AsString()
-				// check if sourcetype is already in the list
-				if !contains(sourcetypes, sourcetype) {
-					sourcetypes = append(sourcetypes, sourcetype)
-				}
-			}
-			if value, ok := rl.Resource().Attributes().Get("com.splunk.index"); ok {
-				index := value.AsString()
-				// check if index is already in the list
-				if !contains(indices, index) {
-					indices = append(indices, index)
-				}
-			}
-		}
-	}
-	return sourcetypes, indices
+	rows := internal.QueryLogs(logs)
+	return internal.DistinctValues(rows, func(r internal.LogRow) (string, bool) { return r.ResourceAttr("com.splunk.sourcetype") }),
+		internal.DistinctValues(rows, func(r internal.LogRow) (string, bool) { return r.ResourceAttr("com.splunk.index") })
 }
 
 // get metrics index from metrics
 func getMetricsIndexAndSourceType(metrics []pmetric.Metrics) ([]string, []string) {
-	var sourcetypes []string
-	var indices []string
-	for i := 0; i < len(metrics); i++ {
-		m := metrics[i]
-		if value, ok := m.ResourceMetrics().At(0).Resource().Attributes().Get("com.splunk.sourcetype"); ok {
-			sourcetype := value.AsString()
-			// check if sourcetype is already in the list
-			if !contains(sourcetypes, sourcetype) {
-				sourcetypes = append(sourcetypes, sourcetype)
-			}
-		}
-		if value, ok := m.ResourceMetrics().At(0).Resource().Attributes().Get("com.splunk.index"); ok {
-			index := value.AsString()
-			if !contains(indices, index) {
-				indices = append(indices, index)
-			}
-		}
-	}
-	return sourcetypes, indices
-}
-
-func contains(list []string, newValue string) bool {
-	for _, v := range list {
-		if v == newValue {
-			return true
-		}
-	}
-	return false
+	rows := internal.QueryMetrics(metrics)
+	return internal.DistinctValues(rows, func(r internal.MetricRow) (string, bool) { return r.ResourceAttr("com.splunk.sourcetype") }),
+		internal.DistinctValues(rows, func(r internal.MetricRow) (string, bool) { return r.ResourceAttr("com.splunk.index") })
 }
 
 func getLogsAttributes(logs []plog.Logs, attributeName string) ([]string, int) {
-	var attributes []string
+	rows := internal.QueryLogs(logs)
 	notFoundCounter := 0
-	foundCounter := 0
-
-	for i := 0; i < len(logs); i++ {
-		l := logs[i]
-		for j := 0; j < l.ResourceLogs().Len(); j++ {
-			rl := l.ResourceLogs().At(j)
-			for k := 0; k < rl.ScopeLogs().Len(); k++ {
-				sl := rl.ScopeLogs().At(k)
-				for m := 0; m < sl.LogRecords().Len(); m++ {
-					tmpAttribute, ok := sl.LogRecords().At(m).Attributes().Get(attributeName)
-					if ok {
-						if !contains(attributes, tmpAttribute.AsString()) {
-							attributes = append(attributes, tmpAttribute.AsString())
-						}
-						foundCounter++
-					} else {
-						fmt.Println("=== Attribute not found: ", attributeName)
-						fmt.Printf("Log Record Body: %v\n", sl.LogRecords().At(m).Body().AsRaw())
-						notFoundCounter++
-					}
-				}
-			}
+	for _, row := range rows {
+		if _, ok := row.Attr(attributeName); !ok {
+			fmt.Println("=== Attribute not found: ", attributeName)
+			fmt.Printf("Log Record Body: %v\n", row.Record.Body().AsRaw())
+			notFoundCounter++
 		}
 	}
-	fmt.Printf("Counters: Found: %d | Not Found: %d\n", foundCounter, notFoundCounter)
-	return attributes, notFoundCounter
+	fmt.Printf("Counters: Found: %d | Not Found: %d\n", len(rows)-notFoundCounter, notFoundCounter)
+	return internal.DistinctValues(rows, func(r internal.LogRow) (string, bool) { return r.Attr(attributeName) }), notFoundCounter
 }
 
 func getMetricsAttributes(metrics []pmetric.Metrics, attributeName string) ([]string, int) {
-	var resourceAttributes []string
-	notFoundCounter := 0
-	foundCounter := 0
-	skippedCounter := 0
-	prefixesForMetricsToSkip := []string{
+	rows := internal.QueryMetrics(metrics, internal.WithoutNamePrefixes(
 		// agent metrics
 		"system.", "k8s.node.",
 		// cluster receiver metrics
 		"k8s.deployment.", "k8s.namespace.", "k8s.replicaset.", "k8s.daemonset.",
-	}
-
-	for i := 0; i < len(metrics); i++ {
-		m := metrics[i]
-		for j := 0; j < m.ResourceMetrics().Len(); j++ {
-			rm := m.ResourceMetrics().At(j)
-			for k := 0; k < rm.ScopeMetrics().Len(); k++ {
-				sm := rm.ScopeMetrics().At(k)
-				for l := 0; l < sm.Metrics().Len(); l++ {
-					skip := false
-					for _, prefix := range prefixesForMetricsToSkip {
-						if strings.HasPrefix(sm.Metrics().At(l).Name(), prefix) {
-							skip = true
-							break
-						}
-					}
-					if skip {
-						skippedCounter++
-						continue
-					}
-					for m := 0; m < sm.Metrics().At(l).Gauge().DataPoints().Len(); m++ {
-						tmpAttribute, ok := sm.Metrics().At(l).Gauge().DataPoints().At(m).Attributes().Get(attributeName)
-
-						if ok {
-							if !contains(resourceAttributes, tmpAttribute.AsString()) {
-								resourceAttributes = append(resourceAttributes, tmpAttribute.AsString())
-							}
-							foundCounter++
-						} else {
-							fmt.Printf("Resource Attribute %s not found for metric: %v \n", attributeName, sm.Metrics().At(l).Name())
-							notFoundCounter++
-						}
-					}
-				}
-			}
+	))
+	notFoundCounter := 0
+	for _, row := range rows {
+		if _, ok := row.Attr(attributeName); !ok {
+			fmt.Printf("Resource Attribute %s not found for metric: %v \n", attributeName, row.Metric.Name())
+			notFoundCounter++
 		}
 	}
-	fmt.Printf("Counters: Found: %d | Skipped: %d | Not Found: %d\n", foundCounter, skippedCounter, notFoundCounter)
-	return resourceAttributes, notFoundCounter
+	fmt.Printf("Counters: Found: %d | Not Found: %d\n", len(rows)-notFoundCounter, notFoundCounter)
+	return internal.DistinctValues(rows, func(r internal.MetricRow) (string, bool) { return r.Attr(attributeName) }), notFoundCounter
 }
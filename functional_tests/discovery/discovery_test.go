@@ -34,6 +34,7 @@ const (
 func Test_Discovery(t *testing.T) {
 	testKubeConfig, ok := os.LookupEnv("KUBECONFIG")
 	require.True(t, ok, "the environment variable KUBECONFIG must be set")
+	internal.RequireSuite(t, testKubeConfig, Requirements)
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		teardown(t, testKubeConfig)
 	}
@@ -144,19 +145,8 @@ func assertRedisMetrics(t *testing.T, sink *consumertest.MetricsSink) {
 		"redis.uptime",
 	}
 	require.EventuallyWithT(t, func(tt *assert.CollectT) {
-		foundMetrics := make(map[string]bool)
-		for _, m := range sink.AllMetrics() {
-			for i := 0; i < m.ResourceMetrics().Len(); i++ {
-				rm := m.ResourceMetrics().At(i)
-				for j := 0; j < rm.ScopeMetrics().Len(); j++ {
-					sm := rm.ScopeMetrics().At(j)
-					for k := 0; k < sm.Metrics().Len(); k++ {
-						foundMetrics[sm.Metrics().At(k).Name()] = true
-					}
-				}
-			}
-		}
-
+		foundMetrics := internal.DistinctValues(internal.QueryMetrics(sink.AllMetrics(), internal.WithNames(expectedRedisMetrics...)),
+			func(r internal.MetricRow) (string, bool) { return r.Metric.Name(), true })
 		for _, rm := range expectedRedisMetrics {
 			assert.Contains(tt, foundMetrics, rm)
 		}
@@ -16,6 +16,9 @@ import (
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
 )
@@ -111,6 +114,9 @@ func testNodeJSTraces(t *testing.T) {
 		ptracetest.IgnoreScopeSpanInstrumentationScopeVersion(),
 	)
 	require.NoError(t, err)
+
+	internal.AssertTraceStructure(t, []ptrace.Traces{*selectedTrace},
+		internal.WithRequiredSpanAttributes(ptrace.SpanKindServer, "http.method", "http.target", "http.status_code"))
 }
 
 func testPythonTraces(t *testing.T) {
@@ -336,6 +342,27 @@ func testNodeJSMetrics(t *testing.T) {
 	})
 }
 
+// testNodeJSSignalsCorrelated checks that the logs, metrics and traces of the
+// node.js pod carry the same k8s metadata and that its log trace IDs were exported.
+func testNodeJSSignalsCorrelated(t *testing.T) {
+	kubeConfig, err := clientcmd.BuildConfigFromFlags("", requireEnv(t, "KUBECONFIG"))
+	require.NoError(t, err)
+	client, err := kubernetes.NewForConfig(kubeConfig)
+	require.NoError(t, err)
+	pods, err := client.CoreV1().Pods(internal.DefaultNamespace).List(t.Context(), metav1.ListOptions{
+		LabelSelector: "app=nodejs-test",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, pods.Items, "no nodejs-test pod found")
+
+	internal.AssertPodSignalsCorrelated(t, internal.CorrelationSinks{
+		Logs:    []*consumertest.LogsSink{globalSinks.logsConsumer},
+		Metrics: []*consumertest.MetricsSink{globalSinks.agentMetricsConsumer},
+		Traces:  []*consumertest.TracesSink{globalSinks.tracesConsumer},
+	}, internal.PodSelector{UID: string(pods.Items[0].UID)}, 3*time.Minute,
+		internal.SignalLogs, internal.SignalMetrics, internal.SignalTraces)
+}
+
 func testDotNetMetrics(t *testing.T) {
 	checkMetricsFromApp(t, globalSinks.agentMetricsConsumer, "dotnet", "dotnet-test", []string{
 		"process.runtime.dotnet.gc.collections.count",
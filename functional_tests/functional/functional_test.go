@@ -55,6 +55,7 @@ const (
 	valuesDir                              = "values"
 	manifestsDir                           = "manifests"
 	kindValuesDir                          = "expected_kind_values"
+	baseValuesDir                          = "expected_base_values"
 	eksValuesDir                           = "expected_eks_values"
 	eksAutoModeValuesDir                   = "expected_eks_auto_mode_values"
 	aksValuesDir                           = "expected_aks_values"
@@ -429,7 +430,7 @@ func Test_Functions(t *testing.T) {
 		expectedValuesDir = kindValuesDir
 	}
 
-	internal.AcquireLeaseForTest(t, testKubeConfig)
+	internal.RequireSuite(t, testKubeConfig, Requirements)
 
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		teardown(t.Context(), t, testKubeConfig)
@@ -614,6 +615,7 @@ func runLocalClusterTests(t *testing.T, includeK8sClusterReceiverTest bool) {
 	t.Run("Python traces captured", testPythonTraces)
 	t.Run("java metrics captured", testJavaMetrics)
 	t.Run("node.js metrics captured", testNodeJSMetrics)
+	t.Run("node.js signals correlated", testNodeJSSignalsCorrelated)
 	t.Run(".NET metrics captured", testDotNetMetrics)
 	t.Run("Python metrics captured", testPythonMetrics)
 	t.Run("java profiling captured", testJavaProfiling)
@@ -747,18 +749,13 @@ func selectExpectedValuesDir(kubeTestEnv string) string {
 }
 
 func validateResourceAttributes(t *testing.T, clientset *kubernetes.Clientset, kubeConfig *rest.Config, role collectorRole) {
-	var labelSelector, expectedResourceAttributesFile, podPathFile string
+	var labelSelector, podPathFile string
 
 	switch role {
 	case roleAgent:
 		labelSelector = internal.AgentLabelSelector
-		expectedResourceAttributesFile = filepath.Join(testDir, expectedValuesDir, "expected_resource_attributes_agent.yaml")
-	case roleClusterReceiver:
+	case roleClusterReceiver, roleClusterReceiverK8s:
 		labelSelector = clusterReceiverLabelSelector
-		expectedResourceAttributesFile = filepath.Join(testDir, expectedValuesDir, "expected_resource_attributes_cluster_receiver.yaml")
-	case roleClusterReceiverK8s:
-		labelSelector = clusterReceiverLabelSelector
-		expectedResourceAttributesFile = filepath.Join(testDir, expectedValuesDir, "expected_resource_attributes_cluster_receiver_k8s_cluster.yaml")
 	default:
 		require.Failf(t, "failed to run validateResourceAttributes", "unknown role %q", role)
 	}
@@ -787,8 +784,15 @@ func validateResourceAttributes(t *testing.T, clientset *kubernetes.Clientset, k
 
 	internal.CopyFileFromPod(t, clientset, kubeConfig, internal.DefaultNamespace, podName, "otel-collector", podPathFile, tmpFile.Name())
 
+	// Expectations are layered: a shared base file plus an optional per-environment overlay.
+	expectedName := fmt.Sprintf("expected_resource_attributes_%s.yaml", role)
+	baseDir, envDir := filepath.Join(testDir, baseValuesDir), filepath.Join(testDir, expectedValuesDir)
 	skipKeys := []string{"k8s.cluster.name", "cloud.platform"}
-	expectedResourceAttributes := readAndNormalizeMetrics(t, expectedResourceAttributesFile, skipKeys...).ResourceMetrics().At(0).Resource().Attributes()
+	expectedMetrics, err := internal.ReadLayeredMetrics(baseDir, envDir, expectedName)
+	require.NoError(t, err)
+	require.Positive(t, expectedMetrics.ResourceMetrics().Len(), "expected metrics %s contain no ResourceMetrics", expectedName)
+	expectedResourceAttributes := expectedMetrics.ResourceMetrics().At(0).Resource().Attributes()
+	internal.NormalizeAttributes(expectedResourceAttributes, skipKeys...)
 
 	// The k8s_cluster receiver emits multiple ResourceMetrics groups.
 	// We pick a container resource for a stable comparison.
@@ -799,6 +803,11 @@ func validateResourceAttributes(t *testing.T, clientset *kubernetes.Clientset, k
 		actualResourceAttributes = readAndNormalizeMetrics(t, tmpFile.Name(), skipKeys...).ResourceMetrics().At(0).Resource().Attributes()
 	}
 
+	if !expectedResourceAttributes.Equal(actualResourceAttributes) {
+		actualMetrics := pmetric.NewMetrics()
+		actualResourceAttributes.CopyTo(actualMetrics.ResourceMetrics().AppendEmpty().Resource().Attributes())
+		internal.MaybeUpdateExpectedMetricsOverlay(t, baseDir, envDir, expectedName, &actualMetrics)
+	}
 	require.True(t, expectedResourceAttributes.Equal(actualResourceAttributes), "Resource Attributes comparison failed for %s , expected values %s , actual values %s", role, internal.FormatAttributes(expectedResourceAttributes), internal.FormatAttributes(actualResourceAttributes))
 
 	t.Cleanup(func() {
@@ -982,14 +991,10 @@ func testAgentLogs(t *testing.T) {
 		assert.True(t, excludeNs, "excluded namespaces should be ignored")
 	})
 	t.Run("check default metadata is attached to all the logs", func(t *testing.T) {
-		_, ok := helloWorldLogRecord.Attributes().Get("k8s.pod.name")
-		assert.True(t, ok)
-		_, ok = helloWorldLogRecord.Attributes().Get("k8s.namespace.name")
-		assert.True(t, ok)
-		_, ok = helloWorldLogRecord.Attributes().Get("k8s.container.name")
-		assert.True(t, ok)
-		_, ok = helloWorldLogRecord.Attributes().Get("k8s.pod.uid")
-		assert.True(t, ok)
+		internal.AssertAllLogs(t, logsConsumer,
+			`attributes["k8s.pod.name"] != nil and attributes["k8s.namespace.name"] != nil and `+
+				`attributes["k8s.container.name"] != nil and attributes["k8s.pod.uid"] != nil`,
+			internal.WithLogBody("Hello World"))
 	})
 }
 
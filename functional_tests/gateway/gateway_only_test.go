@@ -26,6 +26,7 @@ const (
 
 func Test_GatewayOnly(t *testing.T) {
 	testKubeConfig := getEnvVar(t, "KUBECONFIG")
+	internal.RequireSuite(t, testKubeConfig, Requirements)
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		internal.ChartUninstall(t, testKubeConfig)
 	}
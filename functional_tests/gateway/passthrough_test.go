@@ -26,6 +26,7 @@ const (
 // SKIP_SETUP: if set to true, the test will skip setup
 func Test_TokenPassthrough(t *testing.T) {
 	testKubeConfig := getEnvVar(t, "KUBECONFIG")
+	internal.RequireSuite(t, testKubeConfig, Requirements)
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		internal.ChartUninstall(t, testKubeConfig)
 	}
@@ -81,8 +82,8 @@ func sendTrace(t *testing.T) {
 	data, err := os.ReadFile(filepath.Join(testDir, "trace.json"))
 	require.NoError(t, err)
 
-	// Send to kind cluster exposed port 43180 which is mapped to the container port 4318
-	req, err := http.NewRequest(http.MethodPost, "http://"+internal.HostPort("127.0.0.1", 43180)+"/v1/traces", bytes.NewBuffer(data))
+	// Send to the agent OTLP HTTP hostPort as exposed by the cluster provider (43180 on kind).
+	req, err := http.NewRequest(http.MethodPost, "http://"+internal.ExposedHostPort(t, 4318)+"/v1/traces", bytes.NewBuffer(data))
 	require.NoError(t, err)
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("X-SF-Token", token)
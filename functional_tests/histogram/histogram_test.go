@@ -98,6 +98,7 @@ func (input TestInput) assertionConfig(isHistogram bool) (string, string) {
 }
 
 func Test_ControlPlaneMetrics(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	if k8sVersion := os.Getenv("K8S_VERSION"); k8sVersion != "" {
 		t.Logf("Running control plane metrics assertions against Kubernetes %s", k8sVersion)
 	}
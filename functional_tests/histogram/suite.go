@@ -0,0 +1,12 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package histogram
+
+import "github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+
+var Requirements = internal.SuiteRequirements{
+	Name:             "histogram",
+	Capabilities:     []internal.Capability{internal.CapabilityHostSinks, internal.CapabilityControlPlaneMetrics},
+	ClusterExclusive: true,
+}
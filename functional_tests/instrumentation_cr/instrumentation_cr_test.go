@@ -390,6 +390,7 @@ func assertInjectionWorks(t *testing.T, cs *kubernetes.Clientset, label string)
 }
 
 func TestJobMode(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	cs, dyn := newClients(t)
 	cleanup(t, cs)
 	t.Cleanup(func() { cleanup(t, cs) })
@@ -433,6 +434,7 @@ func TestJobMode(t *testing.T) {
 }
 
 func TestResourceMode(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	cs, dyn := newClients(t)
 	cleanup(t, cs)
 	t.Cleanup(func() { cleanup(t, cs) })
@@ -0,0 +1,12 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package instrumentationcr
+
+import "github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+
+var Requirements = internal.SuiteRequirements{
+	Name:             "instrumentation_cr",
+	Capabilities:     []internal.Capability{internal.CapabilityClusterAdmin},
+	ClusterExclusive: true,
+}
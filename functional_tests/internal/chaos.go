@@ -0,0 +1,461 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	GatewayLabelSelector         = "component=otel-collector"
+	ClusterReceiverLabelSelector = "component=otel-k8s-cluster-receiver"
+)
+
+// Fault disrupts the system under test. Recover undoes it; it is nil for faults the system
+// recovers from on its own, such as a deleted pod its controller recreates.
+type Fault struct {
+	Name    string
+	Inject  func(ctx context.Context) error
+	Recover func(ctx context.Context) error
+}
+
+// DeletePodsFault deletes the pods matching labelSelector. With force the pods are killed
+// without a grace period, like a crash; otherwise they shut down gracefully.
+func DeletePodsFault(clientset kubernetes.Interface, namespace, labelSelector string, force bool) Fault {
+	name := "delete pods " + labelSelector
+	if force {
+		name = "kill pods " + labelSelector
+	}
+	return Fault{
+		Name: name,
+		Inject: func(ctx context.Context) error {
+			opts := metav1.DeleteOptions{}
+			if force {
+				opts.GracePeriodSeconds = ptrInt64(0)
+			}
+			return forEachPod(ctx, clientset, namespace, labelSelector, func(pod *corev1.Pod) error {
+				return clientset.CoreV1().Pods(namespace).Delete(ctx, pod.Name, opts)
+			})
+		},
+	}
+}
+
+// EvictPodsFault evicts the pods matching labelSelector through the eviction API, which
+// honours the PodDisruptionBudgets of the chart, as a node drain would.
+func EvictPodsFault(clientset kubernetes.Interface, namespace, labelSelector string) Fault {
+	return Fault{
+		Name: "evict pods " + labelSelector,
+		Inject: func(ctx context.Context) error {
+			return forEachPod(ctx, clientset, namespace, labelSelector, func(pod *corev1.Pod) error {
+				return clientset.CoreV1().Pods(namespace).EvictV1(ctx, &policyv1.Eviction{
+					ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: namespace},
+				})
+			})
+		},
+	}
+}
+
+func forEachPod(ctx context.Context, clientset kubernetes.Interface, namespace, labelSelector string, fn func(pod *corev1.Pod) error) error {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods match %s in namespace %s", labelSelector, namespace)
+	}
+	var errs []error
+	for i := range pods.Items {
+		if err = fn(&pods.Items[i]); err != nil {
+			errs = append(errs, fmt.Errorf("pod %s: %w", pods.Items[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NodeExecutor runs a command on a node, e.g. ClusterProvider.NodeExec or PodNodeExecutor.
+type NodeExecutor func(ctx context.Context, node string, command ...string) (string, error)
+
+// RestartNodeServiceFault restarts a systemd service such as kubelet or containerd on the node.
+func RestartNodeServiceFault(exec NodeExecutor, node, service string) Fault {
+	return Fault{
+		Name: fmt.Sprintf("restart %s on %s", service, node),
+		Inject: func(ctx context.Context) error {
+			_, err := exec(ctx, node, "systemctl", "restart", service)
+			return err
+		},
+	}
+}
+
+// StopNodeServiceFault stops a systemd service on the node until the fault is recovered.
+func StopNodeServiceFault(exec NodeExecutor, node, service string) Fault {
+	return Fault{
+		Name: fmt.Sprintf("stop %s on %s", service, node),
+		Inject: func(ctx context.Context) error {
+			_, err := exec(ctx, node, "systemctl", "stop", service)
+			return err
+		},
+		Recover: func(ctx context.Context) error {
+			_, err := exec(ctx, node, "systemctl", "start", service)
+			return err
+		},
+	}
+}
+
+// PodNodeExecutor runs node commands from a privileged host PID pod scheduled on the node,
+// through nsenter and ExecInPod. It is the fallback for providers without NodeExec. Prefer
+// the provider for containerd restarts: the exec session itself goes through containerd.
+// The node shells are deleted when the test ends; the executor fails once they are.
+func PodNodeExecutor(t *testing.T, kubeConfig string) NodeExecutor {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
+	require.NoError(t, err)
+	clientset, err := GetKubeClient(kubeConfig)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	// created are the shells to delete, running those ready to exec into, by node.
+	created, running := map[string]string{}, map[string]string{}
+	closed := false
+	// Registered here rather than from the executor, which faults call off the test goroutine.
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		// Use context.Background because t.Context() is already cancelled during t.Cleanup.
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute) //nolint:usetesting
+		defer cancel()
+		for _, podName := range created {
+			_ = clientset.CoreV1().Pods(DefaultNamespace).Delete(cleanupCtx, podName, metav1.DeleteOptions{GracePeriodSeconds: ptrInt64(0)})
+		}
+	})
+	// Faults run outside of the test goroutine, so errors are returned rather than required.
+	return func(ctx context.Context, node string, command ...string) (string, error) {
+		mu.Lock()
+		if closed {
+			mu.Unlock()
+			return "", fmt.Errorf("node executor for %s used after the test ended", node)
+		}
+		podName, ok := running[node]
+		if !ok {
+			podName = "node-shell-" + strings.ReplaceAll(node, ".", "-")
+			created[node] = podName
+			if err := startNodeShell(ctx, clientset, podName, node); err != nil {
+				mu.Unlock()
+				return "", err
+			}
+			running[node] = podName
+		}
+		mu.Unlock()
+		return execInPod(ctx, restConfig, clientset, DefaultNamespace, podName, "shell",
+			append([]string{"nsenter", "-t", "1", "-m", "-u", "-i", "-n", "-p", "--"}, command...))
+	}
+}
+
+func startNodeShell(ctx context.Context, clientset kubernetes.Interface, podName, node string) error {
+	privileged := true
+	pods := clientset.CoreV1().Pods(DefaultNamespace)
+	_, err := pods.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName},
+		Spec: corev1.PodSpec{
+			NodeName:                      node,
+			HostPID:                       true,
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			TerminationGracePeriodSeconds: ptrInt64(0),
+			Tolerations:                   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:            "shell",
+				Image:           fileCopyHelperImage,
+				Command:         []string{"sleep", "3600"},
+				SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+			}},
+		},
+	}, metav1.CreateOptions{})
+	// A shell left over by an interrupted run is reused.
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create node shell on %s: %w", node, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	for {
+		pod, getErr := pods.Get(ctx, podName, metav1.GetOptions{})
+		if getErr == nil && pod.Status.Phase == corev1.PodRunning {
+			return nil
+		}
+		if !sleepCtx(ctx, 2*time.Second) {
+			return fmt.Errorf("node shell on %s is not running: %w", node, ctx.Err())
+		}
+	}
+}
+
+// SinkOutageFault makes the sink behind the proxy unreachable until the fault is recovered.
+func SinkOutageFault(proxy *SinkProxy) Fault {
+	return Fault{
+		Name: "sink outage on port " + strconv.Itoa(proxy.Port()),
+		Inject: func(context.Context) error {
+			return proxy.Cut()
+		},
+		Recover: func(context.Context) error {
+			return proxy.Restore()
+		},
+	}
+}
+
+// SinkProxy forwards a port the chart sends to, to a sink listening on another port, so that
+// the sink can be made unavailable while data is being sent without losing what it received.
+type SinkProxy struct {
+	target   string
+	mu       sync.Mutex
+	port     int
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// StartSinkProxy listens on listenPort, or a free port when 0, and forwards connections to
+// the sink on targetPort. Point the chart at the proxy port and the sink at the target port.
+func StartSinkProxy(t *testing.T, listenPort, targetPort int) *SinkProxy {
+	p := &SinkProxy{
+		target: net.JoinHostPort("127.0.0.1", strconv.Itoa(targetPort)),
+		port:   listenPort,
+		conns:  map[net.Conn]struct{}{},
+	}
+	require.NoError(t, p.Restore())
+	t.Cleanup(func() {
+		_ = p.Cut()
+		p.wg.Wait()
+	})
+	return p
+}
+
+// Port is the port the proxy listens on.
+func (p *SinkProxy) Port() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.port
+}
+
+// Cut closes the listener and every open connection: senders get connection refused or reset.
+func (p *SinkProxy) Cut() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener == nil {
+		return nil
+	}
+	err := p.listener.Close()
+	p.listener = nil
+	for conn := range p.conns {
+		_ = conn.Close()
+	}
+	return err
+}
+
+// Restore listens again after a Cut.
+func (p *SinkProxy) Restore() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener != nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("0.0.0.0", strconv.Itoa(p.port)))
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+	p.port = listener.Addr().(*net.TCPAddr).Port
+	p.wg.Add(1)
+	go p.accept(listener)
+	return nil
+}
+
+func (p *SinkProxy) accept(listener net.Listener) {
+	defer p.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go p.forward(conn)
+	}
+}
+
+func (p *SinkProxy) forward(conn net.Conn) {
+	defer p.wg.Done()
+	upstream, err := net.DialTimeout("tcp", p.target, 5*time.Second)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	if !p.track(conn, upstream) {
+		return
+	}
+	defer p.untrack(conn, upstream)
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	<-done
+}
+
+// track registers the connection pair, or closes it when the proxy was cut meanwhile.
+func (p *SinkProxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener == nil {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		return false
+	}
+	for _, conn := range conns {
+		p.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (p *SinkProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range conns {
+		_ = conn.Close()
+		delete(p.conns, conn)
+	}
+}
+
+// TimedFault schedules a fault relative to the start of the schedule.
+type TimedFault struct {
+	Fault
+	// After is when the fault is injected.
+	After time.Duration
+	// Hold is how long the fault lasts before Recover is called.
+	Hold time.Duration
+}
+
+// FaultEvent is an injection or recovery in a fault schedule.
+type FaultEvent struct {
+	Fault  string
+	Action string // inject or recover
+	At     time.Time
+	Err    error
+}
+
+// FaultSchedule runs timed faults against a running workload.
+type FaultSchedule struct {
+	start  time.Time
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	events []FaultEvent
+}
+
+// StartFaults injects each fault at its time, recovers it after its hold and returns at once.
+// Faults still held when the test ends are recovered during cleanup.
+func StartFaults(t *testing.T, faults ...TimedFault) *FaultSchedule {
+	s := &FaultSchedule{start: time.Now()}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(t.Context()))
+	// Registered before any fault starts, so that a fault injected at once is still recovered.
+	t.Cleanup(func() {
+		cancel()
+		s.wg.Wait()
+	})
+	for _, fault := range faults {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if !sleepCtx(ctx, fault.After) {
+				return
+			}
+			s.record(fault.Name, "inject", fault.Inject(ctx))
+			if fault.Recover == nil {
+				return
+			}
+			// Recover even when the schedule is cancelled, so that no fault outlives the test.
+			sleepCtx(ctx, fault.Hold)
+			s.record(fault.Name, "recover", fault.Recover(context.WithoutCancel(ctx)))
+		}()
+	}
+	return s
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *FaultSchedule) record(fault, action string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, FaultEvent{Fault: fault, Action: action, At: time.Now(), Err: err})
+}
+
+// Wait blocks until every fault was injected and recovered, and fails the test if any
+// injection or recovery failed.
+func (s *FaultSchedule) Wait(t *testing.T) []FaultEvent {
+	t.Helper()
+	s.wg.Wait()
+	events := s.Events()
+	for _, event := range events {
+		require.NoError(t, event.Err, "%s %s", event.Action, event.Fault)
+	}
+	return events
+}
+
+// Events returns the injections and recoveries so far, in order.
+func (s *FaultSchedule) Events() []FaultEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FaultEvent(nil), s.events...)
+}
+
+// Report renders the fault timeline followed by the delivery reconciliation.
+func (s *FaultSchedule) Report(delivery DeliveryReport) string {
+	var out strings.Builder
+	for _, event := range s.Events() {
+		status := "ok"
+		if event.Err != nil {
+			status = event.Err.Error()
+		}
+		fmt.Fprintf(&out, "+%6.1fs %-7s %s: %s\n", event.At.Sub(s.start).Seconds(), event.Action, event.Fault, status)
+	}
+	if lost := delivery.Lost(); lost > 0 {
+		fmt.Fprintf(&out, "%d records lost\n", lost)
+	} else {
+		out.WriteString("no records lost\n")
+	}
+	out.WriteString(delivery.String())
+	return out.String()
+}
+
+// AssertNoLossUnderFaults waits for the schedule to finish, logs its report and fails the
+// test when records were lost or duplicated beyond maxDuplicateRate.
+func AssertNoLossUnderFaults(t *testing.T, s *FaultSchedule, delivery DeliveryReport, maxDuplicateRate float64) {
+	t.Helper()
+	s.Wait(t)
+	t.Logf("Faults and delivery:\n%s", s.Report(delivery))
+	AssertDelivery(t, delivery, maxDuplicateRate)
+}
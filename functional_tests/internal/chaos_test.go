@@ -0,0 +1,160 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeletePodsFault(t *testing.T) {
+	t.Parallel()
+
+	pod := func(name, component string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"component": component}}}
+	}
+	clientset := fake.NewClientset(
+		pod("agent-1", "otel-collector-agent"),
+		pod("agent-2", "otel-collector-agent"),
+		pod("gateway-1", "otel-collector"),
+	)
+
+	require.NoError(t, DeletePodsFault(clientset, "default", AgentLabelSelector, true).Inject(t.Context()))
+	pods, err := clientset.CoreV1().Pods("default").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 1)
+	require.Equal(t, "gateway-1", pods.Items[0].Name)
+
+	require.ErrorContains(t, DeletePodsFault(clientset, "default", ClusterReceiverLabelSelector, false).Inject(t.Context()),
+		"no pods match component=otel-k8s-cluster-receiver")
+}
+
+func TestNodeServiceFaults(t *testing.T) {
+	t.Parallel()
+
+	var commands []string
+	exec := func(_ context.Context, node string, command ...string) (string, error) {
+		commands = append(commands, node+": "+strings.Join(command, " "))
+		return "", nil
+	}
+	require.NoError(t, RestartNodeServiceFault(exec, "kind-worker", "containerd").Inject(t.Context()))
+	stop := StopNodeServiceFault(exec, "kind-worker", "kubelet")
+	require.NoError(t, stop.Inject(t.Context()))
+	require.NoError(t, stop.Recover(t.Context()))
+	require.Equal(t, []string{
+		"kind-worker: systemctl restart containerd",
+		"kind-worker: systemctl stop kubelet",
+		"kind-worker: systemctl start kubelet",
+	}, commands)
+}
+
+func TestSinkProxy(t *testing.T) {
+	t.Parallel()
+
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = echo.Close() })
+	go func() {
+		for {
+			conn, acceptErr := echo.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				_, _ = conn.Write([]byte("echo " + line))
+			}()
+		}
+	}()
+
+	proxy := StartSinkProxy(t, 0, echo.Addr().(*net.TCPAddr).Port)
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(proxy.Port()))
+	roundTrip := func() (string, error) {
+		conn, dialErr := net.DialTimeout("tcp", address, time.Second)
+		if dialErr != nil {
+			return "", dialErr
+		}
+		defer conn.Close()
+		require.NoError(t, conn.SetDeadline(time.Now().Add(2*time.Second)))
+		if _, dialErr = conn.Write([]byte("ping\n")); dialErr != nil {
+			return "", dialErr
+		}
+		return bufio.NewReader(conn).ReadString('\n')
+	}
+
+	reply, err := roundTrip()
+	require.NoError(t, err)
+	require.Equal(t, "echo ping\n", reply)
+
+	outage := SinkOutageFault(proxy)
+	require.NoError(t, outage.Inject(t.Context()))
+	_, err = roundTrip()
+	require.Error(t, err)
+
+	require.NoError(t, outage.Recover(t.Context()))
+	reply, err = roundTrip()
+	require.NoError(t, err)
+	require.Equal(t, "echo ping\n", reply)
+}
+
+func TestStartFaults(t *testing.T) {
+	t.Parallel()
+
+	noop := func(context.Context) error { return nil }
+	schedule := StartFaults(t,
+		TimedFault{Fault: Fault{Name: "outage", Inject: noop, Recover: noop}, After: 10 * time.Millisecond, Hold: 50 * time.Millisecond},
+		TimedFault{Fault: Fault{Name: "kill", Inject: noop}, After: 30 * time.Millisecond},
+	)
+	events := schedule.Wait(t)
+	require.Len(t, events, 3)
+	order := make([]string, len(events))
+	for i, event := range events {
+		order[i] = event.Action + " " + event.Fault
+	}
+	require.Equal(t, []string{"inject outage", "inject kill", "recover outage"}, order)
+
+	report := schedule.Report(ReconcileDelivery(map[string]int{"gen": 3}, []DeliveredRecord{{Stream: "gen", Seq: 0}, {Stream: "gen", Seq: 2}}))
+	require.Contains(t, report, "inject  outage: ok\n")
+	require.Contains(t, report, "1 records lost\n")
+	require.Contains(t, report, "  missing: 1\n")
+
+	failing := StartFaults(t, TimedFault{Fault: Fault{Name: "broken", Inject: func(context.Context) error { return errors.New("boom") }}})
+	failing.wg.Wait()
+	require.EqualError(t, failing.Events()[0].Err, "boom")
+}
+
+func TestStartFaultsRecoversHeldFaultsAtCleanup(t *testing.T) {
+	t.Parallel()
+
+	var recovered atomic.Bool
+	t.Run("held", func(t *testing.T) {
+		injected := make(chan struct{})
+		StartFaults(t, TimedFault{Fault: Fault{
+			Name: "outage",
+			Inject: func(context.Context) error {
+				close(injected)
+				return nil
+			},
+			Recover: func(ctx context.Context) error {
+				recovered.Store(ctx.Err() == nil)
+				return nil
+			},
+		}, Hold: time.Hour})
+		<-injected
+	})
+	require.True(t, recovered.Load())
+}
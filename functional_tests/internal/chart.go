@@ -90,6 +90,8 @@ func ChartInstallOrUpgrade(t *testing.T, testKubeConfig string, valuesFile strin
 	err = yaml.Unmarshal(buf.Bytes(), &values)
 	require.NoError(t, err)
 	applyEnvOverrides(t, values)
+	// Report images missing from an offline archive before helm waits on pods that cannot pull them.
+	PreloadChartImages(t, filepath.Join("..", "..", defaultChartPath), values)
 
 	actionConfig := InitHelmActionConfig(t, testKubeConfig)
 	install := action.NewInstall(actionConfig)
@@ -119,6 +121,8 @@ func ChartInstallOrUpgrade(t *testing.T, testKubeConfig string, valuesFile strin
 		initChart := loadChartFromDir(t, oldChartDir)
 		var initValues map[string]any
 		require.NoError(t, yaml.Unmarshal(initValuesBytes, &initValues))
+		// The base release runs the images of the old chart, which the new one may no longer use.
+		PreloadChartImages(t, oldChartPath, initValues)
 		t.Log("Running helm install of the base release")
 		_, err = install.Run(initChart, initValues)
 		require.NoError(t, err)
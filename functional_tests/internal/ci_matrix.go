@@ -0,0 +1,139 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	// CIMatrixKindWorkflow is the ci-matrix.json entry of the kind based functional test workflow.
+	CIMatrixKindWorkflow = "functional_test_v2"
+	// CIMatrixManagedVersions is the ci-matrix.json entry listing the minor versions each managed
+	// Kubernetes service supports, e.g. {"eks": ["1.35", "1.34"]}.
+	CIMatrixManagedVersions = "managed_k8s_versions"
+)
+
+// CIMatrixEntry is the part of a ci-matrix.json workflow entry the suites act on.
+// Exclude uses the GitHub Actions matrix exclude shape, e.g. {"test-job": "istio", "k8s-kind-version": "v1.34.8"}.
+type CIMatrixEntry struct {
+	KindVersions []string            `json:"k8s-kind-version"`
+	TestJobs     []string            `json:"test-job"`
+	Exclude      []map[string]string `json:"exclude"`
+}
+
+// ReadCIMatrixEntry reads the named workflow entry from a ci-matrix.json file.
+func ReadCIMatrixEntry(path, workflow string) (CIMatrixEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CIMatrixEntry{}, err
+	}
+	var matrix map[string]json.RawMessage
+	if err = json.Unmarshal(data, &matrix); err != nil {
+		return CIMatrixEntry{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	raw, ok := matrix[workflow]
+	if !ok {
+		return CIMatrixEntry{}, fmt.Errorf("%s has no %q entry", path, workflow)
+	}
+	var entry CIMatrixEntry
+	if err = json.Unmarshal(raw, &entry); err != nil {
+		return CIMatrixEntry{}, fmt.Errorf("failed to parse %s entry %q: %w", path, workflow, err)
+	}
+	return entry, nil
+}
+
+// KindVersionsFor returns the kind node versions the suite runs against, in matrix order,
+// with excluded combinations removed.
+func (e CIMatrixEntry) KindVersionsFor(suite string) ([]string, error) {
+	if !slices.Contains(e.TestJobs, suite) {
+		return nil, fmt.Errorf("suite %q is not in the matrix test-job list %v", suite, e.TestJobs)
+	}
+	var versions []string
+	for _, version := range e.KindVersions {
+		if !e.excluded(map[string]string{"test-job": suite, "k8s-kind-version": version}) {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("suite %q is excluded for every kind version", suite)
+	}
+	return versions, nil
+}
+
+// excluded reports whether an exclude rule matches combination. As in GitHub Actions,
+// a rule matches when all of its keys match.
+func (e CIMatrixEntry) excluded(combination map[string]string) bool {
+	for _, rule := range e.Exclude {
+		matches := true
+		for key, value := range rule {
+			if combination[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches && len(rule) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedKubeVersions returns the Kubernetes versions a chart install has to support: the kind
+// node versions, which update_k8s_versions keeps to the latest patch of every supported minor
+// version, and the x.y.0 version of the older minor versions managed services still support.
+// The versions are sorted from the newest.
+func SupportedKubeVersions(path string) ([]string, error) {
+	entry, err := ReadCIMatrixEntry(path, CIMatrixKindWorkflow)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var matrix struct {
+		Managed map[string][]string `json:"managed_k8s_versions"`
+	}
+	if err = json.Unmarshal(data, &matrix); err != nil {
+		return nil, fmt.Errorf("failed to parse %s entry %q: %w", path, CIMatrixManagedVersions, err)
+	}
+
+	versions := slices.Clone(entry.KindVersions)
+	covered := map[string]bool{}
+	for _, version := range versions {
+		covered[minorOf(version)] = true
+	}
+	for _, minors := range matrix.Managed {
+		for _, minor := range minors {
+			if !covered[minor] {
+				covered[minor] = true
+				versions = append(versions, "v"+minor+".0")
+			}
+		}
+	}
+	parsed := make(map[string]*semver.Version, len(versions))
+	for _, version := range versions {
+		if parsed[version], err = semver.NewVersion(version); err != nil {
+			return nil, fmt.Errorf("invalid Kubernetes version %q in %s: %w", version, path, err)
+		}
+	}
+	slices.SortFunc(versions, func(a, b string) int { return parsed[b].Compare(parsed[a]) })
+	return versions, nil
+}
+
+// minorOf returns the minor version of a version such as v1.34.8, i.e. 1.34.
+func minorOf(version string) string {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
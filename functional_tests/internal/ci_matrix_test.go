@@ -0,0 +1,90 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIMatrixKindVersionsFor(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ci-matrix.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "functional_test_v2": {
+    "k8s-kind-version": ["v1.36.1", "v1.35.5", "v1.34.8"],
+    "test-job": ["functional", "istio", "obi"],
+    "exclude": [
+      {"test-job": "istio", "k8s-kind-version": "v1.34.8"},
+      {"test-job": "obi"}
+    ]
+  },
+  "kubeconform_tests": {"k8s-version": ["1.33.1"]}
+}`), 0o600))
+
+	entry, err := ReadCIMatrixEntry(path, CIMatrixKindWorkflow)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		suite   string
+		want    []string
+		wantErr string
+	}{
+		{suite: "functional", want: []string{"v1.36.1", "v1.35.5", "v1.34.8"}},
+		{suite: "istio", want: []string{"v1.36.1", "v1.35.5"}},
+		{suite: "obi", wantErr: `suite "obi" is excluded for every kind version`},
+		{suite: "histogram", wantErr: `suite "histogram" is not in the matrix test-job list`},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.suite, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := entry.KindVersionsFor(testCase.suite)
+			if testCase.wantErr != "" {
+				require.ErrorContains(t, err, testCase.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.want, got)
+		})
+	}
+
+	_, err = ReadCIMatrixEntry(path, "migration_tests")
+	require.ErrorContains(t, err, `has no "migration_tests" entry`)
+}
+
+func TestSupportedKubeVersions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ci-matrix.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "functional_test_v2": {"k8s-kind-version": ["v1.34.8", "v1.36.1", "v1.35.5"], "test-job": ["functional"]},
+  "managed_k8s_versions": {"aks": ["1.36", "1.35", "1.34", "1.33"], "eks": ["1.35", "1.34", "1.33", "1.32"]}
+}`), 0o600))
+	versions, err := SupportedKubeVersions(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1.36.1", "v1.35.5", "v1.34.8", "v1.33.0", "v1.32.0"}, versions)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"functional_test_v2": {"k8s-kind-version": ["v1.36.1"]}}`), 0o600))
+	versions, err = SupportedKubeVersions(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1.36.1"}, versions)
+}
+
+func TestRepositoryCIMatrixSuitesExist(t *testing.T) {
+	t.Parallel()
+
+	entry, err := ReadCIMatrixEntry(filepath.Join("..", "..", "ci-matrix.json"), CIMatrixKindWorkflow)
+	require.NoError(t, err)
+	require.NotEmpty(t, entry.KindVersions)
+	for _, suite := range entry.TestJobs {
+		info, err := os.Stat(filepath.Join("..", suite))
+		require.NoError(t, err, "matrix suite %s has no directory", suite)
+		require.True(t, info.IsDir())
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/network"
+	docker "github.com/moby/moby/client"
+	"github.com/stretchr/testify/require"
+)
+
+// Cluster provider names accepted by the CLUSTER_PROVIDER env var.
+const (
+	ClusterProviderKind       = "kind"
+	ClusterProviderK3d        = "k3d"
+	ClusterProviderMinikube   = "minikube"
+	ClusterProviderKubeconfig = "kubeconfig"
+)
+
+// ErrClusterProviderUnsupported is returned when a provider cannot perform an operation,
+// e.g. loading images into an existing remote cluster.
+var ErrClusterProviderUnsupported = errors.New("operation not supported by cluster provider")
+
+// ClusterProvider hides how the local test cluster is run. It answers the questions the
+// suites used to hard-code for kind: how pods reach sinks on the host, how the host reaches
+// ports exposed on nodes, how images get into the cluster and how to run commands on a node.
+type ClusterProvider interface {
+	// Name returns the provider name, one of the ClusterProvider* constants.
+	Name() string
+	// HostEndpoint returns the address pods use to reach listeners on the test host.
+	HostEndpoint(ctx context.Context) (string, error)
+	// ExposedAddress returns the host:port the test host uses to reach port on the cluster nodes,
+	// e.g. an agent hostPort.
+	ExposedAddress(ctx context.Context, port int) (string, error)
+	// LoadImages makes locally available images usable by the cluster without pulling.
+	LoadImages(ctx context.Context, images ...string) error
+	// LoadImageArchives loads docker-archive or OCI archive tarballs into the cluster.
+	LoadImageArchives(ctx context.Context, paths ...string) error
+	// NodeExec runs command on the named node and returns its combined output.
+	NodeExec(ctx context.Context, node string, command ...string) (string, error)
+}
+
+// NewClusterProvider returns the provider registered under name. An empty name selects kind.
+func NewClusterProvider(name string) (ClusterProvider, error) {
+	switch name {
+	case "", ClusterProviderKind:
+		return NewKindClusterProvider(envOrDefault("KIND_CLUSTER_NAME", "kind")), nil
+	case ClusterProviderK3d:
+		return &k3dProvider{cluster: envOrDefault("K3D_CLUSTER_NAME", "k3s-default")}, nil
+	case ClusterProviderMinikube:
+		return &minikubeProvider{profile: envOrDefault("MINIKUBE_PROFILE", "minikube")}, nil
+	case ClusterProviderKubeconfig:
+		return &kubeconfigProvider{
+			hostEndpoint: os.Getenv("HOST_ENDPOINT"),
+			nodeHost:     os.Getenv("NODE_HOST"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown cluster provider %q", name)
+	}
+}
+
+// DefaultClusterProvider returns the provider selected by the CLUSTER_PROVIDER env var.
+func DefaultClusterProvider(t *testing.T) ClusterProvider {
+	provider, err := NewClusterProvider(os.Getenv("CLUSTER_PROVIDER"))
+	require.NoError(t, err)
+	return provider
+}
+
+// ExposedHostPort returns the host:port reaching port on the cluster nodes of the default provider.
+func ExposedHostPort(t *testing.T, port int) string {
+	ctx, cancel := context.WithTimeout(t.Context(), 30*time.Second)
+	defer cancel()
+	addr, err := DefaultClusterProvider(t).ExposedAddress(ctx, port)
+	require.NoError(t, err)
+	return addr
+}
+
+// LoadImages loads images into the cluster of the default provider.
+func LoadImages(t *testing.T, images ...string) {
+	require.NoError(t, DefaultClusterProvider(t).LoadImages(t.Context(), images...))
+}
+
+// NodeExec runs command on node through the default provider and returns its output.
+func NodeExec(t *testing.T, node string, command ...string) string {
+	out, err := DefaultClusterProvider(t).NodeExec(t.Context(), node, command...)
+	require.NoError(t, err)
+	return out
+}
+
+type kindProvider struct {
+	cluster string
+}
+
+// NewKindClusterProvider returns the kind provider for the named cluster.
+func NewKindClusterProvider(cluster string) ClusterProvider {
+	return &kindProvider{cluster: cluster}
+}
+
+func (*kindProvider) Name() string { return ClusterProviderKind }
+
+func (*kindProvider) HostEndpoint(ctx context.Context) (string, error) {
+	if runtime.GOOS == "darwin" {
+		return "host.docker.internal", nil
+	}
+	return dockerNetworkGateway(ctx, "kind")
+}
+
+// ExposedAddress resolves the extraPortMappings of the control-plane container.
+func (p *kindProvider) ExposedAddress(ctx context.Context, port int) (string, error) {
+	return dockerPublishedAddress(ctx, p.cluster+"-control-plane", port)
+}
+
+func (p *kindProvider) LoadImages(ctx context.Context, images ...string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	return runCommand(ctx, "kind", append([]string{"load", "docker-image", "--name", p.cluster}, images...)...)
+}
+
+func (p *kindProvider) LoadImageArchives(ctx context.Context, paths ...string) error {
+	for _, path := range paths {
+		if err := runCommand(ctx, "kind", "load", "image-archive", path, "--name", p.cluster); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NodeExec relies on kind node names matching their container names.
+func (*kindProvider) NodeExec(ctx context.Context, node string, command ...string) (string, error) {
+	return commandOutput(ctx, "docker", append([]string{"exec", node}, command...)...)
+}
+
+type k3dProvider struct {
+	cluster string
+}
+
+func (*k3dProvider) Name() string { return ClusterProviderK3d }
+
+// HostEndpoint uses the name k3d injects into CoreDNS for the host.
+func (*k3dProvider) HostEndpoint(context.Context) (string, error) {
+	return "host.k3d.internal", nil
+}
+
+// ExposedAddress resolves ports published on the server load balancer, e.g. with
+// `k3d cluster create -p "43180:4318@loadbalancer"`.
+func (p *k3dProvider) ExposedAddress(ctx context.Context, port int) (string, error) {
+	return dockerPublishedAddress(ctx, "k3d-"+p.cluster+"-serverlb", port)
+}
+
+func (p *k3dProvider) LoadImages(ctx context.Context, images ...string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	return runCommand(ctx, "k3d", append([]string{"image", "import", "--cluster", p.cluster}, images...)...)
+}
+
+// LoadImageArchives relies on k3d image import accepting tarballs as well as image names.
+func (p *k3dProvider) LoadImageArchives(ctx context.Context, paths ...string) error {
+	return p.LoadImages(ctx, paths...)
+}
+
+func (*k3dProvider) NodeExec(ctx context.Context, node string, command ...string) (string, error) {
+	return commandOutput(ctx, "docker", append([]string{"exec", node}, command...)...)
+}
+
+type minikubeProvider struct {
+	profile string
+}
+
+func (*minikubeProvider) Name() string { return ClusterProviderMinikube }
+
+// HostEndpoint uses the name minikube adds to the node hosts file and CoreDNS.
+func (*minikubeProvider) HostEndpoint(context.Context) (string, error) {
+	return "host.minikube.internal", nil
+}
+
+// ExposedAddress reaches hostPorts directly on the minikube node IP.
+func (p *minikubeProvider) ExposedAddress(ctx context.Context, port int) (string, error) {
+	ip, err := commandOutput(ctx, "minikube", "ip", "--profile", p.profile)
+	if err != nil {
+		return "", err
+	}
+	return HostPort(strings.TrimSpace(ip), port), nil
+}
+
+func (p *minikubeProvider) LoadImages(ctx context.Context, images ...string) error {
+	for _, image := range images {
+		if err := runCommand(ctx, "minikube", "image", "load", "--profile", p.profile, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadImageArchives relies on minikube image load accepting tarballs as well as image names.
+func (p *minikubeProvider) LoadImageArchives(ctx context.Context, paths ...string) error {
+	return p.LoadImages(ctx, paths...)
+}
+
+func (p *minikubeProvider) NodeExec(ctx context.Context, node string, command ...string) (string, error) {
+	return commandOutput(ctx, "minikube", append([]string{"ssh", "--profile", p.profile, "--node", node, "--"}, command...)...)
+}
+
+// kubeconfigProvider targets an existing cluster that the suites cannot manage.
+// HOST_ENDPOINT and NODE_HOST describe how the cluster and the test host reach each other.
+type kubeconfigProvider struct {
+	hostEndpoint string
+	nodeHost     string
+}
+
+func (*kubeconfigProvider) Name() string { return ClusterProviderKubeconfig }
+
+func (p *kubeconfigProvider) HostEndpoint(context.Context) (string, error) {
+	if p.hostEndpoint == "" {
+		return "", errors.New("HOST_ENDPOINT must be set for the kubeconfig cluster provider")
+	}
+	return p.hostEndpoint, nil
+}
+
+func (p *kubeconfigProvider) ExposedAddress(_ context.Context, port int) (string, error) {
+	if p.nodeHost == "" {
+		return "", errors.New("NODE_HOST must be set for the kubeconfig cluster provider")
+	}
+	return HostPort(p.nodeHost, port), nil
+}
+
+func (*kubeconfigProvider) LoadImages(_ context.Context, images ...string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	return fmt.Errorf("loading images: %w", ErrClusterProviderUnsupported)
+}
+
+func (*kubeconfigProvider) LoadImageArchives(_ context.Context, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return fmt.Errorf("loading image archives: %w", ErrClusterProviderUnsupported)
+}
+
+func (*kubeconfigProvider) NodeExec(context.Context, string, ...string) (string, error) {
+	return "", fmt.Errorf("node exec: %w", ErrClusterProviderUnsupported)
+}
+
+func dockerNetworkGateway(ctx context.Context, name string) (string, error) {
+	client, err := docker.New(docker.FromEnv)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	netInfo, err := client.NetworkInspect(ctx, name, docker.NetworkInspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect docker network %s: %w", name, err)
+	}
+	// Prefer IPv4 gateway (e.g. on GitHub runners Docker/kind may expose IPv6 first).
+	var fallback string
+	for _, ipam := range netInfo.Network.IPAM.Config {
+		if !ipam.Gateway.IsValid() {
+			continue
+		}
+		if ipam.Gateway.Is4() {
+			return ipam.Gateway.String(), nil
+		}
+		if fallback == "" {
+			fallback = ipam.Gateway.String()
+		}
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("docker network %s has no gateway", name)
+}
+
+func dockerPublishedAddress(ctx context.Context, containerName string, port int) (string, error) {
+	client, err := docker.New(docker.FromEnv)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	res, err := client.ContainerInspect(ctx, containerName, docker.ContainerInspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+	if res.Container.NetworkSettings == nil {
+		return "", fmt.Errorf("container %s has no network settings", containerName)
+	}
+	addr, ok := publishedAddress(res.Container.NetworkSettings.Ports, port)
+	if !ok {
+		return "", fmt.Errorf("port %d/tcp is not published by container %s", port, containerName)
+	}
+	return addr, nil
+}
+
+// publishedAddress picks the host binding of port/tcp, preferring IPv4. Wildcard
+// bindings are reached through loopback.
+func publishedAddress(ports network.PortMap, port int) (string, bool) {
+	if port <= 0 || port > 65535 {
+		return "", false
+	}
+	key, ok := network.PortFrom(uint16(port), network.TCP)
+	if !ok {
+		return "", false
+	}
+	var fallback string
+	for _, binding := range ports[key] {
+		host := binding.HostIP
+		if !host.IsValid() || host.IsUnspecified() {
+			host = netip.MustParseAddr("127.0.0.1")
+			if binding.HostIP.Is6() {
+				host = netip.IPv6Loopback()
+			}
+		}
+		addr := net.JoinHostPort(host.String(), binding.HostPort)
+		if host.Is4() {
+			return addr, true
+		}
+		if fallback == "" {
+			fallback = addr
+		}
+	}
+	return fallback, fallback != ""
+}
+
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func runCommand(ctx context.Context, name string, args ...string) error {
+	_, err := commandOutput(ctx, name, args...)
+	return err
+}
+
+func commandOutput(ctx context.Context, name string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("%s %s failed: %w (output: %s)", name, strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
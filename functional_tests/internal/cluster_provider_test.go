@@ -0,0 +1,94 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/moby/moby/api/types/network"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishedAddress(t *testing.T) {
+	t.Parallel()
+
+	tcp4318 := network.MustParsePort("4318/tcp")
+	testCases := []struct {
+		name     string
+		bindings []network.PortBinding
+		want     string
+		wantOK   bool
+	}{
+		{
+			name:     "wildcard binding uses loopback",
+			bindings: []network.PortBinding{{HostPort: "43180"}},
+			want:     "127.0.0.1:43180",
+			wantOK:   true,
+		},
+		{
+			name: "ipv4 preferred over ipv6",
+			bindings: []network.PortBinding{
+				{HostIP: netip.MustParseAddr("::"), HostPort: "43181"},
+				{HostIP: netip.MustParseAddr("0.0.0.0"), HostPort: "43180"},
+			},
+			want:   "127.0.0.1:43180",
+			wantOK: true,
+		},
+		{
+			name:     "ipv6 only",
+			bindings: []network.PortBinding{{HostIP: netip.MustParseAddr("::"), HostPort: "43180"}},
+			want:     "[::1]:43180",
+			wantOK:   true,
+		},
+		{
+			name:     "explicit host ip kept",
+			bindings: []network.PortBinding{{HostIP: netip.MustParseAddr("192.168.1.10"), HostPort: "43180"}},
+			want:     "192.168.1.10:43180",
+			wantOK:   true,
+		},
+		{
+			name: "not published",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := publishedAddress(network.PortMap{tcp4318: testCase.bindings}, 4318)
+			require.Equal(t, testCase.wantOK, ok)
+			require.Equal(t, testCase.want, got)
+		})
+	}
+}
+
+func TestNewClusterProvider(t *testing.T) {
+	t.Setenv("HOST_ENDPOINT", "10.0.0.1")
+	t.Setenv("NODE_HOST", "10.0.0.2")
+
+	for _, name := range []string{ClusterProviderKind, ClusterProviderK3d, ClusterProviderMinikube, ClusterProviderKubeconfig} {
+		provider, err := NewClusterProvider(name)
+		require.NoError(t, err)
+		require.Equal(t, name, provider.Name())
+	}
+	provider, err := NewClusterProvider("")
+	require.NoError(t, err)
+	require.Equal(t, ClusterProviderKind, provider.Name())
+	_, err = NewClusterProvider("docker-desktop")
+	require.ErrorContains(t, err, `unknown cluster provider "docker-desktop"`)
+
+	provider, err = NewClusterProvider(ClusterProviderKubeconfig)
+	require.NoError(t, err)
+	ctx := t.Context()
+	host, err := provider.HostEndpoint(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", host)
+	addr, err := provider.ExposedAddress(ctx, 4318)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.2:4318", addr)
+	require.ErrorIs(t, provider.LoadImages(ctx, "busybox"), ErrClusterProviderUnsupported)
+	_, err = provider.NodeExec(ctx, "node", "true")
+	require.ErrorIs(t, err, ErrClusterProviderUnsupported)
+}
@@ -11,13 +11,11 @@ import (
 	"net"
 	"os"
 	"path"
-	"runtime"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	docker "github.com/moby/moby/client"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/golden"
 	k8stest "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/xk8stest"
 	"github.com/stretchr/testify/require"
@@ -52,38 +50,15 @@ const (
 	winControlCExitCode = "3221225786"
 )
 
+// HostEndpoint returns the address pods use to reach sinks on the test host.
+// HOST_ENDPOINT overrides the value resolved by the cluster provider.
 func HostEndpoint(t *testing.T) string {
 	if host, ok := os.LookupEnv("HOST_ENDPOINT"); ok {
 		return host
 	}
-	if runtime.GOOS == "darwin" {
-		return "host.docker.internal"
-	}
-
-	client, err := docker.New(docker.FromEnv)
-	require.NoError(t, err)
-	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
-	defer cancel()
-	netInfo, err := client.NetworkInspect(ctx, "kind", docker.NetworkInspectOptions{})
-	require.NoError(t, err)
-	// Prefer IPv4 gateway (e.g. on GitHub runners Docker/kind may expose IPv6 first).
-	var fallback string
-	for _, ipam := range netInfo.Network.IPAM.Config {
-		if !ipam.Gateway.IsValid() {
-			continue
-		}
-		if ipam.Gateway.Is4() {
-			return ipam.Gateway.String()
-		}
-		if fallback == "" {
-			fallback = ipam.Gateway.String()
-		}
-	}
-	if fallback != "" {
-		return fallback
-	}
-	require.Fail(t, "failed to find host endpoint")
-	return ""
+	host, err := DefaultClusterProvider(t).HostEndpoint(t.Context())
+	require.NoError(t, err, "failed to find host endpoint")
+	return host
 }
 
 // HostPort returns "host:port" with correct bracketing for IPv6 (e.g. "[::1]:4317").
@@ -211,7 +186,7 @@ func CopyFileToPod(t *testing.T, clientset *kubernetes.Clientset, config *rest.C
 			return false
 		}
 		defer localFile.Close()
-		if err = streamExec(t, config, clientset, namespace, podName, helper, command, localFile, io.Discard); err != nil {
+		if err = streamExec(t.Context(), config, clientset, namespace, podName, helper, command, localFile, io.Discard); err != nil {
 			t.Logf("retrying copy of %s to pod %s: %v", localFilePath, podName, err)
 			return false
 		}
@@ -242,7 +217,7 @@ func CopyFileFromPod(t *testing.T, clientset *kubernetes.Clientset, config *rest
 			return false
 		}
 		defer localFile.Close()
-		if err = streamExec(t, config, clientset, namespace, podName, execContainer, command, nil, localFile); err != nil {
+		if err = streamExec(t.Context(), config, clientset, namespace, podName, execContainer, command, nil, localFile); err != nil {
 			info, statErr := localFile.Stat()
 			if isWindows && strings.Contains(err.Error(), winControlCExitCode) && statErr == nil && info.Size() > 0 {
 				t.Logf("ignoring benign Windows exit code error while streaming %s from pod: %v", podFilePath, err)
@@ -355,15 +330,22 @@ func findVolumeMountForPath(t *testing.T, pod *v1.Pod, containerName, absPath st
 // are surfaced via the error return; the caller decides whether to require.NoError.
 func ExecInPod(t *testing.T, config *rest.Config, clientset *kubernetes.Clientset,
 	namespace, podName, containerName string, command []string,
+) (string, error) {
+	return execInPod(t.Context(), config, clientset, namespace, podName, containerName, command)
+}
+
+// execInPod is ExecInPod bound to ctx instead of the test, for callers off the test goroutine.
+func execInPod(ctx context.Context, config *rest.Config, clientset *kubernetes.Clientset,
+	namespace, podName, containerName string, command []string,
 ) (string, error) {
 	var stdout bytes.Buffer
-	err := streamExec(t, config, clientset, namespace, podName, containerName, command, nil, &stdout)
+	err := streamExec(ctx, config, clientset, namespace, podName, containerName, command, nil, &stdout)
 	return stdout.String(), err
 }
 
 // streamExec runs command in the given pod/container, wiring optional stdin and
 // stdout. stderr is captured and folded into the returned error for debugging.
-func streamExec(t *testing.T, config *rest.Config, clientset *kubernetes.Clientset,
+func streamExec(ctx context.Context, config *rest.Config, clientset *kubernetes.Clientset,
 	namespace, podName, containerName string, command []string, stdin io.Reader, stdout io.Writer,
 ) error {
 	req := clientset.CoreV1().RESTClient().Post().
@@ -385,7 +367,7 @@ func streamExec(t *testing.T, config *rest.Config, clientset *kubernetes.Clients
 	}
 
 	var stderr bytes.Buffer
-	if err = exec.StreamWithContext(t.Context(), remotecommand.StreamOptions{
+	if err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdin:  stdin,
 		Stdout: stdout,
 		Stderr: &stderr,
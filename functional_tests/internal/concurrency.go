@@ -5,18 +5,23 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/leaderelection"
-	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
 const (
@@ -24,87 +29,560 @@ const (
 	leaseNamespace = "default"
 
 	leaseDuration = 60 * time.Second
-	renewDeadline = 40 * time.Second
 	retryPeriod   = 5 * time.Second
+
+	// DefaultLeaseScope is the scope of the cluster-wide lease every cluster-exclusive suite
+	// takes unless it names another scope.
+	DefaultLeaseScope = "cluster"
+	// DefaultLeaseMaxHold is how long a holder may keep a lease before waiters break it.
+	DefaultLeaseMaxHold = 90 * time.Minute
+
+	// leaseLabel marks the leases managed here, so they can be listed.
+	leaseLabel = "splunk.com/functional-test-lease"
+	// leaseScopeAnnotation is the scope the lease guards.
+	leaseScopeAnnotation = "splunk.com/functional-test-lease-scope"
+	// leaseQueueAnnotation holds the waiters in arrival order, as JSON.
+	leaseQueueAnnotation = "splunk.com/functional-test-lease-queue"
+	// leaseMaxHoldAnnotation is the maximum hold the current holder asked for.
+	leaseMaxHoldAnnotation = "splunk.com/functional-test-lease-max-hold"
 )
 
+// LeaseHolderEnv is set by the suite runner for the go test processes it runs while it
+// holds the cluster lease on their behalf.
+const LeaseHolderEnv = "FUNCTIONAL_TEST_LEASE_HOLDER"
+
+type leaseConfig struct {
+	scope       string
+	maxHold     time.Duration
+	retryPeriod time.Duration
+	onLost      func()
+	now         func() time.Time
+}
+
+// LeaseOption configures AcquireLease.
+type LeaseOption func(*leaseConfig)
+
+// WithLeaseScope takes the lease of a named scope such as "operator-crds" or "node-logs"
+// instead of the cluster-wide one. Leases of different named scopes do not exclude each
+// other, but every named scope excludes the cluster-wide lease and is excluded by it.
+func WithLeaseScope(scope string) LeaseOption {
+	return func(cfg *leaseConfig) {
+		if scope != "" {
+			cfg.scope = scope
+		}
+	}
+}
+
+// WithLeaseMaxHold bounds how long the lease is held. Once it is exceeded, the first waiter
+// breaks the lease even though the holder still renews it.
+func WithLeaseMaxHold(maxHold time.Duration) LeaseOption {
+	return func(cfg *leaseConfig) {
+		cfg.maxHold = maxHold
+	}
+}
+
+// WithLeaseLost calls onLost when another holder takes over or breaks the lease while it is
+// held, e.g. to cancel the work the lease guards. It is called at most once, before release.
+func WithLeaseLost(onLost func()) LeaseOption {
+	return func(cfg *leaseConfig) {
+		cfg.onLost = onLost
+	}
+}
+
+func newLeaseConfig(opts []LeaseOption) leaseConfig {
+	cfg := leaseConfig{
+		scope:       DefaultLeaseScope,
+		maxHold:     DefaultLeaseMaxHold,
+		retryPeriod: retryPeriod,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// LeaseName returns the name of the Lease object guarding scope.
+func LeaseName(scope string) string {
+	if scope == "" || scope == DefaultLeaseScope {
+		return leaseName
+	}
+	return leaseName + "-" + scope
+}
+
+// LeaseWaiter is a process queued for a lease.
+type LeaseWaiter struct {
+	Identity string    `json:"identity"`
+	Since    time.Time `json:"since"`
+	// Seen is refreshed on every attempt; waiters not seen for a lease duration are dropped.
+	Seen time.Time `json:"seen"`
+}
+
+// LeaseStatus is the state of a lease as recorded on its Lease object.
+type LeaseStatus struct {
+	Scope    string
+	Holder   string
+	Acquired time.Time
+	Renewed  time.Time
+	MaxHold  time.Duration
+	Queue    []LeaseWaiter
+	// blockedBy is why a waiter first in the queue could not take the free lease, set by
+	// tryAcquireLease when a lease of another scope excludes it.
+	blockedBy string
+}
+
+// Expired reports whether the lease is free because nobody holds it or the holder stopped renewing it.
+func (s LeaseStatus) Expired(now time.Time) bool {
+	return s.Holder == "" || now.Sub(s.Renewed) > leaseDuration
+}
+
+// Overdue reports whether the holder has kept the lease longer than its maximum hold.
+func (s LeaseStatus) Overdue(now time.Time) bool {
+	return s.Holder != "" && s.MaxHold > 0 && now.Sub(s.Acquired) > s.MaxHold
+}
+
+func leaseStatus(lease *coordinationv1.Lease) (LeaseStatus, error) {
+	status := LeaseStatus{Scope: lease.Annotations[leaseScopeAnnotation]}
+	if lease.Spec.HolderIdentity != nil {
+		status.Holder = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.AcquireTime != nil {
+		status.Acquired = lease.Spec.AcquireTime.Time
+	}
+	if lease.Spec.RenewTime != nil {
+		status.Renewed = lease.Spec.RenewTime.Time
+	}
+	if value := lease.Annotations[leaseMaxHoldAnnotation]; value != "" {
+		maxHold, err := time.ParseDuration(value)
+		if err != nil {
+			return status, fmt.Errorf("lease %s: invalid max hold %q: %w", lease.Name, value, err)
+		}
+		status.MaxHold = maxHold
+	}
+	if value := lease.Annotations[leaseQueueAnnotation]; value != "" {
+		if err := json.Unmarshal([]byte(value), &status.Queue); err != nil {
+			return status, fmt.Errorf("lease %s: invalid queue: %w", lease.Name, err)
+		}
+	}
+	return status, nil
+}
+
+func setLeaseQueue(lease *coordinationv1.Lease, queue []LeaseWaiter) {
+	if len(queue) == 0 {
+		delete(lease.Annotations, leaseQueueAnnotation)
+		return
+	}
+	data, _ := json.Marshal(queue)
+	lease.Annotations[leaseQueueAnnotation] = string(data)
+}
+
+// ListLeases returns the status of every functional test lease in the cluster, sorted by scope.
+func ListLeases(ctx context.Context, clientset kubernetes.Interface) ([]LeaseStatus, error) {
+	leases, err := clientset.CoordinationV1().Leases(leaseNamespace).List(ctx, metav1.ListOptions{LabelSelector: leaseLabel + "=true"})
+	if err != nil {
+		return nil, err
+	}
+	var statuses []LeaseStatus
+	for i := range leases.Items {
+		status, err := leaseStatus(&leases.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Scope < statuses[j].Scope })
+	return statuses, nil
+}
+
 // AcquireLeaseForTest acquires (and holds) a cluster-wide lease for the duration of the
 // current test. Once acquired, it registers a t.Cleanup function that will release the
 // lease after the test completes (including any other Cleanup functions).
-func AcquireLeaseForTest(t *testing.T, testKubeConfig string) {
-	kubeConfig, err := clientcmd.BuildConfigFromFlags("", testKubeConfig)
+func AcquireLeaseForTest(t *testing.T, testKubeConfig string, opts ...LeaseOption) {
+	if holder := os.Getenv(LeaseHolderEnv); holder != "" {
+		t.Logf("The lease is held by the suite runner as %s", holder)
+		return
+	}
+
+	// Generate an identity for the test execution
+	hostname, err := os.Hostname()
+	_, filename, _, _ := runtime.Caller(2)
 	require.NoError(t, err)
+	holderIdentity := fmt.Sprintf("%s:%s:%s:%d", hostname, filename, t.Name(), time.Now().UnixNano())
+
+	opts = append(opts, WithLeaseLost(func() {
+		t.Errorf("Lost the lease held as %s: another suite may have changed the cluster during the test", holderIdentity)
+	}))
+	release, err := AcquireLease(t.Context(), testKubeConfig, holderIdentity, t.Logf, opts...)
+	if err != nil {
+		t.Fatalf("Failed to acquire lease: %v", err)
+	}
+	t.Logf("Acquired the lease as %s", holderIdentity)
+
+	// We hold the lease. Register a cleanup to release it when the test is fully done.
+	t.Cleanup(func() {
+		t.Log("Releasing cluster-wide lock...")
+		release()
+		t.Log("Cluster-wide lock released.")
+	})
+}
+
+// AcquireLease blocks until holderIdentity holds the lease or ctx is done. Waiters queue on
+// the Lease object and are served in arrival order; a holder that stops renewing, or that
+// exceeds its maximum hold, loses the lease to the first waiter. The lease is renewed in the
+// background until the returned release function is called; release returns once the lease
+// is given up.
+func AcquireLease(ctx context.Context, testKubeConfig, holderIdentity string, logf func(format string, args ...any), opts ...LeaseOption) (func(), error) {
+	kubeConfig, err := clientcmd.BuildConfigFromFlags("", testKubeConfig)
+	if err != nil {
+		return nil, err
+	}
 	// increase from the default of 5/10 to avoid rate limiting error noticed in K8s 1.35+
 	kubeConfig.QPS = 50
 	kubeConfig.Burst = 100
 	kubeConfig.Timeout = 20 * time.Second
 	client, err := kubernetes.NewForConfig(kubeConfig)
-	require.NoError(t, err)
+	if err != nil {
+		return nil, err
+	}
+	return acquireLease(ctx, client, holderIdentity, logf, newLeaseConfig(opts))
+}
 
-	// Generate an identity for the test execution
-	hostname, err := os.Hostname()
-	_, filename, _, _ := runtime.Caller(2)
-	require.NoError(t, err)
-	holderIdentity := fmt.Sprintf("%s:%s:%s:%d", hostname, filename, t.Name(), time.Now().UnixNano())
+func acquireLease(ctx context.Context, clientset kubernetes.Interface, holderIdentity string, logf func(format string, args ...any), cfg leaseConfig) (func(), error) {
+	lastReport := ""
+	for {
+		acquired, status, err := tryAcquireLease(ctx, clientset, holderIdentity, logf, cfg)
+		switch {
+		case err != nil:
+			logf("Failed to update the lease %s: %v", LeaseName(cfg.scope), err)
+		case acquired:
+			return holdLease(ctx, clientset, holderIdentity, logf, cfg), nil
+		default:
+			position := slices.IndexFunc(status.Queue, func(w LeaseWaiter) bool { return w.Identity == holderIdentity })
+			report := fmt.Sprintf("The %s lease is currently held by %s. Waiting at position %d of %d...",
+				cfg.scope, status.Holder, position+1, len(status.Queue))
+			if status.blockedBy != "" {
+				report = fmt.Sprintf("The %s lease is blocked by %s. Waiting at position %d of %d...",
+					cfg.scope, status.blockedBy, position+1, len(status.Queue))
+			}
+			if report != lastReport {
+				logf("%s", report)
+				lastReport = report
+			}
+		}
+		select {
+		case <-ctx.Done():
+			leaveLeaseQueue(clientset, holderIdentity, cfg)
+			return nil, fmt.Errorf("canceled before acquiring lease: %w", ctx.Err())
+		case <-time.After(cfg.retryPeriod):
+		}
+	}
+}
 
-	lock := &resourcelock.LeaseLock{
-		LeaseMeta: metav1.ObjectMeta{
-			Name:      leaseName,
+// tryAcquireLease queues holderIdentity for the lease and takes it when the lease is free,
+// holderIdentity is first in the queue and no lease of another scope excludes it. A conflicting
+// update is not an error: the next attempt starts over from the current state.
+func tryAcquireLease(ctx context.Context, clientset kubernetes.Interface, holderIdentity string, logf func(format string, args ...any), cfg leaseConfig) (bool, LeaseStatus, error) {
+	leases := clientset.CoordinationV1().Leases(leaseNamespace)
+	now := cfg.now()
+	lease, err := leases.Get(ctx, LeaseName(cfg.scope), metav1.GetOptions{})
+	create := k8serrors.IsNotFound(err)
+	if create {
+		lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{
+			Name:      LeaseName(cfg.scope),
 			Namespace: leaseNamespace,
-		},
-		Client: client.CoordinationV1(),
-		LockConfig: resourcelock.ResourceLockConfig{
-			Identity: holderIdentity,
-		},
-	}
-
-	becameLeader := make(chan struct{})
-	lostLeaderCh := make(chan struct{})
-
-	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
-		Lock:          lock,
-		LeaseDuration: leaseDuration,
-		RenewDeadline: renewDeadline,
-		RetryPeriod:   retryPeriod,
-		Callbacks: leaderelection.LeaderCallbacks{
-			OnNewLeader: func(identity string) {
-				t.Logf("The lease is currently held by another test %s. Waiting...", identity)
-			},
-			OnStartedLeading: func(_ context.Context) {
-				// Signal that we've acquired the lease
-				close(becameLeader)
-			},
-			OnStoppedLeading: func() {
-				t.Logf("Lost or released the lease")
-				close(lostLeaderCh)
-			},
-		},
-	})
+		}}
+	} else if err != nil {
+		return false, LeaseStatus{}, err
+	}
+	if lease.Labels == nil {
+		lease.Labels = map[string]string{}
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Labels[leaseLabel] = "true"
+	lease.Annotations[leaseScopeAnnotation] = cfg.scope
+	status, err := leaseStatus(lease)
 	if err != nil {
-		t.Fatalf("Failed to create leader elector: %v", err)
+		return false, status, err
 	}
 
-	// Run the leader election in a goroutine, so we can block until acquiring the lease
-	ctx, cancel := context.WithCancel(context.Background()) //nolint:usetesting
-	go elector.Run(ctx)
+	// Drop the waiters that gave up without leaving the queue, and refresh our own entry.
+	queue := status.Queue[:0]
+	queued := false
+	for _, w := range status.Queue {
+		if w.Identity == holderIdentity {
+			w.Seen = now
+			queued = true
+		} else if now.Sub(w.Seen) > leaseDuration {
+			continue
+		}
+		queue = append(queue, w)
+	}
+	if !queued {
+		queue = append(queue, LeaseWaiter{Identity: holderIdentity, Since: now, Seen: now})
+	}
+	status.Queue = queue
 
-	// Wait until we become leader OR the test context ends
-	select {
-	case <-becameLeader:
-		t.Logf("Acquired the lease as %s", holderIdentity)
-	case <-t.Context().Done():
-		// If the test was canceled before we could acquire the lease
-		cancel()
-		t.Fatalf("Test was canceled before acquiring lease: %v", t.Context().Err())
+	acquired := false
+	if queue[0].Identity == holderIdentity {
+		switch {
+		case status.Expired(now):
+			acquired = true
+			if status.Holder != "" {
+				logf("Taking over the %s lease from %s, which stopped renewing it at %s", cfg.scope, status.Holder, status.Renewed.Format(time.RFC3339))
+			}
+		case status.Overdue(now):
+			acquired = true
+			logf("Breaking the %s lease held by %s since %s, beyond its maximum hold of %s",
+				cfg.scope, status.Holder, status.Acquired.Format(time.RFC3339), status.MaxHold)
+		}
+	}
+	if acquired {
+		if status.blockedBy, err = excludingLease(ctx, clientset, holderIdentity, cfg, now); err != nil {
+			return false, status, err
+		}
+		acquired = status.blockedBy == ""
+	}
+	if acquired {
+		status.Queue = queue[1:]
+		takeLease(lease, holderIdentity, now, cfg.maxHold)
+	}
+	setLeaseQueue(lease, status.Queue)
+	if create {
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	} else {
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	}
+	if k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err) {
+		return false, status, nil
+	} else if err != nil {
+		return false, status, err
+	}
+	if !acquired {
+		return false, status, nil
 	}
 
-	// We hold the lease. Register a cleanup to release it when the test is fully done.
-	t.Cleanup(func() {
-		t.Log("Releasing cluster-wide lock...")
-		// Stop renewing the lease:
+	// A holder of another scope may have taken its lease between the check and the update.
+	// Checking again once the lease is taken guarantees that at least one of the two sees the
+	// other; it gives the lease back and waits first in the queue.
+	if status.blockedBy, err = excludingLease(ctx, clientset, holderIdentity, cfg, cfg.now()); err != nil || status.blockedBy != "" {
+		_, yieldErr := updateHeldLease(ctx, clientset, holderIdentity, cfg, func(lease *coordinationv1.Lease) {
+			current, _ := leaseStatus(lease)
+			clearLeaseHolder(lease)
+			setLeaseQueue(lease, append([]LeaseWaiter{{Identity: holderIdentity, Since: now, Seen: cfg.now()}}, current.Queue...))
+		})
+		return false, status, errors.Join(err, yieldErr)
+	}
+	return true, status, nil
+}
+
+// excludingLease describes the lease of another scope that keeps holderIdentity from taking
+// the lease of cfg.scope, or returns "" when there is none. The cluster lease excludes every
+// named scope, also while suites wait for it so that scoped holders cannot starve them, and
+// every held named-scope lease excludes the cluster lease.
+func excludingLease(ctx context.Context, clientset kubernetes.Interface, holderIdentity string, cfg leaseConfig, now time.Time) (string, error) {
+	leases, err := clientset.CoordinationV1().Leases(leaseNamespace).List(ctx, metav1.ListOptions{LabelSelector: leaseLabel + "=true"})
+	if err != nil {
+		return "", err
+	}
+	for i := range leases.Items {
+		lease := &leases.Items[i]
+		if lease.Name == LeaseName(cfg.scope) || (cfg.scope != DefaultLeaseScope && lease.Name != leaseName) {
+			continue
+		}
+		status, err := leaseStatus(lease)
+		if err != nil {
+			return "", err
+		}
+		scope := status.Scope
+		if scope == "" {
+			scope = strings.TrimPrefix(strings.TrimPrefix(lease.Name, leaseName), "-")
+		}
+		if !status.Expired(now) && status.Holder != holderIdentity {
+			return fmt.Sprintf("the %s lease held by %s", scope, status.Holder), nil
+		}
+		if cfg.scope == DefaultLeaseScope {
+			continue
+		}
+		for _, w := range status.Queue {
+			if w.Identity != holderIdentity && now.Sub(w.Seen) <= leaseDuration {
+				return fmt.Sprintf("the %s lease awaited by %s", scope, w.Identity), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func takeLease(lease *coordinationv1.Lease, holderIdentity string, now time.Time, maxHold time.Duration) {
+	seconds := int32(leaseDuration / time.Second)
+	transitions := int32(0)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions = *lease.Spec.LeaseTransitions + 1
+	}
+	lease.Spec = coordinationv1.LeaseSpec{
+		HolderIdentity:       &holderIdentity,
+		LeaseDurationSeconds: &seconds,
+		AcquireTime:          &metav1.MicroTime{Time: now},
+		RenewTime:            &metav1.MicroTime{Time: now},
+		LeaseTransitions:     &transitions,
+	}
+	if maxHold > 0 {
+		lease.Annotations[leaseMaxHoldAnnotation] = maxHold.String()
+	} else {
+		delete(lease.Annotations, leaseMaxHoldAnnotation)
+	}
+}
+
+// holdLease renews the lease until the returned release function is called.
+func holdLease(ctx context.Context, clientset kubernetes.Interface, holderIdentity string, logf func(format string, args ...any), cfg leaseConfig) func() {
+	// Renew the lease detached from ctx: the lease must outlive the acquisition wait.
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-time.After(cfg.retryPeriod):
+			}
+			held, err := updateHeldLease(renewCtx, clientset, holderIdentity, cfg, func(lease *coordinationv1.Lease) {
+				lease.Spec.RenewTime = &metav1.MicroTime{Time: cfg.now()}
+			})
+			if err != nil && renewCtx.Err() == nil {
+				logf("Failed to renew the %s lease: %v", cfg.scope, err)
+			}
+			if !held && err == nil {
+				logf("Lost the %s lease: it was taken over or broken by another holder", cfg.scope)
+				if cfg.onLost != nil {
+					cfg.onLost()
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		// Stop renewing the lease, then give it up unless someone else took it in the meantime.
 		cancel()
-		// Wait until the OnStoppedLeading callback fires, ensuring the lock is fully released.
-		<-lostLeaderCh
-		t.Log("Cluster-wide lock released.")
-	})
+		<-stopped
+		releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancelRelease()
+		held, err := updateHeldLease(releaseCtx, clientset, holderIdentity, cfg, clearLeaseHolder)
+		switch {
+		case err != nil:
+			logf("Failed to release the %s lease, it expires in %s: %v", cfg.scope, leaseDuration, err)
+		case held:
+			logf("Released the %s lease", cfg.scope)
+		}
+	}
+}
+
+// clearLeaseHolder frees the lease, keeping its queue.
+func clearLeaseHolder(lease *coordinationv1.Lease) {
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	delete(lease.Annotations, leaseMaxHoldAnnotation)
+}
+
+// updateHeldLease applies update to the lease if holderIdentity still holds it, retrying when
+// waiters update the queue concurrently. It reports whether holderIdentity held the lease.
+func updateHeldLease(ctx context.Context, clientset kubernetes.Interface, holderIdentity string, cfg leaseConfig, update func(*coordinationv1.Lease)) (bool, error) {
+	leases := clientset.CoordinationV1().Leases(leaseNamespace)
+	var err error
+	for range 5 {
+		var lease *coordinationv1.Lease
+		lease, err = leases.Get(ctx, LeaseName(cfg.scope), metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holderIdentity {
+			return false, nil
+		}
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		update(lease)
+		if _, err = leases.Update(ctx, lease, metav1.UpdateOptions{}); !k8serrors.IsConflict(err) {
+			return true, err
+		}
+	}
+	return true, err
+}
+
+// leaveLeaseQueue removes holderIdentity from the queue of a lease it gave up waiting for.
+// Waiters that fail to do so are dropped once they are no longer seen.
+func leaveLeaseQueue(clientset kubernetes.Interface, holderIdentity string, cfg leaseConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	leases := clientset.CoordinationV1().Leases(leaseNamespace)
+	for range 3 {
+		lease, err := leases.Get(ctx, LeaseName(cfg.scope), metav1.GetOptions{})
+		if err != nil {
+			return
+		}
+		status, err := leaseStatus(lease)
+		if err != nil {
+			return
+		}
+		queue := slices.DeleteFunc(status.Queue, func(w LeaseWaiter) bool { return w.Identity == holderIdentity })
+		setLeaseQueue(lease, queue)
+		if _, err = leases.Update(ctx, lease, metav1.UpdateOptions{}); !k8serrors.IsConflict(err) {
+			return
+		}
+	}
+}
+
+// FormatLeases writes one line per lease: its scope, holder, how long it has been held and the queue.
+func FormatLeases(statuses []LeaseStatus, now time.Time) string {
+	var out strings.Builder
+	for _, s := range statuses {
+		holder := "-"
+		switch {
+		case s.Holder == "":
+		case s.Expired(now):
+			holder = s.Holder + " (expired)"
+		case s.Overdue(now):
+			holder = s.Holder + " (overdue)"
+		default:
+			holder = s.Holder
+		}
+		held := "-"
+		if s.Holder != "" {
+			held = now.Sub(s.Acquired).Round(time.Second).String()
+			if s.MaxHold > 0 {
+				held += "/" + s.MaxHold.String()
+			}
+		}
+		fmt.Fprintf(&out, "%s\t%s\t%s\t%d waiting\n", s.Scope, holder, held, len(s.Queue))
+		for i, w := range s.Queue {
+			fmt.Fprintf(&out, "\t%d. %s\twaiting %s\t\n", i+1, w.Identity, now.Sub(w.Since).Round(time.Second))
+		}
+	}
+	return out.String()
+}
+
+// ErrLeaseNotHeld is returned by BreakLease when nobody holds the lease.
+var ErrLeaseNotHeld = errors.New("lease not held")
+
+// BreakLease releases the lease of scope whoever holds it, for stale holders that cannot
+// release it themselves. The first waiter takes it on its next attempt.
+func BreakLease(ctx context.Context, clientset kubernetes.Interface, scope string) (string, error) {
+	leases := clientset.CoordinationV1().Leases(leaseNamespace)
+	lease, err := leases.Get(ctx, LeaseName(scope), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return "", ErrLeaseNotHeld
+	}
+	holder := *lease.Spec.HolderIdentity
+	clearLeaseHolder(lease)
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return holder, err
 }
@@ -0,0 +1,248 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaseQueue(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	cfg := newLeaseConfig([]LeaseOption{WithLeaseScope("node-logs"), WithLeaseMaxHold(30 * time.Minute)})
+	cfg.now = func() time.Time { return now }
+	attempt := func(identity string) (bool, LeaseStatus) {
+		acquired, status, err := tryAcquireLease(t.Context(), clientset, identity, t.Logf, cfg)
+		require.NoError(t, err)
+		return acquired, status
+	}
+	renew := func(identity string) {
+		held, err := updateHeldLease(t.Context(), clientset, identity, cfg, func(lease *coordinationv1.Lease) {
+			lease.Spec.RenewTime.Time = now
+		})
+		require.NoError(t, err)
+		require.True(t, held)
+	}
+
+	acquired, _ := attempt("first")
+	require.True(t, acquired)
+
+	// Waiters queue in arrival order while the lease is held.
+	for _, identity := range []string{"second", "third"} {
+		acquired, status := attempt(identity)
+		require.False(t, acquired)
+		require.Equal(t, "first", status.Holder)
+	}
+	now = now.Add(10 * time.Second)
+	attempt("third")
+	_, status := attempt("second")
+	require.Equal(t, []string{"second", "third"}, waiterIdentities(status.Queue))
+
+	// Once the holder stops renewing, the first waiter takes over, never a later one.
+	now = now.Add(leaseDuration - 15*time.Second)
+	attempt("second")
+	now = now.Add(10 * time.Second)
+	acquired, _ = attempt("third")
+	require.False(t, acquired)
+	acquired, _ = attempt("second")
+	require.True(t, acquired)
+
+	// A holder that keeps renewing beyond its maximum hold is broken by the first waiter.
+	for range 5 {
+		now = now.Add(5 * time.Minute)
+		renew("second")
+		acquired, _ = attempt("third")
+		require.False(t, acquired)
+	}
+	now = now.Add(6 * time.Minute)
+	renew("second")
+	acquired, _ = attempt("third")
+	require.True(t, acquired)
+	held, err := updateHeldLease(t.Context(), clientset, "second", cfg, func(*coordinationv1.Lease) {})
+	require.NoError(t, err)
+	require.False(t, held)
+
+	statuses, err := ListLeases(t.Context(), clientset)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, "node-logs", statuses[0].Scope)
+	require.Equal(t, "third", statuses[0].Holder)
+	require.Equal(t, 30*time.Minute, statuses[0].MaxHold)
+	require.Empty(t, statuses[0].Queue)
+	require.Equal(t, "node-logs\tthird\t1m0s/30m0s\t0 waiting\n", FormatLeases(statuses, now.Add(time.Minute)))
+
+	holder, err := BreakLease(t.Context(), clientset, "node-logs")
+	require.NoError(t, err)
+	require.Equal(t, "third", holder)
+	_, err = BreakLease(t.Context(), clientset, "node-logs")
+	require.ErrorIs(t, err, ErrLeaseNotHeld)
+}
+
+func TestLeaseQueueDropsStaleWaiters(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	cfg := newLeaseConfig(nil)
+	cfg.now = func() time.Time { return now }
+
+	attempt := func(identity string) LeaseStatus {
+		_, status, err := tryAcquireLease(t.Context(), clientset, identity, t.Logf, cfg)
+		require.NoError(t, err)
+		return status
+	}
+	renew := func() {
+		_, err := updateHeldLease(t.Context(), clientset, "holder", cfg, func(lease *coordinationv1.Lease) {
+			lease.Spec.RenewTime.Time = now
+		})
+		require.NoError(t, err)
+	}
+
+	for _, identity := range []string{"holder", "gone", "waiting"} {
+		attempt(identity)
+	}
+	now = now.Add(leaseDuration / 2)
+	renew()
+	attempt("waiting")
+	now = now.Add(leaseDuration/2 + time.Second)
+	renew()
+	require.Equal(t, []string{"waiting"}, waiterIdentities(attempt("waiting").Queue))
+
+	leaveLeaseQueue(clientset, "waiting", cfg)
+	statuses, err := ListLeases(t.Context(), clientset)
+	require.NoError(t, err)
+	require.Equal(t, DefaultLeaseScope, statuses[0].Scope)
+	require.Equal(t, "holder", statuses[0].Holder)
+	require.Empty(t, statuses[0].Queue)
+}
+
+func TestAcquireLeaseWaitsForRelease(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	cfg := newLeaseConfig(nil)
+	cfg.retryPeriod = 10 * time.Millisecond
+
+	release, err := acquireLease(t.Context(), clientset, "first", t.Logf, cfg)
+	require.NoError(t, err)
+	acquired := make(chan func())
+	go func() {
+		releaseSecond, acquireErr := acquireLease(t.Context(), clientset, "second", t.Logf, cfg)
+		assert.NoError(t, acquireErr)
+		acquired <- releaseSecond
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the lease was acquired while held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case releaseSecond := <-acquired:
+		releaseSecond()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lease was not acquired after its release")
+	}
+}
+
+func TestLeaseScopesExcludeClusterLease(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	attempt := func(identity, scope string) (bool, LeaseStatus) {
+		cfg := newLeaseConfig([]LeaseOption{WithLeaseScope(scope)})
+		cfg.now = func() time.Time { return now }
+		acquired, status, err := tryAcquireLease(t.Context(), clientset, identity, t.Logf, cfg)
+		require.NoError(t, err)
+		return acquired, status
+	}
+	release := func(identity, scope string) {
+		held, err := updateHeldLease(t.Context(), clientset, identity, newLeaseConfig([]LeaseOption{WithLeaseScope(scope)}), clearLeaseHolder)
+		require.NoError(t, err)
+		require.True(t, held)
+	}
+
+	// A named scope waits for the cluster lease.
+	acquired, _ := attempt("cluster-suite", DefaultLeaseScope)
+	require.True(t, acquired)
+	acquired, status := attempt("logs-suite", "node-logs")
+	require.False(t, acquired)
+	require.Equal(t, "the cluster lease held by cluster-suite", status.blockedBy)
+	release("cluster-suite", DefaultLeaseScope)
+	acquired, _ = attempt("logs-suite", "node-logs")
+	require.True(t, acquired)
+
+	// Named scopes do not exclude each other, but the cluster lease waits for all of them.
+	acquired, _ = attempt("crds-suite", "operator-crds")
+	require.True(t, acquired)
+	acquired, status = attempt("cluster-suite", DefaultLeaseScope)
+	require.False(t, acquired)
+	require.Contains(t, status.blockedBy, "lease held by")
+
+	// Scoped suites arriving while the cluster lease is awaited wait behind it.
+	release("crds-suite", "operator-crds")
+	acquired, status = attempt("crds-suite", "operator-crds")
+	require.False(t, acquired)
+	require.Equal(t, "the cluster lease awaited by cluster-suite", status.blockedBy)
+	release("logs-suite", "node-logs")
+	acquired, _ = attempt("cluster-suite", DefaultLeaseScope)
+	require.True(t, acquired)
+}
+
+func TestLeaseKeepsForeignLabels(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset(&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{
+		Name:      leaseName,
+		Namespace: leaseNamespace,
+		Labels:    map[string]string{"team": "o11y"},
+	}})
+	acquired, _, err := tryAcquireLease(t.Context(), clientset, "holder", t.Logf, newLeaseConfig(nil))
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	lease, err := clientset.CoordinationV1().Leases(leaseNamespace).Get(t.Context(), leaseName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "o11y", leaseLabel: "true"}, lease.Labels)
+}
+
+func TestHoldLeaseReportsLoss(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset()
+	lost := make(chan struct{})
+	cfg := newLeaseConfig([]LeaseOption{WithLeaseLost(func() { close(lost) })})
+	cfg.retryPeriod = 10 * time.Millisecond
+
+	release, err := acquireLease(t.Context(), clientset, "holder", t.Logf, cfg)
+	require.NoError(t, err)
+	defer release()
+	_, err = BreakLease(t.Context(), clientset, DefaultLeaseScope)
+	require.NoError(t, err)
+
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the holder was not told that it lost the lease")
+	}
+}
+
+func waiterIdentities(queue []LeaseWaiter) []string {
+	identities := make([]string, len(queue))
+	for i, w := range queue {
+		identities[i] = w.Identity
+	}
+	return identities
+}
@@ -0,0 +1,310 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	SignalLogs    = "logs"
+	SignalMetrics = "metrics"
+	SignalTraces  = "traces"
+)
+
+// CorrelationAttributes are the k8s metadata keys every signal of a workload must agree on.
+var CorrelationAttributes = []string{
+	"k8s.pod.name",
+	"k8s.namespace.name",
+	"k8s.node.name",
+	"k8s.cluster.name",
+	"container.id",
+	"service.name",
+}
+
+// multiValuedCorrelationAttributes may legitimately take several values for one
+// pod, e.g. one container.id per container. Signals only need to share one value.
+var multiValuedCorrelationAttributes = map[string]struct{}{
+	"container.id": {},
+}
+
+// PodSelector identifies a pod by name or UID. UID takes precedence when both are set.
+type PodSelector struct {
+	Name string
+	UID  string
+}
+
+func (p PodSelector) String() string {
+	if p.UID != "" {
+		return "uid=" + p.UID
+	}
+	return "name=" + p.Name
+}
+
+// matches reports whether the resource or record attributes identify the pod.
+func (p PodSelector) matches(resourceAttrs, recordAttrs pcommon.Map) bool {
+	key, want := "k8s.pod.name", p.Name
+	if p.UID != "" {
+		key, want = "k8s.pod.uid", p.UID
+	}
+	return attrEquals(resourceAttrs, key, want) || attrEquals(recordAttrs, key, want)
+}
+
+func attrEquals(attrs pcommon.Map, key, want string) bool {
+	v, ok := attrs.Get(key)
+	return ok && v.AsString() == want
+}
+
+// CorrelationSinks lists the sinks a workload's telemetry may arrive in. Nil or
+// empty entries are skipped.
+type CorrelationSinks struct {
+	Logs    []*consumertest.LogsSink
+	Metrics []*consumertest.MetricsSink
+	Traces  []*consumertest.TracesSink
+}
+
+// PodCorrelation is the k8s metadata a pod reported through each signal.
+type PodCorrelation struct {
+	Pod PodSelector
+	// Values maps signal -> attribute -> set of observed values.
+	Values map[string]map[string]map[string]struct{}
+	// Records counts matching log records, datapoints and spans per signal.
+	Records map[string]int
+	// LogTraceIDs are the trace IDs carried by the pod's log records.
+	LogTraceIDs map[pcommon.TraceID]struct{}
+	// ReceivedTraceIDs are all trace IDs the traces sinks received, from any pod.
+	ReceivedTraceIDs map[pcommon.TraceID]struct{}
+}
+
+// CollectPodCorrelation gathers the correlation attributes of every record that
+// belongs to pod. Attributes are read from the record first and fall back to the
+// resource, since HEC delivers k8s metadata as record-level fields.
+func CollectPodCorrelation(sinks CorrelationSinks, pod PodSelector) PodCorrelation {
+	c := PodCorrelation{
+		Pod:              pod,
+		Values:           map[string]map[string]map[string]struct{}{},
+		Records:          map[string]int{},
+		LogTraceIDs:      map[pcommon.TraceID]struct{}{},
+		ReceivedTraceIDs: map[pcommon.TraceID]struct{}{},
+	}
+
+	for _, sink := range sinks.Logs {
+		if sink == nil {
+			continue
+		}
+		for _, ld := range sink.AllLogs() {
+			c.collectLogs(ld)
+		}
+	}
+	for _, sink := range sinks.Metrics {
+		if sink == nil {
+			continue
+		}
+		for _, md := range sink.AllMetrics() {
+			c.collectMetrics(md)
+		}
+	}
+	for _, sink := range sinks.Traces {
+		if sink == nil {
+			continue
+		}
+		for _, td := range sink.AllTraces() {
+			c.collectTraces(td)
+		}
+	}
+	return c
+}
+
+func (c *PodCorrelation) record(signal string, resourceAttrs, recordAttrs pcommon.Map) {
+	c.Records[signal]++
+	values, ok := c.Values[signal]
+	if !ok {
+		values = map[string]map[string]struct{}{}
+		c.Values[signal] = values
+	}
+	for _, key := range CorrelationAttributes {
+		v, found := recordAttrs.Get(key)
+		if !found {
+			v, found = resourceAttrs.Get(key)
+		}
+		if !found || v.AsString() == "" {
+			continue
+		}
+		if values[key] == nil {
+			values[key] = map[string]struct{}{}
+		}
+		values[key][v.AsString()] = struct{}{}
+	}
+}
+
+func (c *PodCorrelation) collectLogs(ld plog.Logs) {
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			lrs := rl.ScopeLogs().At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				lr := lrs.At(k)
+				if !c.Pod.matches(rl.Resource().Attributes(), lr.Attributes()) {
+					continue
+				}
+				c.record(SignalLogs, rl.Resource().Attributes(), lr.Attributes())
+				if traceID, ok := logRecordTraceID(lr); ok {
+					c.LogTraceIDs[traceID] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+// logRecordTraceID returns the record's trace ID, falling back to the trace_id
+// field HEC uses to carry it.
+func logRecordTraceID(lr plog.LogRecord) (pcommon.TraceID, bool) {
+	if !lr.TraceID().IsEmpty() {
+		return lr.TraceID(), true
+	}
+	v, ok := lr.Attributes().Get("trace_id")
+	if !ok {
+		return pcommon.TraceID{}, false
+	}
+	var id pcommon.TraceID
+	b, err := hex.DecodeString(v.AsString())
+	if err != nil || len(b) != len(id) {
+		return pcommon.TraceID{}, false
+	}
+	copy(id[:], b)
+	return id, !id.IsEmpty()
+}
+
+func (c *PodCorrelation) collectMetrics(md pmetric.Metrics) {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			ms := rm.ScopeMetrics().At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				forEachDatapoint(ms.At(k), func(_ any, attrs pcommon.Map, _ pcommon.Timestamp, _ float64) {
+					if c.Pod.matches(rm.Resource().Attributes(), attrs) {
+						c.record(SignalMetrics, rm.Resource().Attributes(), attrs)
+					}
+				})
+			}
+		}
+	}
+}
+
+func (c *PodCorrelation) collectTraces(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				c.ReceivedTraceIDs[span.TraceID()] = struct{}{}
+				if c.Pod.matches(rs.Resource().Attributes(), span.Attributes()) {
+					c.record(SignalTraces, rs.Resource().Attributes(), span.Attributes())
+				}
+			}
+		}
+	}
+}
+
+// Validate reports attributes that differ between signals and log trace IDs that
+// the traces sinks never received.
+func (c PodCorrelation) Validate() error {
+	var errs []error
+	signals := make([]string, 0, len(c.Values))
+	for signal := range c.Values {
+		signals = append(signals, signal)
+	}
+	sort.Strings(signals)
+
+	for _, key := range CorrelationAttributes {
+		_, multi := multiValuedCorrelationAttributes[key]
+		var shared map[string]struct{}
+		var reported []string
+		for _, signal := range signals {
+			values := c.Values[signal][key]
+			if len(values) == 0 {
+				continue
+			}
+			reported = append(reported, fmt.Sprintf("%s=%v", signal, sortedKeySet(values)))
+			if !multi && len(values) > 1 {
+				errs = append(errs, fmt.Errorf("pod %s: %s has %d values in %s: %v", c.Pod, key, len(values), signal, sortedKeySet(values)))
+			}
+			if shared == nil {
+				shared = values
+				continue
+			}
+			shared = intersectKeySets(shared, values)
+		}
+		if shared != nil && len(shared) == 0 {
+			errs = append(errs, fmt.Errorf("pod %s: %s disagrees across signals: %s", c.Pod, key, strings.Join(reported, ", ")))
+		}
+	}
+
+	var missing []string
+	for id := range c.LogTraceIDs {
+		if _, ok := c.ReceivedTraceIDs[id]; !ok {
+			missing = append(missing, id.String())
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		errs = append(errs, fmt.Errorf("pod %s: %d log trace ID(s) not received by the traces sink: %v", c.Pod, len(missing), missing))
+	}
+	return errors.Join(errs...)
+}
+
+func intersectKeySets(a, b map[string]struct{}) map[string]struct{} {
+	out := map[string]struct{}{}
+	for k := range a {
+		if _, ok := b[k]; ok {
+			out[k] = struct{}{}
+		}
+	}
+	return out
+}
+
+func sortedKeySet(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AssertPodSignalsCorrelated waits until pod has records in every signal listed in
+// signals, then requires its k8s metadata to agree across them and every trace ID
+// carried by its logs to have been received by the traces sinks.
+func AssertPodSignalsCorrelated(t *testing.T, sinks CorrelationSinks, pod PodSelector, timeout time.Duration, signals ...string) PodCorrelation {
+	t.Helper()
+	var c PodCorrelation
+	require.Eventuallyf(t, func() bool {
+		c = CollectPodCorrelation(sinks, pod)
+		for _, signal := range signals {
+			if c.Records[signal] == 0 {
+				t.Logf("waiting for %s from pod %s, records so far: %v", signal, pod, c.Records)
+				return false
+			}
+		}
+		return true
+	}, timeout, 5*time.Second, "pod %s did not report %v within %v", pod, signals, timeout)
+
+	require.NoError(t, c.Validate(), "k8s metadata for pod %s is not consistent across signals", pod)
+	t.Logf("Pod %s correlated across %v (records: %v)", pod, signals, c.Records)
+	return c
+}
@@ -0,0 +1,93 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestPodCorrelationValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		metricNode  string
+		logTraceID  string
+		wantErr     string
+		wantRecords map[string]int
+	}{
+		{
+			name:        "consistent",
+			metricNode:  "node-1",
+			logTraceID:  "01000000000000000000000000000000",
+			wantRecords: map[string]int{SignalLogs: 1, SignalMetrics: 1, SignalTraces: 1},
+		},
+		{
+			name:       "node disagrees",
+			metricNode: "node-2",
+			wantErr:    "k8s.node.name disagrees across signals",
+		},
+		{
+			name:       "log trace not exported",
+			metricNode: "node-1",
+			logTraceID: "02000000000000000000000000000000",
+			wantErr:    "1 log trace ID(s) not received",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ld := plog.NewLogs()
+			lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+			lr.Attributes().PutStr("k8s.pod.uid", "uid-1")
+			lr.Attributes().PutStr("k8s.node.name", "node-1")
+			lr.Attributes().PutStr("container.id", "c1")
+			if testCase.logTraceID != "" {
+				lr.Attributes().PutStr("trace_id", testCase.logTraceID)
+			}
+			logs := new(consumertest.LogsSink)
+			require.NoError(t, logs.ConsumeLogs(t.Context(), ld))
+
+			md := pmetric.NewMetrics()
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.Resource().Attributes().PutStr("k8s.pod.uid", "uid-1")
+			rm.Resource().Attributes().PutStr("k8s.node.name", testCase.metricNode)
+			rm.Resource().Attributes().PutStr("container.id", "c1")
+			rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty()
+			metrics := new(consumertest.MetricsSink)
+			require.NoError(t, metrics.ConsumeMetrics(t.Context(), md))
+
+			td := ptrace.NewTraces()
+			rs := td.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().PutStr("k8s.pod.uid", "uid-1")
+			rs.Resource().Attributes().PutStr("container.id", "c1")
+			span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+			span.SetTraceID(pcommon.TraceID{1})
+			traces := new(consumertest.TracesSink)
+			require.NoError(t, traces.ConsumeTraces(t.Context(), td))
+
+			c := CollectPodCorrelation(CorrelationSinks{
+				Logs:    []*consumertest.LogsSink{logs},
+				Metrics: []*consumertest.MetricsSink{metrics},
+				Traces:  []*consumertest.TracesSink{traces},
+			}, PodSelector{Name: "ignored", UID: "uid-1"})
+			err := c.Validate()
+			if testCase.wantErr == "" {
+				require.NoError(t, err)
+				require.Equal(t, testCase.wantRecords, c.Records)
+				return
+			}
+			require.ErrorContains(t, err, testCase.wantErr)
+		})
+	}
+}
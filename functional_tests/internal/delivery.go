@@ -0,0 +1,322 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// DeliveredRecord is a generated record as received by a sink.
+type DeliveredRecord struct {
+	Stream string
+	Seq    int
+	// Emitted is the record timestamp, or its observed timestamp when unset.
+	Emitted time.Time
+	// Received is when the sink was first seen holding the record.
+	Received time.Time
+}
+
+// SeqRange is an inclusive range of sequence numbers.
+type SeqRange struct {
+	From, To int
+}
+
+func (r SeqRange) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(r.From)
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
+
+// StreamDelivery reconciles the records of one stream.
+type StreamDelivery struct {
+	Stream   string
+	Expected int
+	// Received counts every delivery, duplicates included.
+	Received int
+	// Unique counts the distinct expected sequence numbers received.
+	Unique  int
+	Missing []SeqRange
+	// Duplicates counts the deliveries beyond the first of a sequence number.
+	Duplicates int
+	// Unexpected counts deliveries of sequence numbers outside the expected range.
+	Unexpected int
+	// OutOfOrder counts deliveries of a sequence number lower than one delivered before it.
+	OutOfOrder int
+	// Latency percentiles from Emitted to Received; zero when no record had a timestamp.
+	LatencyP50, LatencyP95, LatencyMax time.Duration
+}
+
+// MissingCount is the number of expected records never received.
+func (s StreamDelivery) MissingCount() int {
+	n := 0
+	for _, r := range s.Missing {
+		n += r.To - r.From + 1
+	}
+	return n
+}
+
+// DeliveryReport is the reconciliation of every stream, sorted by stream.
+type DeliveryReport struct {
+	Streams []StreamDelivery
+}
+
+// Lost is the number of expected records never received, over all streams.
+func (r DeliveryReport) Lost() int {
+	n := 0
+	for _, s := range r.Streams {
+		n += s.MissingCount()
+	}
+	return n
+}
+
+// DuplicateRate is the share of deliveries that were duplicates, over all streams.
+func (r DeliveryReport) DuplicateRate() float64 {
+	received, duplicates := 0, 0
+	for _, s := range r.Streams {
+		received += s.Received
+		duplicates += s.Duplicates
+	}
+	if received == 0 {
+		return 0
+	}
+	return float64(duplicates) / float64(received)
+}
+
+func (r DeliveryReport) String() string {
+	var out strings.Builder
+	for _, s := range r.Streams {
+		fmt.Fprintf(&out, "stream %s: expected=%d received=%d unique=%d missing=%d duplicates=%d unexpected=%d out-of-order=%d latency p50=%s p95=%s max=%s\n",
+			s.Stream, s.Expected, s.Received, s.Unique, s.MissingCount(), s.Duplicates, s.Unexpected, s.OutOfOrder,
+			s.LatencyP50, s.LatencyP95, s.LatencyMax)
+		if len(s.Missing) > 0 {
+			ranges := make([]string, len(s.Missing))
+			for i, m := range s.Missing {
+				ranges[i] = m.String()
+			}
+			fmt.Fprintf(&out, "  missing: %s\n", strings.Join(ranges, ","))
+		}
+	}
+	return out.String()
+}
+
+// ReconcileDelivery compares the delivered records, in arrival order, with the expected
+// number of records per stream, whose sequence numbers run from 0 to the count minus one.
+func ReconcileDelivery(expected map[string]int, delivered []DeliveredRecord) DeliveryReport {
+	byStream := map[string][]DeliveredRecord{}
+	for _, rec := range delivered {
+		byStream[rec.Stream] = append(byStream[rec.Stream], rec)
+	}
+	streams := map[string]bool{}
+	for stream := range expected {
+		streams[stream] = true
+	}
+	for stream := range byStream {
+		streams[stream] = true
+	}
+
+	var report DeliveryReport
+	for stream := range streams {
+		s := StreamDelivery{Stream: stream, Expected: expected[stream]}
+		seen := make(map[int]int, s.Expected)
+		maxSeq := -1
+		var latencies []time.Duration
+		for _, rec := range byStream[stream] {
+			s.Received++
+			if rec.Seq < maxSeq {
+				s.OutOfOrder++
+			}
+			maxSeq = max(maxSeq, rec.Seq)
+			if rec.Seq < 0 || rec.Seq >= s.Expected {
+				s.Unexpected++
+				continue
+			}
+			seen[rec.Seq]++
+			if seen[rec.Seq] > 1 {
+				s.Duplicates++
+				continue
+			}
+			if !rec.Emitted.IsZero() && !rec.Received.IsZero() {
+				latencies = append(latencies, rec.Received.Sub(rec.Emitted))
+			}
+		}
+		s.Unique = len(seen)
+		for seq := 0; seq < s.Expected; seq++ {
+			if seen[seq] > 0 {
+				continue
+			}
+			if n := len(s.Missing); n > 0 && s.Missing[n-1].To == seq-1 {
+				s.Missing[n-1].To = seq
+			} else {
+				s.Missing = append(s.Missing, SeqRange{From: seq, To: seq})
+			}
+		}
+		if len(latencies) > 0 {
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			s.LatencyP50 = percentile(latencies, 0.50)
+			s.LatencyP95 = percentile(latencies, 0.95)
+			s.LatencyMax = latencies[len(latencies)-1]
+		}
+		report.Streams = append(report.Streams, s)
+	}
+	sort.Slice(report.Streams, func(i, j int) bool { return report.Streams[i].Stream < report.Streams[j].Stream })
+	return report
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// ExpectedLogStreams counts the expected records per stream.
+func ExpectedLogStreams(expected ...[]ExpectedLog) map[string]int {
+	counts := map[string]int{}
+	for _, logs := range expected {
+		for _, rec := range logs {
+			counts[rec.Stream] = max(counts[rec.Stream], rec.Seq+1)
+		}
+	}
+	return counts
+}
+
+var generatedLogLine = regexp.MustCompile(`^(?:([^\s{]+) seq=|\{"generator":"([^"]+)","seq":")(\d{8})`)
+
+// ParseGeneratedLogLine returns the stream and sequence number of a record body written by a
+// LogGenerator, in either format.
+func ParseGeneratedLogLine(body string) (string, int, bool) {
+	m := generatedLogLine.FindStringSubmatch(body)
+	if m == nil {
+		return "", 0, false
+	}
+	seq, err := strconv.Atoi(m[3])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1] + m[2], seq, true
+}
+
+// DeliveryTracker records when the records of a logs sink arrive, to measure delivery latency.
+// The consumertest sinks do not keep arrival times, so the tracker polls the sink.
+type DeliveryTracker struct {
+	sink     *consumertest.LogsSink
+	mu       sync.Mutex
+	records  []DeliveredRecord
+	batches  int
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// deliveryPollInterval bounds the error of the measured latencies.
+const deliveryPollInterval = 100 * time.Millisecond
+
+// TrackLogDelivery starts tracking the generated records arriving at the sink. Start it right
+// after the sink, before records can arrive. It stops when the test ends.
+func TrackLogDelivery(t *testing.T, sink *consumertest.LogsSink) *DeliveryTracker {
+	tracker := &DeliveryTracker{sink: sink, stop: make(chan struct{}), stopped: make(chan struct{})}
+	go func() {
+		defer close(tracker.stopped)
+		ticker := time.NewTicker(deliveryPollInterval)
+		defer ticker.Stop()
+		for {
+			tracker.poll()
+			select {
+			case <-tracker.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	t.Cleanup(tracker.Stop)
+	return tracker
+}
+
+// Stop stops polling the sink.
+func (d *DeliveryTracker) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		<-d.stopped
+	})
+}
+
+func (d *DeliveryTracker) poll() {
+	batches := d.sink.AllLogs()
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(batches) < d.batches {
+		// The sink was reset; start over from its first batch.
+		d.batches = 0
+	}
+	for _, batch := range batches[d.batches:] {
+		d.records = append(d.records, deliveredLogs(batch, now)...)
+	}
+	d.batches = len(batches)
+}
+
+func deliveredLogs(batch plog.Logs, received time.Time) []DeliveredRecord {
+	var records []DeliveredRecord
+	for _, row := range QueryLogs([]plog.Logs{batch}) {
+		stream, seq, ok := ParseGeneratedLogLine(row.Record.Body().AsString())
+		if !ok {
+			continue
+		}
+		rec := DeliveredRecord{Stream: stream, Seq: seq, Received: received}
+		if row.Timestamp != 0 {
+			rec.Emitted = row.Timestamp.AsTime()
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// Records returns the generated records received so far, in arrival order.
+func (d *DeliveryTracker) Records() []DeliveredRecord {
+	d.poll()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DeliveredRecord(nil), d.records...)
+}
+
+// WaitForDelivery waits until every expected record was received or until nothing new has
+// arrived for the settle window, keeps watching for late duplicates for another settle window,
+// and returns the reconciliation. It fails the test if nothing at all arrives before timeout.
+func WaitForDelivery(t *testing.T, tracker *DeliveryTracker, expected map[string]int, settle, timeout time.Duration) DeliveryReport {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	lastCount, lastChange := 0, time.Now()
+	for time.Now().Before(deadline) {
+		records := tracker.Records()
+		if len(records) != lastCount {
+			lastCount, lastChange = len(records), time.Now()
+		}
+		if ReconcileDelivery(expected, records).Lost() == 0 || (lastCount > 0 && time.Since(lastChange) >= settle) {
+			break
+		}
+		time.Sleep(deliveryPollInterval)
+	}
+	require.Positive(t, lastCount, "no generated records received within %s", timeout)
+	time.Sleep(settle)
+	report := ReconcileDelivery(expected, tracker.Records())
+	t.Logf("Delivery report:\n%s", report)
+	return report
+}
+
+// AssertDelivery fails the test when records were lost or duplicated beyond maxDuplicateRate.
+func AssertDelivery(t *testing.T, report DeliveryReport, maxDuplicateRate float64) {
+	t.Helper()
+	require.Zero(t, report.Lost(), "records were lost:\n%s", report)
+	require.LessOrEqual(t, report.DuplicateRate(), maxDuplicateRate, "duplicate rate too high:\n%s", report)
+}
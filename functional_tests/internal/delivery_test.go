@@ -0,0 +1,110 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestReconcileDelivery(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := func(stream string, seq int, latency time.Duration) DeliveredRecord {
+		return DeliveredRecord{Stream: stream, Seq: seq, Emitted: base, Received: base.Add(latency)}
+	}
+	report := ReconcileDelivery(map[string]int{"a": 10, "b": 2}, []DeliveredRecord{
+		rec("a", 0, time.Second),
+		rec("a", 1, 2*time.Second),
+		rec("a", 5, 3*time.Second),
+		rec("a", 3, 4*time.Second),
+		rec("a", 3, 9*time.Second),
+		rec("a", 12, time.Second),
+		rec("a", 9, 5*time.Second),
+		rec("b", 0, time.Second),
+		rec("b", 1, time.Second),
+		rec("c", 0, time.Second),
+	})
+
+	require.Len(t, report.Streams, 3)
+	a := report.Streams[0]
+	require.Equal(t, "a", a.Stream)
+	require.Equal(t, 7, a.Received)
+	require.Equal(t, 5, a.Unique)
+	require.Equal(t, []SeqRange{{2, 2}, {4, 4}, {6, 8}}, a.Missing)
+	require.Equal(t, 5, a.MissingCount())
+	require.Equal(t, 1, a.Duplicates)
+	require.Equal(t, 1, a.Unexpected)
+	require.Equal(t, 3, a.OutOfOrder)
+	require.Equal(t, 3*time.Second, a.LatencyP50)
+	require.Equal(t, 5*time.Second, a.LatencyMax)
+
+	b := report.Streams[1]
+	require.Empty(t, b.Missing)
+	require.Zero(t, b.Duplicates)
+
+	c := report.Streams[2]
+	require.Equal(t, 0, c.Expected)
+	require.Equal(t, 1, c.Unexpected)
+
+	require.Equal(t, 5, report.Lost())
+	require.InDelta(t, 0.1, report.DuplicateRate(), 1e-9)
+	require.Contains(t, report.String(), "  missing: 2,4,6-8\n")
+}
+
+func TestParseGeneratedLogLine(t *testing.T) {
+	t.Parallel()
+
+	for _, g := range []LogGenerator{
+		{Name: "no-drop", Records: 3, Size: 64},
+		{Name: "json-gen", Records: 3, Format: LogFormatJSON, Size: 64},
+		{Name: "multi", Records: 3, Multiline: []string{"  at frame"}},
+	} {
+		for _, expected := range g.Expected() {
+			stream, seq, ok := ParseGeneratedLogLine(expected.Body)
+			require.True(t, ok, expected.Body)
+			require.Equal(t, expected.Stream, stream)
+			require.Equal(t, expected.Seq, seq)
+		}
+	}
+	_, _, ok := ParseGeneratedLogLine("2020-08-26 DEBUG User login failed")
+	require.False(t, ok)
+}
+
+func TestDeliveryTracker(t *testing.T) {
+	t.Parallel()
+
+	sink := new(consumertest.LogsSink)
+	tracker := TrackLogDelivery(t, sink)
+	g := LogGenerator{Name: "gen", Records: 4}
+
+	send := func(seqs ...int) {
+		ld := plog.NewLogs()
+		records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+		for _, seq := range seqs {
+			lr := records.AppendEmpty()
+			lr.Body().SetStr(g.Line(seq))
+			lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+		}
+		require.NoError(t, sink.ConsumeLogs(t.Context(), ld))
+	}
+	send(0, 1)
+	send(1, 3)
+
+	report := WaitForDelivery(t, tracker, ExpectedLogStreams(g.Expected()), 300*time.Millisecond, 5*time.Second)
+	require.Len(t, report.Streams, 1)
+	require.Equal(t, []SeqRange{{2, 2}}, report.Streams[0].Missing)
+	require.Equal(t, 1, report.Streams[0].Duplicates)
+	require.Positive(t, report.Streams[0].LatencyMax)
+
+	send(2)
+	report = WaitForDelivery(t, tracker, ExpectedLogStreams(g.Expected()), 100*time.Millisecond, 5*time.Second)
+	AssertDelivery(t, report, 0.25)
+}
@@ -0,0 +1,340 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/golden"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"gopkg.in/yaml.v3"
+)
+
+// MetricsOverlay describes how one environment's expected metrics differ from the
+// shared base file. Resource indexes refer to positions in the base file.
+type MetricsOverlay struct {
+	Resources []ResourceOverlay `yaml:"resources,omitempty"`
+	// AppendResourceMetrics are golden-format resourceMetrics entries added after the base resources.
+	AppendResourceMetrics []any `yaml:"appendResourceMetrics,omitempty"`
+}
+
+// ResourceOverlay changes a single base resource.
+type ResourceOverlay struct {
+	Index            int            `yaml:"index"`
+	Remove           bool           `yaml:"remove,omitempty"`
+	SetAttributes    map[string]any `yaml:"setAttributes,omitempty"`
+	RemoveAttributes []string       `yaml:"removeAttributes,omitempty"`
+	RemoveMetrics    []string       `yaml:"removeMetrics,omitempty"`
+	// AddMetrics are golden-format metrics added to the resource's first scope,
+	// after RemoveMetrics is applied. Overriding a metric is a remove plus an add.
+	AddMetrics []any `yaml:"addMetrics,omitempty"`
+}
+
+func (o MetricsOverlay) isEmpty() bool {
+	return len(o.Resources) == 0 && len(o.AppendResourceMetrics) == 0
+}
+
+// OverlayFileName returns the overlay file name for an expected file, e.g.
+// expected_foo.yaml -> expected_foo.overlay.yaml.
+func OverlayFileName(name string) string {
+	return strings.TrimSuffix(name, ".yaml") + ".overlay.yaml"
+}
+
+// ReadLayeredMetrics loads the expected metrics called name for an environment.
+// A complete file in envDir takes precedence. Otherwise the file in baseDir is
+// read and the environment's overlay, if any, is applied on top of it.
+func ReadLayeredMetrics(baseDir, envDir, name string) (pmetric.Metrics, error) {
+	if full := filepath.Join(envDir, name); fileExists(full) {
+		return golden.ReadMetrics(full)
+	}
+	base, err := golden.ReadMetrics(filepath.Join(baseDir, name))
+	if err != nil {
+		return pmetric.Metrics{}, err
+	}
+	overlayFile := filepath.Join(envDir, OverlayFileName(name))
+	if !fileExists(overlayFile) {
+		return base, nil
+	}
+	overlay, err := ReadMetricsOverlay(overlayFile)
+	if err != nil {
+		return pmetric.Metrics{}, err
+	}
+	if err := ApplyMetricsOverlay(base, overlay); err != nil {
+		return pmetric.Metrics{}, fmt.Errorf("apply overlay %s: %w", overlayFile, err)
+	}
+	return base, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ReadMetricsOverlay parses an overlay file.
+func ReadMetricsOverlay(file string) (MetricsOverlay, error) {
+	var overlay MetricsOverlay
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return overlay, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&overlay); err != nil && !errors.Is(err, io.EOF) {
+		return overlay, fmt.Errorf("parse overlay %s: %w", file, err)
+	}
+	return overlay, nil
+}
+
+// ApplyMetricsOverlay modifies metrics in place.
+func ApplyMetricsOverlay(metrics pmetric.Metrics, overlay MetricsOverlay) error {
+	rms := metrics.ResourceMetrics()
+	removed := map[int]struct{}{}
+	for _, ro := range overlay.Resources {
+		if ro.Index < 0 || ro.Index >= rms.Len() {
+			return fmt.Errorf("resource index %d out of range, base has %d resources", ro.Index, rms.Len())
+		}
+		if ro.Remove {
+			removed[ro.Index] = struct{}{}
+			continue
+		}
+		rm := rms.At(ro.Index)
+		attrs := rm.Resource().Attributes()
+		for _, k := range ro.RemoveAttributes {
+			attrs.Remove(k)
+		}
+		for _, k := range sortedMapKeys(ro.SetAttributes) {
+			if err := attrs.PutEmpty(k).FromRaw(ro.SetAttributes[k]); err != nil {
+				return fmt.Errorf("resource %d attribute %q: %w", ro.Index, k, err)
+			}
+		}
+		if len(ro.RemoveMetrics) > 0 {
+			drop := map[string]struct{}{}
+			for _, name := range ro.RemoveMetrics {
+				drop[name] = struct{}{}
+			}
+			for i := 0; i < rm.ScopeMetrics().Len(); i++ {
+				rm.ScopeMetrics().At(i).Metrics().RemoveIf(func(m pmetric.Metric) bool {
+					_, ok := drop[m.Name()]
+					return ok
+				})
+			}
+		}
+		if len(ro.AddMetrics) > 0 {
+			added, err := decodeGoldenFragment(map[string]any{
+				"resourceMetrics": []any{map[string]any{"scopeMetrics": []any{map[string]any{"metrics": ro.AddMetrics}}}},
+			})
+			if err != nil {
+				return fmt.Errorf("resource %d addMetrics: %w", ro.Index, err)
+			}
+			if rm.ScopeMetrics().Len() == 0 {
+				rm.ScopeMetrics().AppendEmpty()
+			}
+			added.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().MoveAndAppendTo(rm.ScopeMetrics().At(0).Metrics())
+		}
+	}
+
+	if len(overlay.AppendResourceMetrics) > 0 {
+		appended, err := decodeGoldenFragment(map[string]any{"resourceMetrics": overlay.AppendResourceMetrics})
+		if err != nil {
+			return fmt.Errorf("appendResourceMetrics: %w", err)
+		}
+		appended.ResourceMetrics().MoveAndAppendTo(rms)
+	}
+
+	idx := 0
+	rms.RemoveIf(func(pmetric.ResourceMetrics) bool {
+		_, ok := removed[idx]
+		idx++
+		return ok
+	})
+	return nil
+}
+
+// DiffMetricsOverlay returns the overlay that turns base into actual. Resources are
+// matched by position; a metric whose content changed is removed and re-added.
+func DiffMetricsOverlay(base, actual pmetric.Metrics) (MetricsOverlay, error) {
+	var overlay MetricsOverlay
+	baseRMs, actualRMs := base.ResourceMetrics(), actual.ResourceMetrics()
+	for i := 0; i < baseRMs.Len(); i++ {
+		if i >= actualRMs.Len() {
+			overlay.Resources = append(overlay.Resources, ResourceOverlay{Index: i, Remove: true})
+			continue
+		}
+		ro, err := diffResource(i, baseRMs.At(i), actualRMs.At(i))
+		if err != nil {
+			return overlay, err
+		}
+		if ro != nil {
+			overlay.Resources = append(overlay.Resources, *ro)
+		}
+	}
+	for i := baseRMs.Len(); i < actualRMs.Len(); i++ {
+		single := pmetric.NewMetrics()
+		actualRMs.At(i).CopyTo(single.ResourceMetrics().AppendEmpty())
+		doc, err := encodeGoldenFragment(single)
+		if err != nil {
+			return overlay, err
+		}
+		overlay.AppendResourceMetrics = append(overlay.AppendResourceMetrics, doc["resourceMetrics"].([]any)[0])
+	}
+	return overlay, nil
+}
+
+func diffResource(index int, base, actual pmetric.ResourceMetrics) (*ResourceOverlay, error) {
+	ro := ResourceOverlay{Index: index}
+	baseAttrs, actualAttrs := base.Resource().Attributes(), actual.Resource().Attributes()
+	baseAttrs.Range(func(k string, _ pcommon.Value) bool {
+		if _, ok := actualAttrs.Get(k); !ok {
+			ro.RemoveAttributes = append(ro.RemoveAttributes, k)
+		}
+		return true
+	})
+	actualAttrs.Range(func(k string, v pcommon.Value) bool {
+		if bv, ok := baseAttrs.Get(k); !ok || !bv.Equal(v) {
+			if ro.SetAttributes == nil {
+				ro.SetAttributes = map[string]any{}
+			}
+			ro.SetAttributes[k] = v.AsRaw()
+		}
+		return true
+	})
+	sort.Strings(ro.RemoveAttributes)
+
+	baseMetrics, err := metricsByName(base)
+	if err != nil {
+		return nil, err
+	}
+	actualMetrics, err := metricsByName(actual)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedMapKeys(baseMetrics) {
+		if actualDoc, ok := actualMetrics[name]; !ok || !bytes.Equal(baseMetrics[name].json, actualDoc.json) {
+			ro.RemoveMetrics = append(ro.RemoveMetrics, name)
+		}
+	}
+	for _, name := range sortedMapKeys(actualMetrics) {
+		if baseDoc, ok := baseMetrics[name]; !ok || !bytes.Equal(baseDoc.json, actualMetrics[name].json) {
+			ro.AddMetrics = append(ro.AddMetrics, actualMetrics[name].doc)
+		}
+	}
+
+	if len(ro.SetAttributes) == 0 && len(ro.RemoveAttributes) == 0 && len(ro.RemoveMetrics) == 0 && len(ro.AddMetrics) == 0 {
+		return nil, nil
+	}
+	return &ro, nil
+}
+
+type encodedMetric struct {
+	json []byte
+	doc  any
+}
+
+func metricsByName(rm pmetric.ResourceMetrics) (map[string]encodedMetric, error) {
+	out := map[string]encodedMetric{}
+	for i := 0; i < rm.ScopeMetrics().Len(); i++ {
+		ms := rm.ScopeMetrics().At(i).Metrics()
+		for j := 0; j < ms.Len(); j++ {
+			single := pmetric.NewMetrics()
+			ms.At(j).CopyTo(single.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty())
+			doc, err := encodeGoldenFragment(single)
+			if err != nil {
+				return nil, err
+			}
+			metric := doc["resourceMetrics"].([]any)[0].(map[string]any)["scopeMetrics"].([]any)[0].(map[string]any)["metrics"].([]any)[0]
+			b, err := json.Marshal(metric)
+			if err != nil {
+				return nil, err
+			}
+			out[ms.At(j).Name()] = encodedMetric{json: b, doc: metric}
+		}
+	}
+	return out, nil
+}
+
+// encodeGoldenFragment converts metrics into the generic document form used in golden files.
+func encodeGoldenFragment(metrics pmetric.Metrics) (map[string]any, error) {
+	b, err := (&pmetric.JSONMarshaler{}).MarshalMetrics(metrics)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func decodeGoldenFragment(doc map[string]any) (pmetric.Metrics, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return pmetric.Metrics{}, err
+	}
+	return (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(b)
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteMetricsOverlay writes the delta between the base expected file and actual
+// to envDir. A stale overlay is removed when actual matches the base.
+func WriteMetricsOverlay(baseDir, envDir, name string, actual pmetric.Metrics) error {
+	base, err := golden.ReadMetrics(filepath.Join(baseDir, name))
+	if err != nil {
+		return err
+	}
+	overlay, err := DiffMetricsOverlay(base, actual)
+	if err != nil {
+		return err
+	}
+	overlayFile := filepath.Join(envDir, OverlayFileName(name))
+	if overlay.isEmpty() {
+		if err := os.Remove(overlayFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := yaml.Marshal(overlay)
+	if err != nil {
+		return fmt.Errorf("marshal overlay %s: %w", overlayFile, err)
+	}
+	// An environment matching the base has no directory in a fresh checkout.
+	if err := os.MkdirAll(envDir, 0o755); err != nil {
+		return err
+	}
+	//nolint:gosec // Expected overlays are committed testdata.
+	return os.WriteFile(overlayFile, b, 0o644)
+}
+
+// MaybeUpdateExpectedMetricsOverlay writes only the environment-specific delta when
+// UPDATE_EXPECTED_RESULTS is set. Environments that still keep a complete file are
+// updated in place.
+func MaybeUpdateExpectedMetricsOverlay(t *testing.T, baseDir, envDir, name string, actual *pmetric.Metrics) {
+	if !shouldUpdateExpectedResults() {
+		return
+	}
+	if full := filepath.Join(envDir, name); fileExists(full) {
+		MaybeUpdateExpectedMetricsResults(t, full, actual)
+		return
+	}
+	require.NoError(t, WriteMetricsOverlay(baseDir, envDir, name, *actual))
+	t.Logf("Wrote updated expected metric overlay to %s", filepath.Join(envDir, OverlayFileName(name)))
+}
@@ -0,0 +1,85 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/golden"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatatest/pmetrictest"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func newLayerTestMetrics(platform string, gauges ...string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("cloud.platform", platform)
+	rm.Resource().Attributes().PutStr("host.name", "abcd")
+	ms := rm.ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range gauges {
+		m := ms.AppendEmpty()
+		m.SetName(name)
+		m.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1)
+	}
+	return md
+}
+
+func TestMetricsOverlayRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// The environment directory does not exist yet, as for an environment that matched the base so far.
+	baseDir, envDir := t.TempDir(), filepath.Join(t.TempDir(), "expected_aks_values")
+	const name = "expected_resource_attributes_agent.yaml"
+
+	base := newLayerTestMetrics("aws_eks", "shared", "eks.only", "changed")
+	require.NoError(t, golden.WriteMetricsToFile(filepath.Join(baseDir, name), base))
+
+	actual := newLayerTestMetrics("azure.aks", "shared", "changed", "aks.only")
+	attrs := actual.ResourceMetrics().At(0).Resource().Attributes()
+	attrs.Remove("host.name")
+	attrs.PutStr("azure.vm.name", "abcd")
+	actual.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(1).Gauge().DataPoints().At(0).SetIntValue(2)
+	actual.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("extra", "resource")
+
+	require.NoError(t, WriteMetricsOverlay(baseDir, envDir, name, actual))
+	overlay, err := ReadMetricsOverlay(filepath.Join(envDir, OverlayFileName(name)))
+	require.NoError(t, err)
+	require.Len(t, overlay.Resources, 1)
+	require.Equal(t, []string{"host.name"}, overlay.Resources[0].RemoveAttributes)
+	require.Equal(t, map[string]any{"cloud.platform": "azure.aks", "azure.vm.name": "abcd"}, overlay.Resources[0].SetAttributes)
+	require.Equal(t, []string{"changed", "eks.only"}, overlay.Resources[0].RemoveMetrics)
+	require.Len(t, overlay.Resources[0].AddMetrics, 2)
+	require.Len(t, overlay.AppendResourceMetrics, 1)
+
+	layered, err := ReadLayeredMetrics(baseDir, envDir, name)
+	require.NoError(t, err)
+	require.NoError(t, pmetrictest.CompareMetrics(actual, layered, pmetrictest.IgnoreMetricsOrder()))
+
+	// Matching the base again removes the stale overlay.
+	require.NoError(t, WriteMetricsOverlay(baseDir, envDir, name, base))
+	_, err = os.Stat(filepath.Join(envDir, OverlayFileName(name)))
+	require.True(t, os.IsNotExist(err))
+
+	// A complete file in the environment directory wins over the base.
+	require.NoError(t, golden.WriteMetricsToFile(filepath.Join(envDir, name), actual))
+	layered, err = ReadLayeredMetrics(baseDir, envDir, name)
+	require.NoError(t, err)
+	require.NoError(t, pmetrictest.CompareMetrics(actual, layered))
+}
+
+func TestApplyMetricsOverlayRemovesResources(t *testing.T) {
+	t.Parallel()
+
+	md := newLayerTestMetrics("aws_eks")
+	md.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("second", "x")
+	require.NoError(t, ApplyMetricsOverlay(md, MetricsOverlay{Resources: []ResourceOverlay{{Index: 0, Remove: true}}}))
+	require.Equal(t, 1, md.ResourceMetrics().Len())
+	_, ok := md.ResourceMetrics().At(0).Resource().Attributes().Get("second")
+	require.True(t, ok)
+
+	require.ErrorContains(t, ApplyMetricsOverlay(md, MetricsOverlay{Resources: []ResourceOverlay{{Index: 3}}}), "out of range")
+}
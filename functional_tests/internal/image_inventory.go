@@ -0,0 +1,378 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/chart"
+	"helm.sh/helm/v4/pkg/chart/common/util"
+	"helm.sh/helm/v4/pkg/chart/loader"
+	releasev1 "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// instrumentationImageKeys are the per-language instrumentation values read by the operator
+// when it injects init containers into instrumented workloads.
+var instrumentationImageKeys = []string{"image", "secureAppImage"}
+
+// TestHelperImages returns images the suites start outside the chart.
+func TestHelperImages() []string {
+	return []string{fileCopyHelperImage}
+}
+
+// RenderChart renders the chart at chartPath client-side, like helm template, and returns the
+// manifest and the values coalesced with the chart defaults.
+func RenderChart(chartPath string, values map[string]any) (string, map[string]any, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return "", nil, err
+	}
+	accessor, err := chart.NewAccessor(chrt)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = action.CheckDependencies(chrt, accessor.MetaDependencies()); err != nil {
+		return "", nil, fmt.Errorf("%w (run make dep-update)", err)
+	}
+	install := action.NewInstall(action.NewConfiguration())
+	install.DryRunStrategy = action.DryRunClient
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.ReleaseName = DefaultChartReleaseName
+	install.Namespace = DefaultNamespace
+	rel, err := install.Run(chrt, values)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to render chart %s: %w", chartPath, err)
+	}
+	r, ok := rel.(*releasev1.Release)
+	if !ok {
+		return "", nil, fmt.Errorf("unexpected release type %T", rel)
+	}
+	manifest := r.Manifest
+	for _, hook := range r.Hooks {
+		manifest += "\n---\n" + hook.Manifest
+	}
+	coalesced, err := util.CoalesceValues(chrt, values)
+	if err != nil {
+		return "", nil, err
+	}
+	return manifest, coalesced, nil
+}
+
+// ChartImages lists the images a chart install pulls: images in the rendered manifest
+// (containers, custom resources, operator --*-image flags) and, when the operator is
+// enabled, the instrumentation images it injects into workloads.
+func ChartImages(manifest string, values map[string]any) ([]string, error) {
+	images, err := ImagesInManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	images = append(images, InstrumentationImages(values)...)
+	return uniqueSorted(images), nil
+}
+
+// ImagesInManifest collects every image reference of a multi-document YAML manifest.
+func ImagesInManifest(manifest string) ([]string, error) {
+	var images []string
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		collectImages(doc, &images)
+	}
+	return uniqueSorted(images), nil
+}
+
+func collectImages(node any, images *[]string) {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s, ok := value.(string); ok && key == "image" && s != "" {
+				*images = append(*images, s)
+				continue
+			}
+			collectImages(value, images)
+		}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				// Operator flags such as --auto-instrumentation-java-image=<ref>.
+				if flag, ref, found := strings.Cut(s, "="); found && strings.HasPrefix(flag, "--") && strings.HasSuffix(flag, "-image") && ref != "" {
+					*images = append(*images, ref)
+				}
+				continue
+			}
+			collectImages(item, images)
+		}
+	}
+}
+
+// InstrumentationImages returns the instrumentation library images configured under
+// instrumentation.spec when the operator and instrumentation are enabled.
+func InstrumentationImages(values map[string]any) []string {
+	if !nestedBool(values, "operator", "enabled") || !nestedBool(values, "instrumentation", "enabled") {
+		return nil
+	}
+	spec, _ := nestedValue(values, "instrumentation", "spec").(map[string]any)
+	var images []string
+	for _, lib := range spec {
+		libSpec, ok := lib.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range instrumentationImageKeys {
+			if image, ok := libSpec[key].(string); ok && image != "" {
+				images = append(images, image)
+			}
+		}
+	}
+	return uniqueSorted(images)
+}
+
+// NormalizeImageRef expands Docker Hub shorthands so that references from manifests and
+// archives compare equal, e.g. busybox:1.38.0 becomes docker.io/library/busybox:1.38.0.
+func NormalizeImageRef(ref string) string {
+	name, digest, hasDigest := strings.Cut(ref, "@")
+	first, _, hasSlash := strings.Cut(name, "/")
+	switch {
+	case !hasSlash:
+		name = "docker.io/library/" + name
+	case !strings.ContainsAny(first, ".:") && first != "localhost":
+		name = "docker.io/" + name
+	}
+	if !hasDigest && !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		name += ":latest"
+	}
+	if hasDigest {
+		return name + "@" + digest
+	}
+	return name
+}
+
+// ImageArchiveStore indexes the images available offline in a directory of docker-archive
+// or OCI archive tarballs and OCI layout directories.
+type ImageArchiveStore struct {
+	Dir   string
+	byRef map[string]string
+}
+
+// ScanImageArchives indexes dir. Archives are found at any depth; an OCI layout is a
+// directory containing an oci-layout file.
+func ScanImageArchives(dir string) (ImageArchiveStore, error) {
+	store := ImageArchiveStore{Dir: dir, byRef: map[string]string{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		var refs []string
+		switch {
+		case d.IsDir():
+			if _, statErr := os.Stat(filepath.Join(path, "oci-layout")); statErr != nil {
+				return nil
+			}
+			index, readErr := os.ReadFile(filepath.Join(path, "index.json"))
+			if readErr != nil {
+				return readErr
+			}
+			if refs, err = ociIndexRefs(index); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			store.add(refs, path)
+			return filepath.SkipDir
+		case strings.HasSuffix(d.Name(), ".tar"):
+			if refs, err = archiveRefs(path); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			store.add(refs, path)
+		}
+		return nil
+	})
+	return store, err
+}
+
+func (s ImageArchiveStore) add(refs []string, path string) {
+	for _, ref := range refs {
+		s.byRef[NormalizeImageRef(ref)] = path
+	}
+}
+
+// Lookup returns the archive or layout holding ref.
+func (s ImageArchiveStore) Lookup(ref string) (string, bool) {
+	path, ok := s.byRef[NormalizeImageRef(ref)]
+	return path, ok
+}
+
+// Check splits images into the archive paths that provide them and the missing images.
+func (s ImageArchiveStore) Check(images []string) (paths, missing []string) {
+	for _, image := range images {
+		if path, ok := s.Lookup(image); ok {
+			paths = append(paths, path)
+		} else {
+			missing = append(missing, image)
+		}
+	}
+	return uniqueSorted(paths), missing
+}
+
+// archiveRefs reads the image names of a docker-archive (manifest.json RepoTags) or an
+// OCI archive (index.json ref name annotations).
+func archiveRefs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	var refs []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch strings.TrimPrefix(hdr.Name, "./") {
+		case "manifest.json":
+			var manifests []struct {
+				RepoTags []string `json:"RepoTags"`
+			}
+			if err = json.NewDecoder(tr).Decode(&manifests); err != nil {
+				return nil, fmt.Errorf("failed to parse manifest.json: %w", err)
+			}
+			for _, m := range manifests {
+				refs = append(refs, m.RepoTags...)
+			}
+		case "index.json":
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			indexRefs, err := ociIndexRefs(data)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, indexRefs...)
+		}
+	}
+	return uniqueSorted(refs), nil
+}
+
+func ociIndexRefs(data []byte) ([]string, error) {
+	var index struct {
+		Manifests []struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index.json: %w", err)
+	}
+	var refs []string
+	for _, m := range index.Manifests {
+		// containerd and skopeo record the full reference; the OCI annotation may only hold the tag.
+		if name := m.Annotations["io.containerd.image.name"]; name != "" {
+			refs = append(refs, name)
+		} else if name = m.Annotations["org.opencontainers.image.ref.name"]; strings.ContainsAny(name, "/:") {
+			refs = append(refs, name)
+		}
+	}
+	return refs, nil
+}
+
+// TarOCILayout packs an OCI layout directory into an OCI archive under tmpDir, since the
+// cluster image loaders only accept archives.
+func TarOCILayout(layoutDir, tmpDir string) (string, error) {
+	out := filepath.Join(tmpDir, filepath.Base(layoutDir)+".tar")
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	tw := tar.NewWriter(f)
+	if err = tw.AddFS(os.DirFS(layoutDir)); err != nil {
+		return "", errors.Join(err, f.Close())
+	}
+	return out, errors.Join(tw.Close(), f.Close())
+}
+
+// PreloadChartImages renders the chart with values, fails the test listing every image the
+// IMAGE_ARCHIVE_DIR directory does not provide, and loads the archives into the cluster.
+// It is a no-op when IMAGE_ARCHIVE_DIR is not set.
+func PreloadChartImages(t *testing.T, chartPath string, values map[string]any) {
+	t.Helper()
+	dir := os.Getenv("IMAGE_ARCHIVE_DIR")
+	if dir == "" {
+		return
+	}
+	manifest, coalesced, err := RenderChart(chartPath, values)
+	require.NoError(t, err)
+	images, err := ChartImages(manifest, coalesced)
+	require.NoError(t, err)
+	store, err := ScanImageArchives(dir)
+	require.NoError(t, err)
+	paths, missing := store.Check(images)
+	require.Empty(t, missing, "images missing from IMAGE_ARCHIVE_DIR %s", dir)
+	require.NoError(t, LoadImageArchives(t.Context(), DefaultClusterProvider(t), t.TempDir(), paths))
+}
+
+// LoadImageArchives loads archives and OCI layouts into the cluster. OCI layouts are packed
+// into archives under tmpDir first.
+func LoadImageArchives(ctx context.Context, provider ClusterProvider, tmpDir string, paths []string) error {
+	var archives []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path, err = TarOCILayout(path, tmpDir); err != nil {
+				return err
+			}
+		}
+		archives = append(archives, path)
+	}
+	return provider.LoadImageArchives(ctx, archives...)
+}
+
+func nestedValue(values map[string]any, keys ...string) any {
+	var current any = values
+	for _, key := range keys {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+func nestedBool(values map[string]any, keys ...string) bool {
+	b, _ := nestedValue(values, keys...).(bool)
+	return b
+}
+
+func uniqueSorted(values []string) []string {
+	out := slices.Clone(values)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
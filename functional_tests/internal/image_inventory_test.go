@@ -0,0 +1,146 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChartImages(t *testing.T) {
+	t.Parallel()
+
+	manifest := `
+apiVersion: apps/v1
+kind: DaemonSet
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: busybox:1.38.0
+      containers:
+        - name: otel-collector
+          image: quay.io/signalfx/splunk-otel-collector:0.158.0
+---
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - name: manager
+          image: ghcr.io/open-telemetry/opentelemetry-operator/opentelemetry-operator:0.140.0
+          args:
+            - --auto-instrumentation-go-image=ghcr.io/open-telemetry/opentelemetry-go-instrumentation/autoinstrumentation-go:v0.24.0
+            - --enable-go-instrumentation=true
+---
+`
+	values := map[string]any{
+		"operator": map[string]any{"enabled": true},
+		"instrumentation": map[string]any{
+			"enabled": true,
+			"spec": map[string]any{
+				"java": map[string]any{
+					"image":          "ghcr.io/signalfx/splunk-otel-java/splunk-otel-java:v2.30.0",
+					"secureAppImage": "ghcr.io/signalfx/splunk-otel-java/splunk-otel-java-csa:v2.30.0",
+				},
+				"exporter": map[string]any{"endpoint": "http://agent:4317"},
+			},
+		},
+	}
+
+	images, err := ChartImages(manifest, values)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"busybox:1.38.0",
+		"ghcr.io/open-telemetry/opentelemetry-go-instrumentation/autoinstrumentation-go:v0.24.0",
+		"ghcr.io/open-telemetry/opentelemetry-operator/opentelemetry-operator:0.140.0",
+		"ghcr.io/signalfx/splunk-otel-java/splunk-otel-java-csa:v2.30.0",
+		"ghcr.io/signalfx/splunk-otel-java/splunk-otel-java:v2.30.0",
+		"quay.io/signalfx/splunk-otel-collector:0.158.0",
+	}, images)
+
+	values["operator"] = map[string]any{"enabled": false}
+	require.Empty(t, InstrumentationImages(values))
+}
+
+func TestNormalizeImageRef(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "busybox:1.38.0", want: "docker.io/library/busybox:1.38.0"},
+		{ref: "busybox", want: "docker.io/library/busybox:latest"},
+		{ref: "rock1017/log-generator:2.2.6", want: "docker.io/rock1017/log-generator:2.2.6"},
+		{ref: "quay.io/signalfx/splunk-otel-collector:0.158.0", want: "quay.io/signalfx/splunk-otel-collector:0.158.0"},
+		{ref: "localhost:5000/app", want: "localhost:5000/app:latest"},
+		{ref: "nginx@sha256:abc", want: "docker.io/library/nginx@sha256:abc"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.ref, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, testCase.want, NormalizeImageRef(testCase.ref))
+		})
+	}
+}
+
+func TestScanImageArchives(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTar(t, filepath.Join(dir, "busybox.tar"), map[string]string{
+		"manifest.json": `[{"RepoTags": ["busybox:1.38.0"]}]`,
+	})
+	writeTar(t, filepath.Join(dir, "nested", "collector.tar"), map[string]string{
+		"index.json": `{"manifests": [{"annotations": {"io.containerd.image.name": "quay.io/signalfx/splunk-otel-collector:0.158.0"}}]}`,
+	})
+	layout := filepath.Join(dir, "java-layout")
+	require.NoError(t, os.MkdirAll(filepath.Join(layout, "blobs"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(layout, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(layout, "index.json"),
+		[]byte(`{"manifests": [{"annotations": {"org.opencontainers.image.ref.name": "ghcr.io/signalfx/splunk-otel-java/splunk-otel-java:v2.30.0"}}]}`), 0o600))
+
+	store, err := ScanImageArchives(dir)
+	require.NoError(t, err)
+	paths, missing := store.Check([]string{
+		"docker.io/library/busybox:1.38.0",
+		"quay.io/signalfx/splunk-otel-collector:0.158.0",
+		"ghcr.io/signalfx/splunk-otel-java/splunk-otel-java:v2.30.0",
+		"nginx:stable-alpine3.23",
+	})
+	require.Equal(t, []string{"nginx:stable-alpine3.23"}, missing)
+	require.Equal(t, []string{
+		filepath.Join(dir, "busybox.tar"),
+		filepath.Join(dir, "java-layout"),
+		filepath.Join(dir, "nested", "collector.tar"),
+	}, paths)
+
+	archive, err := TarOCILayout(layout, t.TempDir())
+	require.NoError(t, err)
+	refs, err := archiveRefs(archive)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ghcr.io/signalfx/splunk-otel-java/splunk-otel-java:v2.30.0"}, refs)
+}
+
+func writeTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+}
@@ -0,0 +1,128 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KindCluster describes a kind cluster created for a functional test run.
+type KindCluster struct {
+	Name string
+	// Version is the Kubernetes version of the kindest/node image, e.g. v1.36.1.
+	Version string
+	// ConfigPath is the kind cluster config, normally .github/workflows/configs/kind-config.yaml.
+	ConfigPath string
+	// KubeConfig is the file kind writes the cluster credentials to.
+	KubeConfig string
+}
+
+// NodeImage returns the kindest/node image for the cluster version.
+func (c KindCluster) NodeImage() string {
+	return "kindest/node:" + c.Version
+}
+
+// Exists reports whether a kind cluster with the same name is already running.
+func (c KindCluster) Exists(ctx context.Context) (bool, error) {
+	out, err := commandOutput(ctx, "kind", "get", "clusters")
+	if err != nil {
+		return false, err
+	}
+	for _, name := range strings.Fields(out) {
+		if name == c.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Create creates the cluster and waits for the control plane to be ready.
+func (c KindCluster) Create(ctx context.Context) error {
+	args := []string{"create", "cluster", "--name", c.Name, "--image", c.NodeImage(), "--kubeconfig", c.KubeConfig, "--wait", "5m"}
+	if c.ConfigPath != "" {
+		args = append(args, "--config", c.ConfigPath)
+	}
+	return runCommand(ctx, "kind", args...)
+}
+
+// Delete deletes the cluster. Deleting a cluster that does not exist is not an error.
+func (c KindCluster) Delete(ctx context.Context) error {
+	return runCommand(ctx, "kind", "delete", "cluster", "--name", c.Name, "--kubeconfig", c.KubeConfig)
+}
+
+// ApproveKubeletServingCSRs approves the pending kubelet serving certificate requests until every
+// node has an approved one. The kind config enables serverTLSBootstrap so that the kubelet stats
+// receiver can verify kubelet certificates, which leaves those requests waiting for approval.
+func ApproveKubeletServingCSRs(ctx context.Context, clientset kubernetes.Interface, timeout time.Duration) error {
+	var pendingNodes []string
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		csrs, err := clientset.CertificatesV1().CertificateSigningRequests().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		approved := map[string]bool{}
+		for i := range csrs.Items {
+			csr := &csrs.Items[i]
+			if csr.Spec.SignerName != certificatesv1.KubeletServingSignerName {
+				continue
+			}
+			if !csrDecided(csr) {
+				csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+					Type:    certificatesv1.CertificateApproved,
+					Status:  v1.ConditionTrue,
+					Reason:  "FunctionalTestApprove",
+					Message: "approved by the functional test cluster setup",
+				})
+				if _, err = clientset.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{}); err != nil {
+					return false, fmt.Errorf("failed to approve CSR %s: %w", csr.Name, err)
+				}
+			}
+			if csrApproved(csr) {
+				approved[strings.TrimPrefix(csr.Spec.Username, "system:node:")] = true
+			}
+		}
+		pendingNodes = pendingNodes[:0]
+		for _, node := range nodes.Items {
+			if !approved[node.Name] {
+				pendingNodes = append(pendingNodes, node.Name)
+			}
+		}
+		return len(nodes.Items) > 0 && len(pendingNodes) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("kubelet serving certificates not approved for nodes %v: %w", pendingNodes, err)
+	}
+	return nil
+}
+
+func csrApproved(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, cond := range csr.Status.Conditions {
+		if cond.Type == certificatesv1.CertificateApproved && cond.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func csrDecided(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, cond := range csr.Status.Conditions {
+		if cond.Type == certificatesv1.CertificateApproved || cond.Type == certificatesv1.CertificateDenied {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApproveKubeletServingCSRs(t *testing.T) {
+	t.Parallel()
+
+	csr := func(name, signer, user string, conditions ...certificatesv1.RequestConditionType) *certificatesv1.CertificateSigningRequest {
+		c := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       certificatesv1.CertificateSigningRequestSpec{SignerName: signer, Username: user},
+		}
+		for _, cond := range conditions {
+			c.Status.Conditions = append(c.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{Type: cond, Status: v1.ConditionTrue})
+		}
+		return c
+	}
+	clientset := fake.NewClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "kind-control-plane"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "kind-worker"}},
+		csr("serving-cp", certificatesv1.KubeletServingSignerName, "system:node:kind-control-plane"),
+		csr("serving-worker-denied", certificatesv1.KubeletServingSignerName, "system:node:kind-worker", certificatesv1.CertificateDenied),
+		csr("serving-worker", certificatesv1.KubeletServingSignerName, "system:node:kind-worker"),
+		csr("client", certificatesv1.KubeAPIServerClientKubeletSignerName, "system:node:kind-worker"),
+	)
+
+	require.NoError(t, ApproveKubeletServingCSRs(t.Context(), clientset, 10*time.Second))
+
+	csrs, err := clientset.CertificatesV1().CertificateSigningRequests().List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	approved := map[string]bool{}
+	for i := range csrs.Items {
+		approved[csrs.Items[i].Name] = csrApproved(&csrs.Items[i])
+	}
+	require.Equal(t, map[string]bool{
+		"serving-cp":            true,
+		"serving-worker-denied": false,
+		"serving-worker":        true,
+		"client":                false,
+	}, approved)
+}
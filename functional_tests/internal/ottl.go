@@ -0,0 +1,75 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottldatapoint"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottllog"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+// OTTL conditions are parsed and evaluated by the collector's pkg/ottl with the standard
+// converters, so a condition behaves here as it does in a filter or transform processor:
+// log records are evaluated in the log context, datapoints in the datapoint context, e.g.
+// metric.name and value_int, and spans in the span context.
+
+// ottlTransformContext is the pooled context of a row, released with Close once evaluated.
+type ottlTransformContext interface {
+	Close()
+}
+
+type ottlParserFactory[K ottlTransformContext] func(map[string]ottl.Factory[K], component.TelemetrySettings, ...ottl.Option[K]) (ottl.Parser[K], error)
+
+// ottlSignal evaluates OTTL conditions against the rows of a signal.
+type ottlSignal[T any, K ottlTransformContext] struct {
+	newParser  ottlParserFactory[K]
+	newContext func(T) K
+}
+
+var (
+	ottlLogs = ottlSignal[LogRow, *ottllog.TransformContext]{
+		newParser: ottllog.NewParser,
+		newContext: func(r LogRow) *ottllog.TransformContext {
+			return ottllog.NewTransformContextPtr(r.resourceLogs, r.scopeLogs, r.Record)
+		},
+	}
+	ottlDatapoints = ottlSignal[MetricRow, *ottldatapoint.TransformContext]{
+		newParser: ottldatapoint.NewParser,
+		newContext: func(r MetricRow) *ottldatapoint.TransformContext {
+			return ottldatapoint.NewTransformContextPtr(r.resourceMetrics, r.scopeMetrics, r.Metric, r.dataPoint)
+		},
+	}
+	ottlSpans = ottlSignal[SpanRow, *ottlspan.TransformContext]{
+		newParser: ottlspan.NewParser,
+		newContext: func(r SpanRow) *ottlspan.TransformContext {
+			return ottlspan.NewTransformContextPtr(r.resourceSpans, r.scopeSpans, r.Span)
+		},
+	}
+)
+
+func (s ottlSignal[T, K]) parse(condition string) (*ottl.Condition[K], error) {
+	parser, err := s.newParser(ottlfuncs.StandardConverters[K](), componenttest.NewNopTelemetrySettings())
+	if err != nil {
+		return nil, err
+	}
+	cond, err := parser.ParseCondition(condition)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", condition, err)
+	}
+	return cond, nil
+}
+
+// eval reports whether row satisfies cond.
+func (s ottlSignal[T, K]) eval(ctx context.Context, cond *ottl.Condition[K], row T) (bool, error) {
+	tCtx := s.newContext(row)
+	defer tCtx.Close()
+	return cond.Eval(ctx, tCtx)
+}
@@ -0,0 +1,114 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type ottlTestCase struct {
+	condition string
+	want      bool
+	wantErr   string
+}
+
+func runOTTLTestCases[T ottlRow, K ottlTransformContext](t *testing.T, signal ottlSignal[T, K], row T, testCases []ottlTestCase) {
+	for _, testCase := range testCases {
+		t.Run(testCase.condition, func(t *testing.T) {
+			t.Parallel()
+
+			matched, _, err := matchOTTL(t.Context(), signal, testCase.condition, []T{row})
+			if testCase.wantErr != "" {
+				require.ErrorContains(t, err, testCase.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.want, len(matched) == 1)
+		})
+	}
+}
+
+func TestOTTLConditionLogs(t *testing.T) {
+	t.Parallel()
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("com.splunk.index", "main")
+	rl.Resource().Attributes().PutStr("k8s.namespace.name", "default")
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.Scope().SetName("scope")
+	lr := sl.LogRecords().AppendEmpty()
+	lr.Body().SetStr("Hello World")
+	lr.SetSeverityText("INFO")
+	lr.Attributes().PutStr("k8s.pod.name", "nodejs-test-abc")
+	lr.Attributes().PutInt("retries", 3)
+	lr.Attributes().PutDouble("ratio", 0.5)
+	lr.Attributes().PutEmptyMap("nested").PutStr("inner", "x")
+	rows := QueryLogs([]plog.Logs{ld})
+	require.Len(t, rows, 1)
+
+	runOTTLTestCases(t, ottlLogs, rows[0], []ottlTestCase{
+		{condition: `attributes["k8s.pod.name"] != nil`, want: true},
+		{condition: `attributes["missing"] == nil`, want: true},
+		{condition: `resource.attributes["com.splunk.index"] == "main" and resource.attributes["k8s.namespace.name"] == "default"`, want: true},
+		{condition: `resource.attributes["com.splunk.index"] == "other" or body == "Hello World"`, want: true},
+		{condition: `not (severity_text == "INFO")`, want: false},
+		{condition: `attributes["retries"] >= 3 and attributes["ratio"] < 1`, want: true},
+		{condition: `attributes["nested"]["inner"] == "x"`, want: true},
+		{condition: `IsMatch(attributes["k8s.pod.name"], "^nodejs-test-.*")`, want: true},
+		{condition: `IsString(body) and Len(body) == 11`, want: true},
+		{condition: `ToUpperCase(body) == "HELLO WORLD"`, want: true},
+		{condition: `instrumentation_scope.name == "scope"`, want: true},
+		{condition: `unknown.path == 1`, wantErr: `parsing "unknown.path == 1"`},
+		{condition: `attributes["k8s.pod.name"] = "x"`, wantErr: `parsing`},
+		{condition: `(body == "x"`, wantErr: `parsing`},
+	})
+}
+
+func TestOTTLConditionDatapoints(t *testing.T) {
+	t.Parallel()
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("k8s.namespace.name", "default")
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("k8s.pod.phase")
+	dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(3.5)
+	dp.Attributes().PutStr("phase", "running")
+	rows := QueryMetrics([]pmetric.Metrics{md})
+	require.Len(t, rows, 1)
+
+	runOTTLTestCases(t, ottlDatapoints, rows[0], []ottlTestCase{
+		{condition: `metric.name == "k8s.pod.phase" and resource.attributes["k8s.namespace.name"] == "default"`, want: true},
+		{condition: `attributes["phase"] == "running"`, want: true},
+		{condition: `value_double == 3.5`, want: true},
+		// A double datapoint has no int value, as in the collector.
+		{condition: `value_int == 3`, want: false},
+		{condition: `name == "k8s.pod.phase"`, wantErr: `parsing "name == \"k8s.pod.phase\""`},
+	})
+}
+
+func TestOTTLConditionSpans(t *testing.T) {
+	t.Parallel()
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("GET /")
+	span.SetKind(ptrace.SpanKindServer)
+	span.Attributes().PutInt("http.response.status_code", 200)
+	rows := QuerySpans([]ptrace.Traces{td})
+	require.Len(t, rows, 1)
+
+	runOTTLTestCases(t, ottlSpans, rows[0], []ottlTestCase{
+		{condition: `name == "GET /" and kind == SPAN_KIND_SERVER`, want: true},
+		{condition: `attributes["http.response.status_code"] == 200`, want: true},
+		{condition: `kind.string == "Client"`, want: false},
+	})
+}
@@ -0,0 +1,127 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+)
+
+// maxReportedOTTLMismatches bounds how many records an OTTL assertion prints on failure.
+const maxReportedOTTLMismatches = 5
+
+func (r LogRow) describe() string {
+	return fmt.Sprintf("resource={%s} attributes={%s} body=%q",
+		FormatAttributes(r.Resource.Attributes()), FormatAttributes(r.Attributes), r.Record.Body().AsString())
+}
+
+func (r MetricRow) describe() string {
+	return fmt.Sprintf("metric=%s resource={%s} attributes={%s}",
+		r.Metric.Name(), FormatAttributes(r.Resource.Attributes()), FormatAttributes(r.Attributes))
+}
+
+func (r SpanRow) describe() string {
+	return fmt.Sprintf("span=%s kind=%s resource={%s} attributes={%s}",
+		r.Span.Name(), r.Span.Kind(), FormatAttributes(r.Resource.Attributes()), FormatAttributes(r.Attributes))
+}
+
+type ottlRow interface {
+	describe() string
+}
+
+// matchOTTL evaluates condition against rows and returns the matching and
+// non-matching rows in order.
+func matchOTTL[T ottlRow, K ottlTransformContext](ctx context.Context, signal ottlSignal[T, K], condition string, rows []T) (matched, unmatched []T, err error) {
+	cond, err := signal.parse(condition)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		ok, err := signal.eval(ctx, cond, row)
+		if err != nil {
+			return nil, nil, fmt.Errorf("evaluating %q against %s: %w", condition, row.describe(), err)
+		}
+		if ok {
+			matched = append(matched, row)
+		} else {
+			unmatched = append(unmatched, row)
+		}
+	}
+	return matched, unmatched, nil
+}
+
+func describeRows[T ottlRow](rows []T) string {
+	var sb strings.Builder
+	for i, row := range rows {
+		if i == maxReportedOTTLMismatches {
+			fmt.Fprintf(&sb, "\n  ... and %d more", len(rows)-i)
+			break
+		}
+		sb.WriteString("\n  ")
+		sb.WriteString(row.describe())
+	}
+	return sb.String()
+}
+
+func assertAllOTTL[T ottlRow, K ottlTransformContext](t *testing.T, signal ottlSignal[T, K], kind, condition string, rows []T) {
+	t.Helper()
+	require.NotEmpty(t, rows, "no %s to evaluate %q against", kind, condition)
+	_, unmatched, err := matchOTTL(t.Context(), signal, condition, rows)
+	require.NoError(t, err)
+	require.Empty(t, unmatched, "%d of %d %s do not match %q:%s",
+		len(unmatched), len(rows), kind, condition, describeRows(unmatched))
+}
+
+func assertAnyOTTL[T ottlRow, K ottlTransformContext](t *testing.T, signal ottlSignal[T, K], kind, condition string, rows []T) {
+	t.Helper()
+	matched, _, err := matchOTTL(t.Context(), signal, condition, rows)
+	require.NoError(t, err)
+	require.NotEmpty(t, matched, "none of %d %s match %q; first records:%s",
+		len(rows), kind, condition, describeRows(rows))
+}
+
+// AssertAllLogs requires every log record in sink selected by opts to satisfy the
+// OTTL condition of the log context, e.g. `attributes["com.splunk.index"] != nil`.
+func AssertAllLogs(t *testing.T, sink *consumertest.LogsSink, condition string, opts ...QueryOption) {
+	t.Helper()
+	assertAllOTTL(t, ottlLogs, "log records", condition, QueryLogs(sink.AllLogs(), opts...))
+}
+
+// AssertAnyLog requires at least one log record in sink selected by opts to satisfy the OTTL condition.
+func AssertAnyLog(t *testing.T, sink *consumertest.LogsSink, condition string, opts ...QueryOption) {
+	t.Helper()
+	assertAnyOTTL(t, ottlLogs, "log records", condition, QueryLogs(sink.AllLogs(), opts...))
+}
+
+// AssertAllMetrics requires every datapoint in sink selected by opts to satisfy the OTTL
+// condition of the datapoint context, e.g.
+// `metric.name == "k8s.pod.phase" and resource.attributes["k8s.namespace.name"] == "default"`.
+func AssertAllMetrics(t *testing.T, sink *consumertest.MetricsSink, condition string, opts ...QueryOption) {
+	t.Helper()
+	assertAllOTTL(t, ottlDatapoints, "datapoints", condition, QueryMetrics(sink.AllMetrics(), opts...))
+}
+
+// AssertAnyMetric requires at least one datapoint in sink selected by opts to satisfy the OTTL condition.
+func AssertAnyMetric(t *testing.T, sink *consumertest.MetricsSink, condition string, opts ...QueryOption) {
+	t.Helper()
+	assertAnyOTTL(t, ottlDatapoints, "datapoints", condition, QueryMetrics(sink.AllMetrics(), opts...))
+}
+
+// AssertAllSpans requires every span in sink selected by opts to satisfy the OTTL condition
+// of the span context.
+func AssertAllSpans(t *testing.T, sink *consumertest.TracesSink, condition string, opts ...QueryOption) {
+	t.Helper()
+	assertAllOTTL(t, ottlSpans, "spans", condition, QuerySpans(sink.AllTraces(), opts...))
+}
+
+// AssertAnySpan requires at least one span in sink selected by opts to satisfy the OTTL condition.
+func AssertAnySpan(t *testing.T, sink *consumertest.TracesSink, condition string, opts ...QueryOption) {
+	t.Helper()
+	assertAnyOTTL(t, ottlSpans, "spans", condition, QuerySpans(sink.AllTraces(), opts...))
+}
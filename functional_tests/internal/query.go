@@ -0,0 +1,382 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// QueryRow is the part of a query result shared by all signals. Attributes are the
+// log record, datapoint or span attributes.
+type QueryRow struct {
+	Resource   pcommon.Resource
+	Scope      pcommon.InstrumentationScope
+	Attributes pcommon.Map
+	Timestamp  pcommon.Timestamp
+}
+
+// ResourceAttr returns the string form of a resource attribute.
+func (r QueryRow) ResourceAttr(key string) (string, bool) {
+	v, ok := r.Resource.Attributes().Get(key)
+	if !ok {
+		return "", false
+	}
+	return v.AsString(), true
+}
+
+// Attr returns the string form of a record-level attribute.
+func (r QueryRow) Attr(key string) (string, bool) {
+	v, ok := r.Attributes.Get(key)
+	if !ok {
+		return "", false
+	}
+	return v.AsString(), true
+}
+
+// LogRow is a single log record returned by QueryLogs.
+type LogRow struct {
+	QueryRow
+	Record plog.LogRecord
+
+	resourceLogs plog.ResourceLogs
+	scopeLogs    plog.ScopeLogs
+}
+
+// MetricRow is a single datapoint returned by QueryMetrics. Value holds the number
+// for gauges and sums and the sum for histograms and summaries.
+type MetricRow struct {
+	QueryRow
+	Metric pmetric.Metric
+	Value  float64
+
+	resourceMetrics pmetric.ResourceMetrics
+	scopeMetrics    pmetric.ScopeMetrics
+	// dataPoint is the pmetric datapoint, e.g. a pmetric.NumberDataPoint.
+	dataPoint any
+}
+
+// SpanRow is a single span returned by QuerySpans.
+type SpanRow struct {
+	QueryRow
+	Span ptrace.Span
+
+	resourceSpans ptrace.ResourceSpans
+	scopeSpans    ptrace.ScopeSpans
+}
+
+type queryConfig struct {
+	resourceAttrs   map[string]string
+	recordAttrs     map[string]string
+	recordAttrKeys  []string
+	scopeName       string
+	logBody         string
+	names           map[string]struct{}
+	excludePrefixes []string
+	start, end      time.Time
+}
+
+// QueryOption narrows the records returned by QueryLogs, QueryMetrics and QuerySpans.
+type QueryOption func(*queryConfig)
+
+// WithResourceAttribute keeps records whose resource has key set to value.
+func WithResourceAttribute(key, value string) QueryOption {
+	return func(cfg *queryConfig) {
+		if cfg.resourceAttrs == nil {
+			cfg.resourceAttrs = map[string]string{}
+		}
+		cfg.resourceAttrs[key] = value
+	}
+}
+
+// WithRecordAttribute keeps records whose log record, datapoint or span attributes
+// have key set to value.
+func WithRecordAttribute(key, value string) QueryOption {
+	return func(cfg *queryConfig) {
+		if cfg.recordAttrs == nil {
+			cfg.recordAttrs = map[string]string{}
+		}
+		cfg.recordAttrs[key] = value
+	}
+}
+
+// WithRecordAttributeKey keeps records that carry key as a record-level attribute, whatever its value.
+func WithRecordAttributeKey(key string) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.recordAttrKeys = append(cfg.recordAttrKeys, key)
+	}
+}
+
+// WithScopeName keeps records emitted under the named instrumentation scope.
+func WithScopeName(name string) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.scopeName = name
+	}
+}
+
+// WithLogBody keeps log records whose body is body. It has no effect on metrics and spans.
+func WithLogBody(body string) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.logBody = body
+	}
+}
+
+// WithNames keeps metrics or spans with one of the given names. It has no effect on logs.
+func WithNames(names ...string) QueryOption {
+	return func(cfg *queryConfig) {
+		if cfg.names == nil {
+			cfg.names = map[string]struct{}{}
+		}
+		for _, name := range names {
+			cfg.names[name] = struct{}{}
+		}
+	}
+}
+
+// WithoutNamePrefixes drops metrics or spans whose name starts with any of prefixes.
+func WithoutNamePrefixes(prefixes ...string) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.excludePrefixes = append(cfg.excludePrefixes, prefixes...)
+	}
+}
+
+// WithTimeWindow keeps records timestamped within [start, end]. A zero bound is open.
+// Logs without a timestamp are matched on their observed timestamp.
+func WithTimeWindow(start, end time.Time) QueryOption {
+	return func(cfg *queryConfig) {
+		cfg.start, cfg.end = start, end
+	}
+}
+
+func newQueryConfig(opts []QueryOption) queryConfig {
+	var cfg queryConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (cfg queryConfig) matchResource(res pcommon.Resource) bool {
+	return mapHasAll(res.Attributes(), cfg.resourceAttrs)
+}
+
+func (cfg queryConfig) matchScope(scope pcommon.InstrumentationScope) bool {
+	return cfg.scopeName == "" || scope.Name() == cfg.scopeName
+}
+
+func (cfg queryConfig) matchName(name string) bool {
+	for _, prefix := range cfg.excludePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	if cfg.names == nil {
+		return true
+	}
+	_, ok := cfg.names[name]
+	return ok
+}
+
+func (cfg queryConfig) matchRecord(attrs pcommon.Map, ts pcommon.Timestamp) bool {
+	if !mapHasAll(attrs, cfg.recordAttrs) {
+		return false
+	}
+	for _, key := range cfg.recordAttrKeys {
+		if _, ok := attrs.Get(key); !ok {
+			return false
+		}
+	}
+	if !cfg.start.IsZero() && ts.AsTime().Before(cfg.start) {
+		return false
+	}
+	if !cfg.end.IsZero() && ts.AsTime().After(cfg.end) {
+		return false
+	}
+	return true
+}
+
+func mapHasAll(attrs pcommon.Map, want map[string]string) bool {
+	for k, v := range want {
+		got, ok := attrs.Get(k)
+		if !ok || got.AsString() != v {
+			return false
+		}
+	}
+	return true
+}
+
+// QueryLogs returns the log records in batches that match every option.
+func QueryLogs(batches []plog.Logs, opts ...QueryOption) []LogRow {
+	cfg := newQueryConfig(opts)
+	var rows []LogRow
+	for _, ld := range batches {
+		for i := 0; i < ld.ResourceLogs().Len(); i++ {
+			rl := ld.ResourceLogs().At(i)
+			if !cfg.matchResource(rl.Resource()) {
+				continue
+			}
+			for j := 0; j < rl.ScopeLogs().Len(); j++ {
+				sl := rl.ScopeLogs().At(j)
+				if !cfg.matchScope(sl.Scope()) {
+					continue
+				}
+				for k := 0; k < sl.LogRecords().Len(); k++ {
+					lr := sl.LogRecords().At(k)
+					ts := lr.Timestamp()
+					if ts == 0 {
+						ts = lr.ObservedTimestamp()
+					}
+					if !cfg.matchRecord(lr.Attributes(), ts) || (cfg.logBody != "" && lr.Body().AsString() != cfg.logBody) {
+						continue
+					}
+					rows = append(rows, LogRow{
+						QueryRow: QueryRow{Resource: rl.Resource(), Scope: sl.Scope(), Attributes: lr.Attributes(), Timestamp: ts},
+						Record:   lr,
+
+						resourceLogs: rl,
+						scopeLogs:    sl,
+					})
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// QueryMetrics returns one row per datapoint in batches that matches every option.
+func QueryMetrics(batches []pmetric.Metrics, opts ...QueryOption) []MetricRow {
+	cfg := newQueryConfig(opts)
+	var rows []MetricRow
+	for _, md := range batches {
+		for i := 0; i < md.ResourceMetrics().Len(); i++ {
+			rm := md.ResourceMetrics().At(i)
+			if !cfg.matchResource(rm.Resource()) {
+				continue
+			}
+			for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+				sm := rm.ScopeMetrics().At(j)
+				if !cfg.matchScope(sm.Scope()) {
+					continue
+				}
+				for k := 0; k < sm.Metrics().Len(); k++ {
+					metric := sm.Metrics().At(k)
+					if !cfg.matchName(metric.Name()) {
+						continue
+					}
+					forEachDatapoint(metric, func(dp any, attrs pcommon.Map, ts pcommon.Timestamp, value float64) {
+						if !cfg.matchRecord(attrs, ts) {
+							return
+						}
+						rows = append(rows, MetricRow{
+							QueryRow: QueryRow{Resource: rm.Resource(), Scope: sm.Scope(), Attributes: attrs, Timestamp: ts},
+							Metric:   metric,
+							Value:    value,
+
+							resourceMetrics: rm,
+							scopeMetrics:    sm,
+							dataPoint:       dp,
+						})
+					})
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// QuerySpans returns the spans in batches that match every option.
+func QuerySpans(batches []ptrace.Traces, opts ...QueryOption) []SpanRow {
+	cfg := newQueryConfig(opts)
+	var rows []SpanRow
+	for _, td := range batches {
+		for i := 0; i < td.ResourceSpans().Len(); i++ {
+			rs := td.ResourceSpans().At(i)
+			if !cfg.matchResource(rs.Resource()) {
+				continue
+			}
+			for j := 0; j < rs.ScopeSpans().Len(); j++ {
+				ss := rs.ScopeSpans().At(j)
+				if !cfg.matchScope(ss.Scope()) {
+					continue
+				}
+				for k := 0; k < ss.Spans().Len(); k++ {
+					span := ss.Spans().At(k)
+					if !cfg.matchName(span.Name()) || !cfg.matchRecord(span.Attributes(), span.StartTimestamp()) {
+						continue
+					}
+					rows = append(rows, SpanRow{
+						QueryRow: QueryRow{Resource: rs.Resource(), Scope: ss.Scope(), Attributes: span.Attributes(), Timestamp: span.StartTimestamp()},
+						Span:     span,
+
+						resourceSpans: rs,
+						scopeSpans:    ss,
+					})
+				}
+			}
+		}
+	}
+	return rows
+}
+
+func numberValue(dp pmetric.NumberDataPoint) float64 {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		return float64(dp.IntValue())
+	}
+	return dp.DoubleValue()
+}
+
+func forEachDatapoint(metric pmetric.Metric, fn func(dp any, attrs pcommon.Map, ts pcommon.Timestamp, value float64)) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			dp := metric.Gauge().DataPoints().At(i)
+			fn(dp, dp.Attributes(), dp.Timestamp(), numberValue(dp))
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			dp := metric.Sum().DataPoints().At(i)
+			fn(dp, dp.Attributes(), dp.Timestamp(), numberValue(dp))
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			dp := metric.Histogram().DataPoints().At(i)
+			fn(dp, dp.Attributes(), dp.Timestamp(), dp.Sum())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			dp := metric.ExponentialHistogram().DataPoints().At(i)
+			fn(dp, dp.Attributes(), dp.Timestamp(), dp.Sum())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			dp := metric.Summary().DataPoints().At(i)
+			fn(dp, dp.Attributes(), dp.Timestamp(), dp.Sum())
+		}
+	case pmetric.MetricTypeEmpty:
+	}
+}
+
+// DistinctValues returns the distinct values extracted from rows, in first-seen order.
+// Rows for which value reports false are skipped.
+func DistinctValues[T any](rows []T, value func(T) (string, bool)) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, row := range rows {
+		v, ok := value(row)
+		if !ok {
+			continue
+		}
+		if _, dup := seen[v]; dup {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestQueryLogs(t *testing.T) {
+	t.Parallel()
+
+	base := time.Unix(1_700_000_000, 0)
+	ld := plog.NewLogs()
+	for i, index := range []string{"main", "main", "other"} {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("com.splunk.index", index)
+		sl := rl.ScopeLogs().AppendEmpty()
+		sl.Scope().SetName("scope")
+		lr := sl.LogRecords().AppendEmpty()
+		lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(base.Add(time.Duration(i) * time.Minute)))
+		lr.Attributes().PutInt("seq", int64(i))
+		lr.Body().SetStr("line " + strconv.Itoa(i))
+	}
+
+	testCases := []struct {
+		name    string
+		opts    []QueryOption
+		wantSeq []string
+	}{
+		{name: "no filter", wantSeq: []string{"0", "1", "2"}},
+		{name: "resource attribute", opts: []QueryOption{WithResourceAttribute("com.splunk.index", "other")}, wantSeq: []string{"2"}},
+		{name: "record attribute", opts: []QueryOption{WithRecordAttribute("seq", "1")}, wantSeq: []string{"1"}},
+		{name: "missing record attribute key", opts: []QueryOption{WithRecordAttributeKey("absent")}},
+		{name: "log body", opts: []QueryOption{WithLogBody("line 2")}, wantSeq: []string{"2"}},
+		{name: "other scope", opts: []QueryOption{WithScopeName("nope")}},
+		{name: "time window", opts: []QueryOption{WithTimeWindow(base.Add(30*time.Second), time.Time{})}, wantSeq: []string{"1", "2"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			rows := QueryLogs([]plog.Logs{ld}, testCase.opts...)
+			require.Equal(t, testCase.wantSeq, DistinctValues(rows, func(r LogRow) (string, bool) { return r.Attr("seq") }))
+		})
+	}
+
+	require.Equal(t, []string{"main", "other"},
+		DistinctValues(QueryLogs([]plog.Logs{ld}), func(r LogRow) (string, bool) { return r.ResourceAttr("com.splunk.index") }))
+}
+
+func TestQueryMetrics(t *testing.T) {
+	t.Parallel()
+
+	md := pmetric.NewMetrics()
+	ms := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	gauge := ms.AppendEmpty()
+	gauge.SetName("k8s.pod.cpu.utilization")
+	gauge.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(3)
+	sum := ms.AppendEmpty()
+	sum.SetName("system.cpu.time")
+	sum.SetEmptySum().DataPoints().AppendEmpty().SetDoubleValue(1.5)
+
+	rows := QueryMetrics([]pmetric.Metrics{md})
+	require.Len(t, rows, 2)
+	require.InDelta(t, 3.0, rows[0].Value, 0)
+	require.InDelta(t, 1.5, rows[1].Value, 0)
+
+	rows = QueryMetrics([]pmetric.Metrics{md}, WithoutNamePrefixes("system."))
+	require.Len(t, rows, 1)
+	require.Equal(t, "k8s.pod.cpu.utilization", rows[0].Metric.Name())
+
+	require.Len(t, QueryMetrics([]pmetric.Metrics{md}, WithNames("system.cpu.time")), 1)
+}
@@ -0,0 +1,310 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/chart"
+	"helm.sh/helm/v4/pkg/chart/common"
+	"helm.sh/helm/v4/pkg/chart/common/util"
+	"helm.sh/helm/v4/pkg/chart/loader"
+	chartv2 "helm.sh/helm/v4/pkg/chart/v2"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+	"helm.sh/helm/v4/pkg/engine"
+)
+
+// APIChange is a deprecation or removal of a served API version of a kind. Versions are
+// Kubernetes minor versions such as 1.25; RemovedIn is empty while the version is still served.
+type APIChange struct {
+	APIVersion   string
+	Kind         string
+	DeprecatedIn string
+	RemovedIn    string
+	Replacement  string
+}
+
+// APIChanges lists the deprecated and removed APIs of
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/ a chart could create.
+var APIChanges = []APIChange{
+	{"extensions/v1beta1", "DaemonSet", "1.8", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "Deployment", "1.8", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", "1.8", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", "1.9", "1.16", "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", "1.10", "1.16", "policy/v1beta1"},
+	{"extensions/v1beta1", "Ingress", "1.14", "1.22", "networking.k8s.io/v1"},
+	{"apps/v1beta1", "ControllerRevision", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "ControllerRevision", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"authentication.k8s.io/v1beta1", "TokenReview", "1.19", "1.22", "authentication.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "LocalSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SelfSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", "1.14", "1.22", "coordination.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", "1.17", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", "1.6", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", "1.13", "1.22", "storage.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", "1.19", "1.25", "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "1.22", "1.25", "autoscaling/v2"},
+	{"policy/v1beta1", "PodDisruptionBudget", "1.21", "1.25", "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", "1.21", "1.25", "Pod Security Admission"},
+	{"node.k8s.io/v1beta1", "RuntimeClass", "1.20", "1.25", "node.k8s.io/v1"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.23", "1.26", "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", "1.24", "1.27", "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"v1", "Endpoints", "1.33", "", "discovery.k8s.io/v1 EndpointSlice"},
+}
+
+// APIIntroductions maps the API versions added in a Kubernetes version a chart may still have to
+// support, or gate on, to that version. Older API versions are served by every cluster.
+var APIIntroductions = map[string]string{
+	"admissionregistration.k8s.io/v1":      "1.16",
+	"apiextensions.k8s.io/v1":              "1.16",
+	"certificates.k8s.io/v1":               "1.19",
+	"events.k8s.io/v1":                     "1.19",
+	"node.k8s.io/v1":                       "1.20",
+	"discovery.k8s.io/v1":                  "1.21",
+	"policy/v1":                            "1.21",
+	"autoscaling/v2":                       "1.23",
+	"flowcontrol.apiserver.k8s.io/v1beta3": "1.26",
+	"flowcontrol.apiserver.k8s.io/v1":      "1.29",
+	"resource.k8s.io/v1":                   "1.34",
+}
+
+// APIFinding is an object of a rendered chart using a deprecated or removed API.
+type APIFinding struct {
+	KubeVersion string
+	Template    string
+	Name        string
+	Change      APIChange
+	// Removed is set when the cluster does not serve the API anymore, so the object won't apply.
+	Removed bool
+}
+
+func (f APIFinding) String() string {
+	state := "deprecated since " + f.Change.DeprecatedIn
+	if f.Removed {
+		state = "removed in " + f.Change.RemovedIn
+	}
+	return fmt.Sprintf("Kubernetes %s: %s %q in %s uses %s, %s, use %s instead",
+		f.KubeVersion, f.Change.Kind, f.Name, f.Template, f.Change.APIVersion, state, f.Change.Replacement)
+}
+
+// KubeCapabilities returns the capabilities of a cluster running kubeVersion, e.g. v1.33.4: the
+// API versions known to helm, without those APIIntroductions adds later and those APIChanges
+// removed by that version. Templates checking .Capabilities.APIVersions then take the branch
+// they would take on the cluster.
+func KubeCapabilities(kubeVersion string) (*common.Capabilities, error) {
+	kv, err := common.ParseKubeVersion(kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+	caps := common.DefaultCapabilities.Copy()
+	caps.KubeVersion = *kv
+	var served common.VersionSet
+	for _, apiVersion := range caps.APIVersions {
+		introduced, ok := APIIntroductions[apiVersion]
+		if ok && !atLeastMinor(kv.Version, introduced) || apiVersionRemoved(apiVersion, kv.Version) {
+			continue
+		}
+		served = append(served, apiVersion)
+	}
+	caps.APIVersions = served
+	return caps, nil
+}
+
+// apiVersionRemoved reports whether every kind APIChanges lists for apiVersion is removed at
+// kubeVersion. API versions APIChanges does not list are served.
+func apiVersionRemoved(apiVersion, kubeVersion string) bool {
+	listed := false
+	for _, change := range APIChanges {
+		if change.APIVersion != apiVersion {
+			continue
+		}
+		listed = true
+		if !change.removedAt(kubeVersion) {
+			return false
+		}
+	}
+	return listed
+}
+
+func (c APIChange) removedAt(kubeVersion string) bool {
+	return atLeastMinor(kubeVersion, c.RemovedIn)
+}
+
+func (c APIChange) deprecatedAt(kubeVersion string) bool {
+	return atLeastMinor(kubeVersion, c.DeprecatedIn)
+}
+
+// atLeastMinor reports whether kubeVersion, e.g. v1.33.4-eks-1234, is at minor or newer.
+func atLeastMinor(kubeVersion, minor string) bool {
+	if minor == "" {
+		return false
+	}
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return false
+	}
+	m, err := semver.NewVersion(minor)
+	if err != nil {
+		return false
+	}
+	return v.Major() > m.Major() || v.Major() == m.Major() && v.Minor() >= m.Minor()
+}
+
+// RenderChartFor renders the chart at chartPath client-side for a cluster running kubeVersion,
+// and returns the rendered templates and CRDs by file name. Unlike RenderChart, which uses the
+// default capabilities of helm, templates see the Kubernetes and API versions of that cluster.
+func RenderChartFor(chartPath string, values map[string]any, kubeVersion string) (map[string]string, error) {
+	loaded, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	chrt, ok := loaded.(*chartv2.Chart)
+	if !ok {
+		return nil, fmt.Errorf("unexpected chart type %T", loaded)
+	}
+	accessor, err := chart.NewAccessor(chrt)
+	if err != nil {
+		return nil, err
+	}
+	if err = action.CheckDependencies(chrt, accessor.MetaDependencies()); err != nil {
+		return nil, fmt.Errorf("%w (run make dep-update)", err)
+	}
+	caps, err := KubeCapabilities(kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+	if chrt.Metadata.KubeVersion != "" && !chartutil.IsCompatibleRange(chrt.Metadata.KubeVersion, caps.KubeVersion.Version) {
+		return nil, fmt.Errorf("chart requires kubeVersion %s which is incompatible with Kubernetes %s", chrt.Metadata.KubeVersion, caps.KubeVersion.Version)
+	}
+	if err = chartutil.ProcessDependencies(chrt, values); err != nil {
+		return nil, err
+	}
+	options := common.ReleaseOptions{Name: DefaultChartReleaseName, Namespace: DefaultNamespace, Revision: 1, IsInstall: true}
+	renderValues, err := util.ToRenderValues(chrt, values, options, caps)
+	if err != nil {
+		return nil, err
+	}
+	files, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %s for Kubernetes %s: %w", chartPath, caps.KubeVersion.Version, err)
+	}
+	for _, crd := range chrt.CRDObjects() {
+		files[crd.Filename] = string(crd.File.Data)
+	}
+	return files, nil
+}
+
+// ScanRemovedAPIs returns the objects of the rendered files using an API deprecated or removed
+// at kubeVersion.
+func ScanRemovedAPIs(files map[string]string, kubeVersion string) ([]APIFinding, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var findings []APIFinding
+	for _, name := range names {
+		dec := yaml.NewDecoder(strings.NewReader(files[name]))
+		for {
+			var object struct {
+				APIVersion string `yaml:"apiVersion"`
+				Kind       string `yaml:"kind"`
+				Metadata   struct {
+					Name string `yaml:"name"`
+				} `yaml:"metadata"`
+			}
+			err := dec.Decode(&object)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			for _, change := range APIChanges {
+				if change.APIVersion != object.APIVersion || change.Kind != object.Kind || !change.deprecatedAt(kubeVersion) && !change.removedAt(kubeVersion) {
+					continue
+				}
+				findings = append(findings, APIFinding{
+					KubeVersion: kubeVersion,
+					Template:    name,
+					Name:        object.Metadata.Name,
+					Change:      change,
+					Removed:     change.removedAt(kubeVersion),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// ExampleValuesFiles returns the values file of every example directory under examplesDir, the
+// files render-examples.sh renders and the -values.norender.yaml ones.
+func ExampleValuesFiles(examplesDir string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"*values.yaml", "*values.norender.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(examplesDir, "*", pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	slices.Sort(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no values files in %s", examplesDir)
+	}
+	return files, nil
+}
+
+// ReadValuesFile reads a chart values file.
+func ReadValuesFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	if err = yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return values, nil
+}
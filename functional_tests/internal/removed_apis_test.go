@@ -0,0 +1,167 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeScanChart writes a chart whose PodDisruptionBudget and CronJob templates pick their API
+// version from the capabilities, the CronJob preferring the beta one, and whose
+// HorizontalPodAutoscaler hardcodes a removed one.
+func writeScanChart(t *testing.T, kubeVersion string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: scan\nversion: 0.1.0\n" + kubeVersion,
+		"values.yaml": "autoscaling: false\n",
+		"templates/pdb.yaml": `apiVersion: {{ if .Capabilities.APIVersions.Has "policy/v1" }}policy/v1{{ else }}policy/v1beta1{{ end }}
+kind: PodDisruptionBudget
+metadata:
+  name: collector
+`,
+		"templates/cronjob.yaml": `apiVersion: {{ if .Capabilities.APIVersions.Has "batch/v1beta1" }}batch/v1beta1{{ else }}batch/v1{{ end }}
+kind: CronJob
+metadata:
+  name: cleanup
+`,
+		"templates/hpa.yaml": `{{- if .Values.autoscaling }}
+apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: gateway
+{{- end }}
+`,
+		"templates/endpoints.yaml": `apiVersion: v1
+kind: Endpoints
+metadata:
+  name: {{ .Release.Name }}
+`,
+		"templates/NOTES.txt": "Installed.\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestKubeCapabilities(t *testing.T) {
+	t.Parallel()
+
+	old, err := KubeCapabilities("v1.20.15")
+	require.NoError(t, err)
+	require.Equal(t, "v1.20.15", old.KubeVersion.Version)
+	require.True(t, old.APIVersions.Has("policy/v1beta1"))
+	require.False(t, old.APIVersions.Has("policy/v1"), "policy/v1 is served from 1.21")
+
+	current, err := KubeCapabilities("v1.33.4-eks-1234")
+	require.NoError(t, err)
+	require.Equal(t, "33", current.KubeVersion.Minor)
+	require.True(t, current.APIVersions.Has("policy/v1"))
+	require.True(t, current.APIVersions.Has("v1"), "APIs with deprecated kinds are still served")
+	for _, apiVersion := range []string{"policy/v1beta1", "batch/v1beta1", "autoscaling/v2beta2", "flowcontrol.apiserver.k8s.io/v1beta3"} {
+		require.False(t, current.APIVersions.Has(apiVersion), apiVersion)
+	}
+
+	_, err = KubeCapabilities("latest")
+	require.Error(t, err)
+}
+
+func TestScanRemovedAPIs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		kubeVersion string
+		values      map[string]any
+		want        []string
+	}{
+		{
+			name:        "gated templates",
+			kubeVersion: "v1.24.17",
+			want: []string{
+				`Kubernetes v1.24.17: CronJob "cleanup" in scan/templates/cronjob.yaml uses batch/v1beta1, deprecated since 1.21, use batch/v1 instead`,
+			},
+		},
+		{
+			name:        "gated templates after the removal",
+			kubeVersion: "v1.25.0",
+		},
+		{
+			name:        "deprecated API",
+			kubeVersion: "v1.33.0",
+			want: []string{
+				`Kubernetes v1.33.0: Endpoints "sock" in scan/templates/endpoints.yaml uses v1, deprecated since 1.33, use discovery.k8s.io/v1 EndpointSlice instead`,
+			},
+		},
+		{
+			name:        "removed API",
+			kubeVersion: "v1.26.0",
+			values:      map[string]any{"autoscaling": true},
+			want: []string{
+				`Kubernetes v1.26.0: HorizontalPodAutoscaler "gateway" in scan/templates/hpa.yaml uses autoscaling/v2beta2, removed in 1.26, use autoscaling/v2 instead`,
+			},
+		},
+	}
+	chartPath := writeScanChart(t, "")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			files, err := RenderChartFor(chartPath, tc.values, tc.kubeVersion)
+			require.NoError(t, err)
+			findings, err := ScanRemovedAPIs(files, tc.kubeVersion)
+			require.NoError(t, err)
+			var got []string
+			for _, finding := range findings {
+				got = append(got, finding.String())
+				require.Equal(t, strings.Contains(finding.String(), "removed in"), finding.Removed)
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRenderChartForCapabilities(t *testing.T) {
+	t.Parallel()
+
+	chartPath := writeScanChart(t, "")
+	files, err := RenderChartFor(chartPath, nil, "v1.20.15")
+	require.NoError(t, err)
+	require.Contains(t, files["scan/templates/pdb.yaml"], "apiVersion: policy/v1beta1")
+	files, err = RenderChartFor(chartPath, nil, "v1.33.4-eks-1234")
+	require.NoError(t, err)
+	require.Contains(t, files["scan/templates/pdb.yaml"], "apiVersion: policy/v1\n")
+	require.Contains(t, files["scan/templates/cronjob.yaml"], "apiVersion: batch/v1\n")
+}
+
+func TestRenderChartForKubeVersionConstraint(t *testing.T) {
+	t.Parallel()
+
+	chartPath := writeScanChart(t, "kubeVersion: \">=1.33.0-0\"\n")
+	_, err := RenderChartFor(chartPath, nil, "v1.33.4-eks-1234")
+	require.NoError(t, err)
+	_, err = RenderChartFor(chartPath, nil, "v1.32.9")
+	require.ErrorContains(t, err, "chart requires kubeVersion >=1.33.0-0 which is incompatible with Kubernetes v1.32.9")
+}
+
+func TestExampleValuesFiles(t *testing.T) {
+	t.Parallel()
+
+	files, err := ExampleValuesFiles(filepath.Join("..", "..", "examples"))
+	require.NoError(t, err)
+	require.Contains(t, files, filepath.Join("..", "..", "examples", "default", "default-values.yaml"))
+	for _, file := range files {
+		require.True(t, strings.HasSuffix(file, "values.yaml") || strings.HasSuffix(file, "values.norender.yaml"), file)
+	}
+
+	_, err = ExampleValuesFiles(t.TempDir())
+	require.ErrorContains(t, err, "no values files")
+}
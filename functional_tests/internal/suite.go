@@ -0,0 +1,351 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	k8stest "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/xk8stest"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Suite describes a functional test suite declaratively: the sinks it listens on, the values
+// template the chart is installed with and the hooks that deploy and remove its workloads.
+// Run applies the lifecycle environment variables the same way for every suite:
+//
+//	TEARDOWN_BEFORE_SETUP – run the teardown before the setup when set to "true"
+//	SKIP_SETUP            – skip the chart installation and the Setup and Deploy hooks
+//	SKIP_TEARDOWN         – leave the chart and the workloads installed after the run
+//	SKIP_TESTS            – stop after the setup, without running the tests
+//	DIAGNOSTICS_DIR       – write the diagnostics of failed suites to this directory
+//	                        instead of the test log
+type Suite struct {
+	SuiteRequirements
+	// SignalFxAPI starts the stub SignalFx API server most values templates point apiUrl at.
+	SignalFxAPI bool
+	// Sinks are started before the chart is installed, in order.
+	Sinks []SuiteSink
+	// ValuesTemplate is the chart values template, relative to the suite testdata directory.
+	// The chart is not installed when it is empty.
+	ValuesTemplate string
+	// Replacements are passed to the values template. SinkURL and SinkAddress values are
+	// resolved against the host endpoint.
+	Replacements map[string]any
+	// ChartOptions defaults to GetDefaultChartOptions.
+	ChartOptions *ChartOptions
+	// MinReadyTime is how long the chart pods must stay ready after the installation.
+	MinReadyTime time.Duration
+	// Setup runs before the chart is installed, e.g. to create namespaces the chart watches.
+	Setup func(t *testing.T, env *SuiteEnv)
+	// Deploy runs after the chart is installed, typically to deploy the test workloads.
+	Deploy func(t *testing.T, env *SuiteEnv)
+	// Teardown removes what Setup and Deploy created. The chart is uninstalled after it.
+	Teardown func(t *testing.T, env *SuiteEnv)
+	// DiagnosticsNamespaces are described when the suite fails, in addition to the chart namespace.
+	DiagnosticsNamespaces []string
+}
+
+// SinkURL is a Replacements value resolved to the http URL of a sink port on the host endpoint.
+type SinkURL int
+
+// SinkAddress is a Replacements value resolved to the host:port of a sink on the host endpoint.
+type SinkAddress int
+
+// SuiteSink is a sink a suite listens on, looked up by name from the SuiteEnv.
+type SuiteSink struct {
+	Name  string
+	setup func(t *testing.T) any
+}
+
+// HECLogsSuiteSink listens for HEC logs on HECLogsReceiverPort.
+func HECLogsSuiteSink(name string) SuiteSink {
+	return SuiteSink{Name: name, setup: func(t *testing.T) any { return SetupHECLogsSink(t) }}
+}
+
+// HECMetricsSuiteSink listens for HEC metrics on HECMetricsReceiverPort.
+func HECMetricsSuiteSink(name string) SuiteSink {
+	return SuiteSink{Name: name, setup: func(t *testing.T) any { return SetupHECMetricsSink(t) }}
+}
+
+// OTLPLogsSuiteSink listens for OTLP HTTP logs on the port and path.
+func OTLPLogsSuiteSink(name string, port int, logsPath string) SuiteSink {
+	return SuiteSink{Name: name, setup: func(t *testing.T) any { return SetupOTLPLogsSinkOnPort(t, port, logsPath) }}
+}
+
+// OTLPTracesSuiteSink listens for OTLP traces on the default gRPC and HTTP ports.
+func OTLPTracesSuiteSink(name string) SuiteSink {
+	return SuiteSink{Name: name, setup: func(t *testing.T) any { return SetupOTLPTracesSink(t) }}
+}
+
+// SignalFxSuiteSink listens for SignalFx metrics on the port.
+func SignalFxSuiteSink(name string, port int) SuiteSink {
+	return SuiteSink{Name: name, setup: func(t *testing.T) any { return SetupSignalfxReceiver(t, port) }}
+}
+
+// SuiteEnv is what the hooks and the tests of a suite share.
+type SuiteEnv struct {
+	KubeConfig string
+	Clientset  *kubernetes.Clientset
+	sinks      map[string]any
+	hostEp     string
+	k8sClient  *k8stest.K8sClient
+}
+
+// HostEndpoint returns the address pods use to reach the sinks.
+func (e *SuiteEnv) HostEndpoint(t *testing.T) string {
+	if e.hostEp == "" {
+		e.hostEp = HostEndpoint(t)
+		require.NotEmpty(t, e.hostEp, "host endpoint not found")
+	}
+	return e.hostEp
+}
+
+// K8sClient returns a dynamic client for creating and deleting objects from manifests.
+func (e *SuiteEnv) K8sClient(t *testing.T) *k8stest.K8sClient {
+	if e.k8sClient == nil {
+		client, err := k8stest.NewK8sClient(e.KubeConfig)
+		require.NoError(t, err)
+		e.k8sClient = client
+	}
+	return e.k8sClient
+}
+
+// LogsSink returns the logs sink declared with the name.
+func (e *SuiteEnv) LogsSink(t *testing.T, name string) *consumertest.LogsSink {
+	return suiteSink[*consumertest.LogsSink](t, e, name)
+}
+
+// MetricsSink returns the metrics sink declared with the name.
+func (e *SuiteEnv) MetricsSink(t *testing.T, name string) *consumertest.MetricsSink {
+	return suiteSink[*consumertest.MetricsSink](t, e, name)
+}
+
+// TracesSink returns the traces sink declared with the name.
+func (e *SuiteEnv) TracesSink(t *testing.T, name string) *consumertest.TracesSink {
+	return suiteSink[*consumertest.TracesSink](t, e, name)
+}
+
+func suiteSink[T any](t *testing.T, e *SuiteEnv, name string) T {
+	t.Helper()
+	sink, ok := e.sinks[name]
+	require.Truef(t, ok, "sink %q is not declared by the suite", name)
+	typed, ok := sink.(T)
+	require.Truef(t, ok, "sink %q is a %T", name, sink)
+	return typed
+}
+
+// Run sets the suite up according to the lifecycle environment variables, then calls tests.
+// It skips the test when KUBE_TEST_ENV is not supported, holds the cluster lease for
+// cluster-exclusive suites and collects diagnostics from the cluster when the test fails.
+func (s Suite) Run(t *testing.T, tests func(t *testing.T, env *SuiteEnv)) {
+	kubeConfig, ok := os.LookupEnv("KUBECONFIG")
+	require.True(t, ok, "the environment variable KUBECONFIG must be set")
+	RequireSuite(t, kubeConfig, s.SuiteRequirements)
+
+	clientset, err := GetKubeClient(kubeConfig)
+	require.NoError(t, err)
+	env := &SuiteEnv{KubeConfig: kubeConfig, Clientset: clientset, sinks: map[string]any{}}
+
+	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
+		t.Log("Running teardown before setup as TEARDOWN_BEFORE_SETUP is set to true")
+		s.teardown(t, env)
+	}
+
+	if s.SignalFxAPI {
+		SetupSignalFxAPIServer(t)
+	}
+	for _, sink := range s.Sinks {
+		env.sinks[sink.Name] = sink.setup(t)
+	}
+
+	if os.Getenv("SKIP_SETUP") == "true" {
+		t.Log("Skipping setup as SKIP_SETUP is set to true")
+	} else {
+		t.Cleanup(func() {
+			if os.Getenv("SKIP_TEARDOWN") == "true" {
+				t.Log("Skipping teardown as SKIP_TEARDOWN is set to true")
+				return
+			}
+			s.teardown(t, env)
+		})
+		s.setup(t, env)
+	}
+	// Registered after the teardown so that it runs first, while the pods are still there.
+	t.Cleanup(func() {
+		if t.Failed() {
+			s.collectDiagnostics(t, env)
+		}
+	})
+
+	if os.Getenv("SKIP_TESTS") == "true" {
+		t.Log("Skipping tests as SKIP_TESTS is set to true")
+		return
+	}
+	tests(t, env)
+}
+
+func (s Suite) setup(t *testing.T, env *SuiteEnv) {
+	if s.Setup != nil {
+		s.Setup(t, env)
+	}
+	if s.ValuesTemplate != "" {
+		valuesFile, err := filepath.Abs(filepath.Join("testdata", s.ValuesTemplate))
+		require.NoError(t, err)
+		ChartInstallOrUpgrade(t, env.KubeConfig, valuesFile, s.resolveReplacements(t, env), s.MinReadyTime, s.chartOptions())
+	}
+	if s.Deploy != nil {
+		s.Deploy(t, env)
+	}
+}
+
+func (s Suite) teardown(t *testing.T, env *SuiteEnv) {
+	if s.Teardown != nil {
+		s.Teardown(t, env)
+	}
+	if s.ValuesTemplate != "" {
+		ChartUninstall(t, env.KubeConfig)
+	}
+}
+
+func (s Suite) chartOptions() ChartOptions {
+	if s.ChartOptions != nil {
+		return *s.ChartOptions
+	}
+	return GetDefaultChartOptions()
+}
+
+func (s Suite) resolveReplacements(t *testing.T, env *SuiteEnv) map[string]any {
+	resolved := make(map[string]any, len(s.Replacements))
+	for key, value := range s.Replacements {
+		switch v := value.(type) {
+		case SinkURL:
+			resolved[key] = HostPortHTTP(env.HostEndpoint(t), int(v))
+		case SinkAddress:
+			resolved[key] = HostPort(env.HostEndpoint(t), int(v))
+		default:
+			resolved[key] = value
+		}
+	}
+	return resolved
+}
+
+func (s Suite) collectDiagnostics(t *testing.T, env *SuiteEnv) {
+	namespaces := append([]string{s.chartOptions().ChartNamespace}, s.DiagnosticsNamespaces...)
+	// t.Context() is already cancelled during t.Cleanup.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute) //nolint:usetesting
+	defer cancel()
+
+	var out strings.Builder
+	if err := CollectDiagnostics(ctx, env.Clientset, &out, namespaces...); err != nil {
+		t.Logf("Failed to collect diagnostics: %v", err)
+	}
+	dir := os.Getenv("DIAGNOSTICS_DIR")
+	if dir == "" {
+		t.Logf("Diagnostics for suite %s:\n%s", s.Name, out.String())
+		return
+	}
+	path := filepath.Join(dir, strings.NewReplacer("/", "_").Replace(t.Name())+".txt")
+	if err := os.MkdirAll(dir, 0o755); err == nil {
+		err = os.WriteFile(path, []byte(out.String()), 0o600)
+		if err == nil {
+			t.Logf("Diagnostics for suite %s written to %s", s.Name, path)
+			return
+		}
+	}
+	t.Logf("Failed to write diagnostics to %s; diagnostics for suite %s:\n%s", path, s.Name, out.String())
+}
+
+// diagnosticsLogTail is the number of log lines kept per container.
+const diagnosticsLogTail = 100
+
+// CollectDiagnostics writes the pods, their container states and log tails, and the recent
+// warning events of the namespaces to w. It keeps going past errors and returns the first one.
+func CollectDiagnostics(ctx context.Context, clientset kubernetes.Interface, w io.Writer, namespaces ...string) error {
+	var firstErr error
+	keep := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	seen := map[string]bool{}
+	for _, namespace := range namespaces {
+		if seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+
+		pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			keep(fmt.Errorf("failed to list pods in %s: %w", namespace, err))
+			continue
+		}
+		fmt.Fprintf(w, "=== namespace %s: %d pods\n", namespace, len(pods.Items))
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			fmt.Fprintf(w, "--- pod %s phase=%s node=%s\n", pod.Name, pod.Status.Phase, pod.Spec.NodeName)
+			for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+				fmt.Fprintf(w, "container %s ready=%t restarts=%d state=%s\n",
+					status.Name, status.Ready, status.RestartCount, containerState(status.State))
+			}
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				logs, logErr := podLogTail(ctx, clientset, namespace, pod.Name, container.Name)
+				if logErr != nil {
+					fmt.Fprintf(w, "logs %s/%s: %v\n", pod.Name, container.Name, logErr)
+					continue
+				}
+				fmt.Fprintf(w, "logs %s/%s (last %d lines):\n%s\n", pod.Name, container.Name, diagnosticsLogTail, logs)
+			}
+		}
+
+		events, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=Warning"})
+		if err != nil {
+			keep(fmt.Errorf("failed to list events in %s: %w", namespace, err))
+			continue
+		}
+		sort.Slice(events.Items, func(i, j int) bool {
+			return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+		})
+		fmt.Fprintf(w, "=== namespace %s: %d warning events\n", namespace, len(events.Items))
+		for _, event := range events.Items {
+			fmt.Fprintf(w, "%s %s/%s %s: %s\n", event.LastTimestamp.UTC().Format(time.RFC3339),
+				event.InvolvedObject.Kind, event.InvolvedObject.Name, event.Reason, event.Message)
+		}
+	}
+	return firstErr
+}
+
+func containerState(state corev1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "running"
+	case state.Waiting != nil:
+		return fmt.Sprintf("waiting(%s: %s)", state.Waiting.Reason, state.Waiting.Message)
+	case state.Terminated != nil:
+		return fmt.Sprintf("terminated(%s, exit %d)", state.Terminated.Reason, state.Terminated.ExitCode)
+	}
+	return "unknown"
+}
+
+func podLogTail(ctx context.Context, clientset kubernetes.Interface, namespace, pod, container string) (string, error) {
+	tail := int64(diagnosticsLogTail)
+	stream, err := clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container, TailLines: &tail}).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	logs, err := io.ReadAll(stream)
+	return string(logs), err
+}
@@ -0,0 +1,156 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Capability is something a suite needs from the cluster or the machine running it.
+type Capability string
+
+const (
+	// CapabilityHostSinks: pods can reach the sinks the suite listens on from the test host.
+	CapabilityHostSinks Capability = "host-sinks"
+	// CapabilityNodePorts: the test host can reach hostPorts on the nodes (kind extraPortMappings).
+	CapabilityNodePorts Capability = "node-ports"
+	// CapabilityControlPlaneMetrics: control plane components expose metrics on the node network,
+	// as configured in .github/workflows/configs/kind-config.yaml.
+	CapabilityControlPlaneMetrics Capability = "control-plane-metrics"
+	// CapabilityClusterAdmin: the suite may create cluster-scoped objects such as operator CRDs.
+	CapabilityClusterAdmin Capability = "cluster-admin"
+	// CapabilityPrivilegedPods: privileged and hostPath pods are admitted (not GKE Autopilot or EKS Fargate).
+	CapabilityPrivilegedPods Capability = "privileged-pods"
+	// CapabilityInternet: the suite downloads tooling or manifests, e.g. istioctl.
+	CapabilityInternet Capability = "internet"
+)
+
+// SuiteRequirements is what a functional test suite declares about the environment it needs.
+type SuiteRequirements struct {
+	// Name is the suite directory under functional_tests.
+	Name string
+	// Environments lists the KUBE_TEST_ENV values the suite supports. Empty means any.
+	Environments []string
+	Capabilities []Capability
+	// ClusterExclusive suites install the chart cluster-wide and must hold the cluster lease.
+	ClusterExclusive bool
+	// LeaseScope names the lease a cluster-exclusive suite holds, for suites that only need a
+	// feature of the cluster to themselves. Empty means DefaultLeaseScope.
+	LeaseScope string
+}
+
+// ClusterCapabilities describes the cluster a run targets.
+type ClusterCapabilities struct {
+	Env          string
+	Capabilities map[Capability]bool
+}
+
+// Has reports whether the capability is available.
+func (c ClusterCapabilities) Has(capability Capability) bool {
+	return c.Capabilities[capability]
+}
+
+// List returns the available capabilities, sorted.
+func (c ClusterCapabilities) List() []string {
+	var out []string
+	for capability, ok := range c.Capabilities {
+		if ok {
+			out = append(out, string(capability))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Unmet returns why the suite cannot run on the cluster, or nil when it can.
+func (r SuiteRequirements) Unmet(caps ClusterCapabilities) []string {
+	var reasons []string
+	if len(r.Environments) > 0 && !slices.Contains(r.Environments, caps.Env) {
+		reasons = append(reasons, fmt.Sprintf("KUBE_TEST_ENV %q not in %v", caps.Env, r.Environments))
+	}
+	for _, capability := range r.Capabilities {
+		if !caps.Has(capability) {
+			reasons = append(reasons, "missing capability "+string(capability))
+		}
+	}
+	return reasons
+}
+
+// DetectClusterCapabilities derives the capabilities of the cluster from KUBE_TEST_ENV, the
+// cluster provider and an access review. The result can be adjusted with ApplyCapabilityOverrides.
+func DetectClusterCapabilities(ctx context.Context, clientset kubernetes.Interface, env string, provider ClusterProvider) (ClusterCapabilities, error) {
+	caps := ClusterCapabilities{Env: env, Capabilities: map[Capability]bool{
+		CapabilityInternet: os.Getenv("FUNCTIONAL_TESTS_OFFLINE") != "true",
+	}}
+	switch env {
+	case "gke/autopilot", "eks/fargate":
+	default:
+		caps.Capabilities[CapabilityPrivilegedPods] = true
+	}
+	if provider != nil {
+		if _, err := provider.HostEndpoint(ctx); err == nil {
+			caps.Capabilities[CapabilityHostSinks] = true
+		}
+		if provider.Name() == ClusterProviderKind {
+			caps.Capabilities[CapabilityNodePorts] = true
+			caps.Capabilities[CapabilityControlPlaneMetrics] = true
+		}
+	}
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     "create",
+				Group:    "apiextensions.k8s.io",
+				Resource: "customresourcedefinitions",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return caps, fmt.Errorf("failed to review cluster access: %w", err)
+	}
+	caps.Capabilities[CapabilityClusterAdmin] = review.Status.Allowed
+	return caps, nil
+}
+
+// ApplyCapabilityOverrides adds or, with a leading "-", removes capabilities from a
+// comma-separated list such as "node-ports,-internet".
+func (c ClusterCapabilities) ApplyCapabilityOverrides(overrides string) {
+	for _, item := range strings.Split(overrides, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if name, found := strings.CutPrefix(item, "-"); found {
+			c.Capabilities[Capability(name)] = false
+		} else {
+			c.Capabilities[Capability(item)] = true
+		}
+	}
+}
+
+// RequireSuite applies the suite requirements that do not depend on capability detection:
+// it skips the test when KUBE_TEST_ENV is not supported and takes the cluster lease for
+// cluster-exclusive suites. Capability filtering is left to the suite runner, which knows
+// the overrides the caller asked for.
+func RequireSuite(t *testing.T, testKubeConfig string, requirements SuiteRequirements) {
+	t.Helper()
+	if env := os.Getenv("KUBE_TEST_ENV"); len(requirements.Environments) > 0 && !slices.Contains(requirements.Environments, env) {
+		t.Skipf("suite %s does not support KUBE_TEST_ENV %q (supported: %v)", requirements.Name, env, requirements.Environments)
+	}
+	if requirements.ClusterExclusive {
+		require.NotEmpty(t, testKubeConfig, "KUBECONFIG is required to take the cluster lease")
+		AcquireLeaseForTest(t, testKubeConfig, WithLeaseScope(requirements.LeaseScope))
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuiteRequirementsUnmet(t *testing.T) {
+	t.Parallel()
+
+	caps := ClusterCapabilities{Env: "eks", Capabilities: map[Capability]bool{
+		CapabilityHostSinks: true,
+		CapabilityInternet:  true,
+	}}
+	caps.ApplyCapabilityOverrides("node-ports, -internet,")
+	require.Equal(t, []string{"host-sinks", "node-ports"}, caps.List())
+
+	require.Empty(t, SuiteRequirements{Capabilities: []Capability{CapabilityHostSinks, CapabilityNodePorts}}.Unmet(caps))
+	require.Equal(t, []string{
+		`KUBE_TEST_ENV "eks" not in [kind]`,
+		"missing capability internet",
+	}, SuiteRequirements{Environments: []string{"kind"}, Capabilities: []Capability{CapabilityInternet}}.Unmet(caps))
+}
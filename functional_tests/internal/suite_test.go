@@ -0,0 +1,65 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSuiteResolveReplacements(t *testing.T) {
+	t.Parallel()
+
+	suite := Suite{Replacements: map[string]any{
+		"ApiURL":       SinkURL(SignalFxAPIPort),
+		"OTLPEndpoint": SinkAddress(OTLPGRPCReceiverPort),
+		"ClusterName":  "dev",
+	}}
+	env := &SuiteEnv{hostEp: "172.18.0.1"}
+	require.Equal(t, map[string]any{
+		"ApiURL":       "http://172.18.0.1:8881",
+		"OTLPEndpoint": "172.18.0.1:4317",
+		"ClusterName":  "dev",
+	}, suite.resolveReplacements(t, env))
+}
+
+func TestCollectDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent-abc", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "kind-control-plane", Containers: []corev1.Container{{Name: "otel-collector"}}},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:         "otel-collector",
+					RestartCount: 3,
+					State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off"}},
+				}},
+			},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "agent-abc.1", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "agent-abc"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+		},
+	)
+
+	var out strings.Builder
+	require.NoError(t, CollectDiagnostics(t.Context(), clientset, &out, "default", "default"))
+	diagnostics := out.String()
+	require.Equal(t, 1, strings.Count(diagnostics, "=== namespace default: 1 pods"))
+	require.Contains(t, diagnostics, "--- pod agent-abc phase=Pending node=kind-control-plane")
+	require.Contains(t, diagnostics, "container otel-collector ready=false restarts=3 state=waiting(CrashLoopBackOff: back-off)")
+	require.Contains(t, diagnostics, "logs agent-abc/otel-collector (last 100 lines):\nfake logs")
+	require.Contains(t, diagnostics, "Pod/agent-abc BackOff: Back-off restarting failed container")
+}
@@ -0,0 +1,289 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// TraceSpan is a received span together with the resource and scope it was emitted under.
+type TraceSpan struct {
+	Resource pcommon.Resource
+	Scope    pcommon.InstrumentationScope
+	Span     ptrace.Span
+}
+
+// ServiceName returns the service.name resource attribute of the span, or "" when unset.
+func (s TraceSpan) ServiceName() string {
+	if v, ok := s.Resource.Attributes().Get("service.name"); ok {
+		return v.AsString()
+	}
+	return ""
+}
+
+// TraceTree holds every span received for a single trace ID, possibly across several batches.
+type TraceTree struct {
+	TraceID pcommon.TraceID
+	Spans   []TraceSpan
+	byID    map[pcommon.SpanID]int
+}
+
+// ServiceEdge is a call from one service to another, derived from a CLIENT→SERVER
+// or PRODUCER→CONSUMER parent/child span pair.
+type ServiceEdge struct {
+	Client string
+	Server string
+}
+
+func (e ServiceEdge) String() string {
+	return e.Client + " -> " + e.Server
+}
+
+type traceAssertionConfig struct {
+	requiredAttrs map[ptrace.SpanKind][]string
+	partialTraces bool
+}
+
+// TraceAssertionOption configures trace structure validation.
+type TraceAssertionOption func(*traceAssertionConfig)
+
+// WithRequiredSpanAttributes requires every span of the given kind to carry attrs.
+func WithRequiredSpanAttributes(kind ptrace.SpanKind, attrs ...string) TraceAssertionOption {
+	return func(cfg *traceAssertionConfig) {
+		if cfg.requiredAttrs == nil {
+			cfg.requiredAttrs = map[ptrace.SpanKind][]string{}
+		}
+		cfg.requiredAttrs[kind] = append(cfg.requiredAttrs[kind], attrs...)
+	}
+}
+
+// WithPartialTraces tolerates spans whose parent was not received, e.g. when the
+// caller is not instrumented or exports to a different sink.
+func WithPartialTraces() TraceAssertionOption {
+	return func(cfg *traceAssertionConfig) {
+		cfg.partialTraces = true
+	}
+}
+
+// GroupSpansByTrace flattens the given batches and groups their spans by trace ID.
+// A span received more than once is kept once.
+func GroupSpansByTrace(batches []ptrace.Traces) map[pcommon.TraceID]*TraceTree {
+	trees := map[pcommon.TraceID]*TraceTree{}
+	for _, td := range batches {
+		for i := 0; i < td.ResourceSpans().Len(); i++ {
+			rs := td.ResourceSpans().At(i)
+			for j := 0; j < rs.ScopeSpans().Len(); j++ {
+				ss := rs.ScopeSpans().At(j)
+				for k := 0; k < ss.Spans().Len(); k++ {
+					span := ss.Spans().At(k)
+					tree, ok := trees[span.TraceID()]
+					if !ok {
+						tree = &TraceTree{TraceID: span.TraceID(), byID: map[pcommon.SpanID]int{}}
+						trees[span.TraceID()] = tree
+					}
+					if _, dup := tree.byID[span.SpanID()]; dup {
+						continue
+					}
+					tree.byID[span.SpanID()] = len(tree.Spans)
+					tree.Spans = append(tree.Spans, TraceSpan{Resource: rs.Resource(), Scope: ss.Scope(), Span: span})
+				}
+			}
+		}
+	}
+	return trees
+}
+
+// Parent returns the parent of s within the tree. ok is false for roots and for
+// spans whose parent was not received.
+func (tr *TraceTree) Parent(s TraceSpan) (TraceSpan, bool) {
+	if s.Span.ParentSpanID().IsEmpty() {
+		return TraceSpan{}, false
+	}
+	idx, ok := tr.byID[s.Span.ParentSpanID()]
+	if !ok {
+		return TraceSpan{}, false
+	}
+	return tr.Spans[idx], true
+}
+
+// Roots returns the spans without a parent span ID.
+func (tr *TraceTree) Roots() []TraceSpan {
+	var roots []TraceSpan
+	for _, s := range tr.Spans {
+		if s.Span.ParentSpanID().IsEmpty() {
+			roots = append(roots, s)
+		}
+	}
+	return roots
+}
+
+// Services returns the sorted set of service names seen in the trace.
+func (tr *TraceTree) Services() []string {
+	seen := map[string]struct{}{}
+	for _, s := range tr.Spans {
+		seen[s.ServiceName()] = struct{}{}
+	}
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ServiceEdges returns the sorted, de-duplicated cross-service calls in the trace.
+func (tr *TraceTree) ServiceEdges() []ServiceEdge {
+	seen := map[ServiceEdge]struct{}{}
+	for _, s := range tr.Spans {
+		parent, ok := tr.Parent(s)
+		if !ok || parent.ServiceName() == s.ServiceName() || !isHopPair(parent.Span.Kind(), s.Span.Kind()) {
+			continue
+		}
+		seen[ServiceEdge{Client: parent.ServiceName(), Server: s.ServiceName()}] = struct{}{}
+	}
+	return sortedEdges(seen)
+}
+
+// Validate checks that the spans form a single tree: exactly one root, every parent
+// received, no cycles, remote hops typed CLIENT→SERVER or PRODUCER→CONSUMER, and
+// the per-kind required attributes present. All problems are joined into the error.
+func (tr *TraceTree) Validate(opts ...TraceAssertionOption) error {
+	var cfg traceAssertionConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var errs []error
+	roots := tr.Roots()
+	if len(roots) > 1 {
+		errs = append(errs, fmt.Errorf("trace %s has %d root spans, want 1", tr.TraceID, len(roots)))
+	}
+	if len(roots) == 0 && !cfg.partialTraces {
+		errs = append(errs, fmt.Errorf("trace %s has no root span", tr.TraceID))
+	}
+
+	for _, s := range tr.Spans {
+		span := s.Span
+		if !span.ParentSpanID().IsEmpty() {
+			parent, ok := tr.Parent(s)
+			switch {
+			case !ok && !cfg.partialTraces:
+				errs = append(errs, fmt.Errorf("span %q (%s) in %s references missing parent %s",
+					span.Name(), span.SpanID(), s.ServiceName(), span.ParentSpanID()))
+			case ok && parent.ServiceName() != s.ServiceName() && !isHopPair(parent.Span.Kind(), span.Kind()):
+				errs = append(errs, fmt.Errorf("hop %s -> %s has span kinds %s -> %s, want Client -> Server or Producer -> Consumer",
+					parent.ServiceName(), s.ServiceName(), parent.Span.Kind(), span.Kind()))
+			}
+		}
+		for _, attr := range cfg.requiredAttrs[span.Kind()] {
+			if _, ok := span.Attributes().Get(attr); !ok {
+				errs = append(errs, fmt.Errorf("%s span %q in %s is missing attribute %q",
+					span.Kind(), span.Name(), s.ServiceName(), attr))
+			}
+		}
+	}
+
+	if cycle := tr.findCycle(); cycle != "" {
+		errs = append(errs, fmt.Errorf("trace %s has a parent cycle through span %s", tr.TraceID, cycle))
+	}
+	return errors.Join(errs...)
+}
+
+func (tr *TraceTree) findCycle() string {
+	for _, s := range tr.Spans {
+		visited := map[pcommon.SpanID]struct{}{}
+		cur := s
+		for {
+			if _, seen := visited[cur.Span.SpanID()]; seen {
+				return cur.Span.SpanID().String()
+			}
+			visited[cur.Span.SpanID()] = struct{}{}
+			parent, ok := tr.Parent(cur)
+			if !ok {
+				break
+			}
+			cur = parent
+		}
+	}
+	return ""
+}
+
+func isHopPair(parent, child ptrace.SpanKind) bool {
+	return (parent == ptrace.SpanKindClient && child == ptrace.SpanKindServer) ||
+		(parent == ptrace.SpanKindProducer && child == ptrace.SpanKindConsumer)
+}
+
+func sortedEdges(set map[ServiceEdge]struct{}) []ServiceEdge {
+	out := make([]ServiceEdge, 0, len(set))
+	for e := range set {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+// AssertTraceStructure validates every trace found in batches.
+func AssertTraceStructure(t *testing.T, batches []ptrace.Traces, opts ...TraceAssertionOption) {
+	t.Helper()
+	trees := GroupSpansByTrace(batches)
+	require.NotEmpty(t, trees, "no spans to validate")
+	var errs []error
+	for _, tree := range trees {
+		errs = append(errs, tree.Validate(opts...))
+	}
+	require.NoError(t, errors.Join(errs...), "invalid trace structure in %d trace(s)", len(trees))
+}
+
+// WaitForServiceEdges waits until every edge is observed in a single trace from the sink
+// and returns the traces that contain all of them.
+func WaitForServiceEdges(t *testing.T, sink *consumertest.TracesSink, timeout time.Duration, edges ...ServiceEdge) []*TraceTree {
+	t.Helper()
+	var matched []*TraceTree
+	require.Eventuallyf(t, func() bool {
+		matched = nil
+		observed := map[ServiceEdge]struct{}{}
+		for _, tree := range GroupSpansByTrace(sink.AllTraces()) {
+			treeEdges := map[ServiceEdge]struct{}{}
+			for _, e := range tree.ServiceEdges() {
+				treeEdges[e] = struct{}{}
+				observed[e] = struct{}{}
+			}
+			if containsAllEdges(treeEdges, edges) {
+				matched = append(matched, tree)
+			}
+		}
+		if len(matched) == 0 {
+			t.Logf("waiting for service edges [%s], observed [%s]", formatEdges(edges), formatEdges(sortedEdges(observed)))
+		}
+		return len(matched) > 0
+	}, timeout, 2*time.Second, "no trace contained service edges [%s]", formatEdges(edges))
+	return matched
+}
+
+func containsAllEdges(set map[ServiceEdge]struct{}, edges []ServiceEdge) bool {
+	for _, e := range edges {
+		if _, ok := set[e]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func formatEdges(edges []ServiceEdge) string {
+	parts := make([]string, len(edges))
+	for i, e := range edges {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, ", ")
+}
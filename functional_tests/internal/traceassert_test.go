@@ -0,0 +1,135 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type testSpan struct {
+	service string
+	id      byte
+	parent  byte
+	kind    ptrace.SpanKind
+	attrs   map[string]string
+}
+
+func buildTestTraces(spans ...testSpan) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, s := range spans {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", s.service)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{1})
+		span.SetSpanID(pcommon.SpanID{s.id})
+		if s.parent != 0 {
+			span.SetParentSpanID(pcommon.SpanID{s.parent})
+		}
+		span.SetKind(s.kind)
+		span.SetName(s.service)
+		for k, v := range s.attrs {
+			span.Attributes().PutStr(k, v)
+		}
+	}
+	return td
+}
+
+func TestTraceTreeValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		spans   []testSpan
+		opts    []TraceAssertionOption
+		wantErr string
+	}{
+		{
+			name: "client to server hop",
+			spans: []testSpan{
+				{service: "gateway", id: 1, kind: ptrace.SpanKindClient},
+				{service: "httpbin", id: 2, parent: 1, kind: ptrace.SpanKindServer},
+			},
+		},
+		{
+			name: "missing parent",
+			spans: []testSpan{
+				{service: "gateway", id: 1, kind: ptrace.SpanKindClient},
+				{service: "httpbin", id: 2, parent: 9, kind: ptrace.SpanKindServer},
+			},
+			wantErr: "references missing parent",
+		},
+		{
+			name: "missing parent tolerated for partial traces",
+			spans: []testSpan{
+				{service: "httpbin", id: 2, parent: 9, kind: ptrace.SpanKindServer},
+			},
+			opts: []TraceAssertionOption{WithPartialTraces()},
+		},
+		{
+			name: "server to server hop",
+			spans: []testSpan{
+				{service: "gateway", id: 1, kind: ptrace.SpanKindServer},
+				{service: "httpbin", id: 2, parent: 1, kind: ptrace.SpanKindServer},
+			},
+			wantErr: "want Client -> Server",
+		},
+		{
+			name: "required attribute missing",
+			spans: []testSpan{
+				{service: "httpbin", id: 1, kind: ptrace.SpanKindServer, attrs: map[string]string{"http.method": "GET"}},
+			},
+			opts:    []TraceAssertionOption{WithRequiredSpanAttributes(ptrace.SpanKindServer, "http.method", "http.status_code")},
+			wantErr: `missing attribute "http.status_code"`,
+		},
+		{
+			name: "two roots",
+			spans: []testSpan{
+				{service: "a", id: 1, kind: ptrace.SpanKindServer},
+				{service: "b", id: 2, kind: ptrace.SpanKindServer},
+			},
+			wantErr: "2 root spans",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			trees := GroupSpansByTrace([]ptrace.Traces{buildTestTraces(testCase.spans...)})
+			require.Len(t, trees, 1)
+			err := trees[pcommon.TraceID{1}].Validate(testCase.opts...)
+			if testCase.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, testCase.wantErr)
+		})
+	}
+}
+
+func TestTraceTreeServiceEdges(t *testing.T) {
+	t.Parallel()
+
+	td := buildTestTraces(
+		testSpan{service: "gateway", id: 1, kind: ptrace.SpanKindServer},
+		testSpan{service: "gateway", id: 2, parent: 1, kind: ptrace.SpanKindClient},
+		testSpan{service: "httpbin", id: 3, parent: 2, kind: ptrace.SpanKindServer},
+		testSpan{service: "httpbin", id: 4, parent: 3, kind: ptrace.SpanKindProducer},
+		testSpan{service: "worker", id: 5, parent: 4, kind: ptrace.SpanKindConsumer},
+	)
+	// Duplicate deliveries must not produce duplicate spans or edges.
+	trees := GroupSpansByTrace([]ptrace.Traces{td, td})
+	tree := trees[pcommon.TraceID{1}]
+	require.Len(t, tree.Spans, 5)
+	require.NoError(t, tree.Validate())
+	require.Equal(t, []ServiceEdge{
+		{Client: "gateway", Server: "httpbin"},
+		{Client: "httpbin", Server: "worker"},
+	}, tree.ServiceEdges())
+	require.Equal(t, []string{"gateway", "httpbin", "worker"}, tree.Services())
+}
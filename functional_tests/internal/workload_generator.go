@@ -0,0 +1,621 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	generatorContainerName = "generator"
+	// generatedContainerName is the container the RuntimeFormat log files are attributed to.
+	generatedContainerName = "generated"
+	// maxGeneratorConfigMapSize leaves headroom under the 1MiB ConfigMap limit.
+	maxGeneratorConfigMapSize = 900 * 1024
+)
+
+// Workload is a generator pod and the ConfigMap holding its payload, if any.
+type Workload struct {
+	ConfigMap *corev1.ConfigMap
+	Pod       *corev1.Pod
+}
+
+// DeployWorkload creates the workload and waits for its pod to be ready. The workload is
+// deleted when the test ends unless SKIP_TEARDOWN is set to true.
+func DeployWorkload(t *testing.T, clientset *kubernetes.Clientset, w Workload) {
+	t.Helper()
+	namespace := w.Pod.Namespace
+	if w.ConfigMap != nil {
+		configMaps := clientset.CoreV1().ConfigMaps(namespace)
+		_ = configMaps.Delete(t.Context(), w.ConfigMap.Name, metav1.DeleteOptions{})
+		_, err := configMaps.Create(t.Context(), w.ConfigMap, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	_, err := clientset.CoreV1().Pods(namespace).Create(t.Context(), w.Pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if os.Getenv("SKIP_TEARDOWN") == "true" {
+			return
+		}
+		// Use context.Background because t.Context() is already cancelled during t.Cleanup.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute) //nolint:usetesting
+		defer cancel()
+		grace := int64(5)
+		_ = clientset.CoreV1().Pods(namespace).Delete(ctx, w.Pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+		if w.ConfigMap != nil {
+			_ = clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, w.ConfigMap.Name, metav1.DeleteOptions{})
+		}
+	})
+
+	CheckPodsReady(t, clientset, namespace, "app="+w.Pod.Labels["app"], 2*time.Minute, 0)
+}
+
+func generatorPod(name, namespace string, annotations map[string]string, container corev1.Container, volumes ...corev1.Volume) *corev1.Pod {
+	if container.Name == "" {
+		container.Name = generatorContainerName
+	}
+	// The pinned helper image is part of the air-gapped image inventory.
+	container.Image = fileCopyHelperImage
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{"app": name},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			TerminationGracePeriodSeconds: ptrInt64(5),
+			Containers:                    []corev1.Container{container},
+			Volumes:                       volumes,
+		},
+	}
+}
+
+func generatorConfigMap(name, namespace string, data map[string]string) (*corev1.ConfigMap, error) {
+	size := 0
+	for key, value := range data {
+		size += len(key) + len(value)
+	}
+	if size > maxGeneratorConfigMapSize {
+		return nil, fmt.Errorf("generator %s payload is %d bytes, more than the %d a ConfigMap can hold", name, size, maxGeneratorConfigMapSize)
+	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": name}},
+		Data:       data,
+	}, nil
+}
+
+func configMapVolume(name string, items ...corev1.KeyToPath) corev1.Volume {
+	return corev1.Volume{
+		Name: "payload",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Items: items},
+		},
+	}
+}
+
+func ptrInt64(v int64) *int64 { return &v }
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// LogFormat is the format of the first line of each generated log record.
+type LogFormat string
+
+const (
+	// LogFormatPlain lines look like "<name> seq=00000042 xxxx".
+	LogFormatPlain LogFormat = "plain"
+	// LogFormatJSON lines look like {"generator":"<name>","seq":"00000042","pad":"xxxx"}.
+	LogFormatJSON LogFormat = "json"
+)
+
+// RuntimeFormat is the container runtime log file format the generator writes directly under
+// /var/log/pods, bypassing the container runtime, to exercise the chart's format parsers.
+type RuntimeFormat string
+
+const (
+	RuntimeFormatCRIO       RuntimeFormat = "cri-o"
+	RuntimeFormatContainerd RuntimeFormat = "containerd"
+	RuntimeFormatDocker     RuntimeFormat = "docker"
+)
+
+// LogGenerator builds a pod that writes numbered log records. Every record starts with a line
+// carrying the generator name and an 8 digit sequence number, so records can be told apart and
+// reconciled with what the sinks received.
+type LogGenerator struct {
+	Name string
+	// Namespace defaults to DefaultNamespace.
+	Namespace string
+	Records   int
+	// Rate limits the records written per second; 0 writes as fast as possible. With a
+	// RuntimeFormat it limits the log file entries instead.
+	Rate int
+	// Size pads the first line of each record to at least this many bytes.
+	Size int
+	// Format defaults to LogFormatPlain.
+	Format LogFormat
+	// Multiline lines are written after the first line of every record, e.g. stack frames.
+	// The expected body is the record lines joined with "\n".
+	Multiline []string
+	// RuntimeFormat writes the records to a log file under /var/log/pods in the runtime format
+	// instead of stdout. It needs privileged pods.
+	RuntimeFormat RuntimeFormat
+	// PartialSize splits RuntimeFormat lines into partial entries of at most this many bytes.
+	PartialSize int
+	// Start is the timestamp of the first RuntimeFormat entry; defaults to the current time
+	// so that re-runs do not produce files with the fingerprint of an earlier run.
+	Start time.Time
+}
+
+// ExpectedLog is a log record a generator produces.
+type ExpectedLog struct {
+	// Stream is the name of the generator, which every record carries.
+	Stream     string
+	Seq        int
+	Body       string
+	Attributes map[string]string
+}
+
+func (g LogGenerator) namespace() string {
+	if g.Namespace == "" {
+		return DefaultNamespace
+	}
+	return g.Namespace
+}
+
+// linePrefixSuffix returns what surrounds the sequence number on the first line of a record.
+func (g LogGenerator) linePrefixSuffix() (string, string) {
+	if g.Format == LogFormatJSON {
+		prefix := `{"generator":"` + g.Name + `","seq":"`
+		padLen := max(0, g.Size-len(prefix)-8-len(`","pad":""}`))
+		return prefix, `","pad":"` + strings.Repeat("x", padLen) + `"}`
+	}
+	prefix := g.Name + " seq="
+	if padLen := g.Size - len(prefix) - 8 - 1; padLen > 0 {
+		return prefix, " " + strings.Repeat("x", padLen)
+	}
+	return prefix, ""
+}
+
+// Line returns the first line of the record with the sequence number.
+func (g LogGenerator) Line(seq int) string {
+	prefix, suffix := g.linePrefixSuffix()
+	return fmt.Sprintf("%s%08d%s", prefix, seq, suffix)
+}
+
+// Expected returns the records in the order they are written.
+func (g LogGenerator) Expected() []ExpectedLog {
+	container := generatorContainerName
+	if g.RuntimeFormat != "" {
+		container = generatedContainerName
+	}
+	expected := make([]ExpectedLog, g.Records)
+	for seq := range expected {
+		expected[seq] = ExpectedLog{
+			Stream: g.Name,
+			Seq:    seq,
+			Body:   strings.Join(append([]string{g.Line(seq)}, g.Multiline...), "\n"),
+			Attributes: map[string]string{
+				"k8s.namespace.name": g.namespace(),
+				"k8s.pod.name":       g.Name,
+				"k8s.container.name": container,
+			},
+		}
+	}
+	return expected
+}
+
+// Workload returns the generator pod, and for a RuntimeFormat the ConfigMap with the log file.
+func (g LogGenerator) Workload() (Workload, error) {
+	if g.Name == "" || g.Records <= 0 {
+		return Workload{}, errors.New("a log generator needs a name and a positive record count")
+	}
+	switch g.Format {
+	case "", LogFormatPlain, LogFormatJSON:
+	default:
+		return Workload{}, fmt.Errorf("unknown log format %q", g.Format)
+	}
+	if g.RuntimeFormat != "" {
+		return g.runtimeWorkload()
+	}
+
+	prefix, suffix := g.linePrefixSuffix()
+	var script strings.Builder
+	fmt.Fprintf(&script, "i=0\nwhile [ \"$i\" -lt %d ]; do\n", g.Records)
+	fmt.Fprintf(&script, "  printf '%%s%%08d%%s\\n' %s \"$i\" %s\n", shellQuote(prefix), shellQuote(suffix))
+	for _, line := range g.Multiline {
+		fmt.Fprintf(&script, "  printf '%%s\\n' %s\n", shellQuote(line))
+	}
+	script.WriteString("  i=$((i+1))\n")
+	if g.Rate > 0 {
+		fmt.Fprintf(&script, "  if [ $((i %% %d)) -eq 0 ]; then sleep 1; fi\n", g.Rate)
+	}
+	script.WriteString("done\nsleep infinity\n")
+
+	return Workload{Pod: generatorPod(g.Name, g.namespace(), nil, corev1.Container{
+		Command: []string{"sh", "-c", script.String()},
+	})}, nil
+}
+
+func (g LogGenerator) runtimeWorkload() (Workload, error) {
+	logFile, err := g.RuntimeLogFile()
+	if err != nil {
+		return Workload{}, err
+	}
+	configMap, err := generatorConfigMap(g.Name, g.namespace(), map[string]string{"0.log": logFile})
+	if err != nil {
+		return Workload{}, err
+	}
+	// The directory follows the kubelet layout the chart extracts the pod metadata from.
+	dir := fmt.Sprintf("/var/log/pods/%s_%s_%s/%s", g.namespace(), g.Name, uuid.NewUUID(), generatedContainerName)
+	var script strings.Builder
+	fmt.Fprintf(&script, "dir=%s\nmkdir -p \"$dir\"\n", shellQuote(dir))
+	// The file would otherwise outlive the pod on the node.
+	script.WriteString("trap 'rm -rf \"$(dirname \"$dir\")\"; exit 0' TERM\n")
+	script.WriteString("n=0\nwhile IFS= read -r line; do\n  printf '%s\\n' \"$line\" >> \"$dir/0.log\"\n  n=$((n+1))\n")
+	if g.Rate > 0 {
+		fmt.Fprintf(&script, "  if [ $((n %% %d)) -eq 0 ]; then sleep 1; fi\n", g.Rate)
+	}
+	script.WriteString("done < /payload/0.log\nsleep infinity &\nwait\n")
+
+	privileged := true
+	pod := generatorPod(g.Name, g.namespace(), nil, corev1.Container{
+		Command:         []string{"sh", "-c", script.String()},
+		SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "payload", MountPath: "/payload"},
+			{Name: "varlogpods", MountPath: "/var/log/pods"},
+		},
+	}, configMapVolume(g.Name), corev1.Volume{
+		Name:         "varlogpods",
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log/pods"}},
+	})
+	return Workload{ConfigMap: configMap, Pod: pod}, nil
+}
+
+// RuntimeLogFile renders the records in the RuntimeFormat, one entry per line or partial chunk.
+func (g LogGenerator) RuntimeLogFile() (string, error) {
+	start := g.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	var out strings.Builder
+	entry := 0
+	for _, record := range g.Expected() {
+		for _, line := range strings.Split(record.Body, "\n") {
+			chunks := splitChunks(line, g.PartialSize)
+			for i, chunk := range chunks {
+				ts := start.Add(time.Duration(entry) * time.Millisecond).UTC()
+				entry++
+				final := i == len(chunks)-1
+				switch g.RuntimeFormat {
+				case RuntimeFormatCRIO:
+					fmt.Fprintf(&out, "%s stdout %s %s\n", ts.Format("2006-01-02T15:04:05.000000000-07:00"), partialTag(final), chunk)
+				case RuntimeFormatContainerd:
+					fmt.Fprintf(&out, "%s stdout %s %s\n", ts.Format("2006-01-02T15:04:05.000000000Z"), partialTag(final), chunk)
+				case RuntimeFormatDocker:
+					if final {
+						chunk += "\n"
+					}
+					entryJSON, err := json.Marshal(map[string]string{
+						"log":    chunk,
+						"stream": "stdout",
+						"time":   ts.Format("2006-01-02T15:04:05.000000000Z"),
+					})
+					if err != nil {
+						return "", err
+					}
+					out.Write(entryJSON)
+					out.WriteByte('\n')
+				default:
+					return "", fmt.Errorf("unknown runtime format %q", g.RuntimeFormat)
+				}
+			}
+		}
+	}
+	return out.String(), nil
+}
+
+func partialTag(final bool) string {
+	if final {
+		return "F"
+	}
+	return "P"
+}
+
+func splitChunks(line string, size int) []string {
+	if size <= 0 || len(line) <= size {
+		return []string{line}
+	}
+	var chunks []string
+	for len(line) > size {
+		chunks = append(chunks, line[:size])
+		line = line[size:]
+	}
+	return append(chunks, line)
+}
+
+// PrometheusMetric is a sample a PrometheusGenerator exposes.
+type PrometheusMetric struct {
+	Name string
+	// Type is counter or gauge; defaults to gauge.
+	Type   string
+	Help   string
+	Labels map[string]string
+	Value  float64
+}
+
+// PrometheusGenerator builds a pod serving fixed metrics in the Prometheus text format, with
+// the prometheus.io annotations the agent autodetects scrape targets from.
+type PrometheusGenerator struct {
+	Name      string
+	Namespace string
+	// Port defaults to 9090, the default of the autodetect rule, and Path to /metrics.txt. httpd
+	// derives the Content-Type from the file extension, so a custom Path should end in .txt for
+	// the metrics to be served as text/plain.
+	Port    int
+	Path    string
+	Metrics []PrometheusMetric
+}
+
+// ExpectedMetric is a data point a generator produces.
+type ExpectedMetric struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+func (g PrometheusGenerator) portAndPath() (int, string) {
+	port, metricsPath := g.Port, g.Path
+	if port == 0 {
+		port = 9090
+	}
+	if metricsPath == "" {
+		metricsPath = "/metrics.txt"
+	}
+	return port, metricsPath
+}
+
+// Exposition renders the metrics in the Prometheus text format.
+func (g PrometheusGenerator) Exposition() string {
+	byName := map[string][]PrometheusMetric{}
+	var names []string
+	for _, m := range g.Metrics {
+		if _, ok := byName[m.Name]; !ok {
+			names = append(names, m.Name)
+		}
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+	var out strings.Builder
+	for _, name := range names {
+		samples := byName[name]
+		metricType := samples[0].Type
+		if metricType == "" {
+			metricType = "gauge"
+		}
+		if samples[0].Help != "" {
+			fmt.Fprintf(&out, "# HELP %s %s\n", name, samples[0].Help)
+		}
+		fmt.Fprintf(&out, "# TYPE %s %s\n", name, metricType)
+		for _, m := range samples {
+			fmt.Fprintf(&out, "%s%s %s\n", name, formatPromLabels(m.Labels), strconv.FormatFloat(m.Value, 'g', -1, 64))
+		}
+	}
+	return out.String()
+}
+
+func formatPromLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", key, labels[key])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Expected returns the data points the scrape produces, without the labels the scraper adds.
+func (g PrometheusGenerator) Expected() []ExpectedMetric {
+	expected := make([]ExpectedMetric, len(g.Metrics))
+	for i, m := range g.Metrics {
+		expected[i] = ExpectedMetric{Name: m.Name, Labels: m.Labels, Value: m.Value}
+	}
+	return expected
+}
+
+// Workload returns the pod serving the metrics with busybox httpd and the ConfigMap holding them.
+func (g PrometheusGenerator) Workload() (Workload, error) {
+	if g.Name == "" || len(g.Metrics) == 0 {
+		return Workload{}, errors.New("a prometheus generator needs a name and metrics")
+	}
+	port, metricsPath := g.portAndPath()
+	configMap, err := generatorConfigMap(g.Name, g.Namespace, map[string]string{"metrics": g.Exposition()})
+	if err != nil {
+		return Workload{}, err
+	}
+	pod := generatorPod(g.Name, g.Namespace, map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   strconv.Itoa(port),
+		"prometheus.io/path":   metricsPath,
+	}, corev1.Container{
+		Command:      []string{"httpd", "-f", "-p", strconv.Itoa(port), "-h", "/www"},
+		Ports:        []corev1.ContainerPort{{Name: "metrics", ContainerPort: int32(port)}},
+		VolumeMounts: []corev1.VolumeMount{{Name: "payload", MountPath: "/www"}},
+	}, configMapVolume(g.Name, corev1.KeyToPath{Key: "metrics", Path: strings.TrimPrefix(path.Clean(metricsPath), "/")}))
+	return Workload{ConfigMap: configMap, Pod: pod}, nil
+}
+
+// SpanGenerator builds a pod that sends traces over OTLP/HTTP. Every trace follows the
+// topology: a SERVER root span in the client service of the first edge, then for each edge a
+// CLIENT span in the client service and a SERVER span in the server service.
+type SpanGenerator struct {
+	Name      string
+	Namespace string
+	// Edges must be ordered so that the client of every edge is the root service or the
+	// server of an earlier edge. Without edges every trace is a single span in the Name service.
+	Edges  []ServiceEdge
+	Traces int
+	// Interval is the pause between two traces.
+	Interval time.Duration
+	// Endpoint defaults to the agent OTLP/HTTP port on the node, http://$(HOST_IP):4318.
+	Endpoint string
+	// Seed is mixed into the trace and span IDs and Start is the start time of the first
+	// trace. Both default to values chosen once per test process, so that Expected and
+	// Workload agree while the spans of a re-run never collide with those of an earlier run.
+	Seed  string
+	Start time.Time
+}
+
+var (
+	spanGeneratorSeed  = string(uuid.NewUUID())
+	spanGeneratorStart = time.Now().UTC().Truncate(time.Second)
+)
+
+// Expected returns the traces the generator sends, one batch per trace.
+func (g SpanGenerator) Expected() ([]ptrace.Traces, error) {
+	seed, start := g.Seed, g.Start
+	if seed == "" {
+		seed = spanGeneratorSeed
+	}
+	if start.IsZero() {
+		start = spanGeneratorStart
+	}
+	root := g.Name
+	if len(g.Edges) > 0 {
+		root = g.Edges[0].Client
+	}
+	traces := make([]ptrace.Traces, g.Traces)
+	for i := range traces {
+		td := ptrace.NewTraces()
+		resources := map[string]ptrace.SpanSlice{}
+		spansOf := func(service string) ptrace.SpanSlice {
+			if spans, ok := resources[service]; ok {
+				return spans
+			}
+			rs := td.ResourceSpans().AppendEmpty()
+			rs.Resource().Attributes().PutStr("service.name", service)
+			rs.Resource().Attributes().PutStr("generator.name", g.Name)
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName("splunk-otel-collector-chart/functional_tests")
+			resources[service] = ss.Spans()
+			return ss.Spans()
+		}
+		var traceID pcommon.TraceID
+		copy(traceID[:], generatedID(seed, g.Name, strconv.Itoa(i)))
+		traceStart := start.Add(time.Duration(i) * time.Second)
+		spanIndex := 0
+		addSpan := func(service, name string, kind ptrace.SpanKind, parent pcommon.SpanID) pcommon.SpanID {
+			span := spansOf(service).AppendEmpty()
+			span.SetTraceID(traceID)
+			var id pcommon.SpanID
+			copy(id[:], generatedID(seed, g.Name, strconv.Itoa(i), strconv.Itoa(spanIndex)))
+			span.SetSpanID(id)
+			span.SetParentSpanID(parent)
+			span.SetName(name)
+			span.SetKind(kind)
+			span.SetStartTimestamp(pcommon.NewTimestampFromTime(traceStart.Add(time.Duration(spanIndex) * time.Millisecond)))
+			span.SetEndTimestamp(pcommon.NewTimestampFromTime(traceStart.Add(100 * time.Millisecond)))
+			span.Attributes().PutInt("generator.trace", int64(i))
+			spanIndex++
+			return id
+		}
+		serverSpans := map[string]pcommon.SpanID{
+			root: addSpan(root, "GET /", ptrace.SpanKindServer, pcommon.NewSpanIDEmpty()),
+		}
+		for _, edge := range g.Edges {
+			parent, ok := serverSpans[edge.Client]
+			if !ok {
+				return nil, fmt.Errorf("edge %s: %s has no incoming call before it", edge, edge.Client)
+			}
+			clientSpan := addSpan(edge.Client, "GET /"+edge.Server, ptrace.SpanKindClient, parent)
+			serverSpans[edge.Server] = addSpan(edge.Server, "GET /"+edge.Server, ptrace.SpanKindServer, clientSpan)
+		}
+		traces[i] = td
+	}
+	return traces, nil
+}
+
+// generatedID derives the trace and span IDs from the seed, so that Expected and Workload agree.
+func generatedID(parts ...string) []byte {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	return sum[:]
+}
+
+// Workload returns the pod posting the traces and the ConfigMap holding them as OTLP/JSON.
+func (g SpanGenerator) Workload() (Workload, error) {
+	if g.Name == "" || g.Traces <= 0 {
+		return Workload{}, errors.New("a span generator needs a name and a positive trace count")
+	}
+	traces, err := g.Expected()
+	if err != nil {
+		return Workload{}, err
+	}
+	marshaler := &ptrace.JSONMarshaler{}
+	data := map[string]string{}
+	for i, td := range traces {
+		payload, marshalErr := marshaler.MarshalTraces(td)
+		if marshalErr != nil {
+			return Workload{}, marshalErr
+		}
+		data[fmt.Sprintf("trace-%06d.json", i)] = string(payload)
+	}
+	configMap, err := generatorConfigMap(g.Name, g.Namespace, data)
+	if err != nil {
+		return Workload{}, err
+	}
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "http://$(HOST_IP):4318"
+	}
+	script := fmt.Sprintf(`for f in /payload/trace-*.json; do
+  until wget -q -O /dev/null --header 'Content-Type: application/json' --post-file "$f" "$ENDPOINT/v1/traces"; do sleep 2; done
+  sleep %s
+done
+sleep infinity
+`, strconv.FormatFloat(g.Interval.Seconds(), 'f', -1, 64))
+	pod := generatorPod(g.Name, g.Namespace, nil, corev1.Container{
+		Command: []string{"sh", "-c", script},
+		Env: []corev1.EnvVar{
+			{Name: "HOST_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"}}},
+			{Name: "ENDPOINT", Value: endpoint},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: "payload", MountPath: "/payload"}},
+	}, configMapVolume(g.Name))
+	return Workload{ConfigMap: configMap, Pod: pod}, nil
+}
@@ -0,0 +1,192 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestLogGeneratorWorkloadMatchesExpected(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		generator LogGenerator
+	}{
+		{
+			name:      "plain padded",
+			generator: LogGenerator{Name: "gen", Records: 3, Size: 40},
+		},
+		{
+			name:      "json",
+			generator: LogGenerator{Name: "gen", Records: 2, Format: LogFormatJSON, Size: 64},
+		},
+		{
+			name: "multiline with quotes",
+			generator: LogGenerator{Name: "gen", Records: 2, Multiline: []string{
+				"\tat com.example.Main.run(Main.java:42)",
+				"Caused by: it's 100% broken",
+			}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			w, err := testCase.generator.Workload()
+			require.NoError(t, err)
+			script := w.Pod.Spec.Containers[0].Command[2]
+			script = strings.TrimSuffix(script, "sleep infinity\n")
+
+			out, err := exec.CommandContext(t.Context(), "sh", "-c", script).Output()
+			require.NoError(t, err)
+			var bodies []string
+			for _, record := range testCase.generator.Expected() {
+				bodies = append(bodies, record.Body)
+				if testCase.generator.Size > 0 {
+					require.GreaterOrEqual(t, len(strings.SplitN(record.Body, "\n", 2)[0]), testCase.generator.Size)
+				}
+			}
+			require.Equal(t, strings.Join(bodies, "\n")+"\n", string(out))
+		})
+	}
+}
+
+func TestLogGeneratorExpected(t *testing.T) {
+	t.Parallel()
+
+	expected := LogGenerator{Name: "gen", Namespace: "logs-test", Records: 2}.Expected()
+	require.Equal(t, []ExpectedLog{
+		{Stream: "gen", Seq: 0, Body: "gen seq=00000000", Attributes: map[string]string{
+			"k8s.namespace.name": "logs-test", "k8s.pod.name": "gen", "k8s.container.name": "generator",
+		}},
+		{Stream: "gen", Seq: 1, Body: "gen seq=00000001", Attributes: map[string]string{
+			"k8s.namespace.name": "logs-test", "k8s.pod.name": "gen", "k8s.container.name": "generator",
+		}},
+	}, expected)
+}
+
+func TestLogGeneratorRuntimeLogFile(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		format   RuntimeFormat
+		expected string
+	}{
+		{
+			format: RuntimeFormatCRIO,
+			expected: "2026-01-01T00:00:00.000000000+00:00 stdout P gen seq=0\n" +
+				"2026-01-01T00:00:00.001000000+00:00 stdout F 0000000\n",
+		},
+		{
+			format: RuntimeFormatContainerd,
+			expected: "2026-01-01T00:00:00.000000000Z stdout P gen seq=0\n" +
+				"2026-01-01T00:00:00.001000000Z stdout F 0000000\n",
+		},
+		{
+			format: RuntimeFormatDocker,
+			expected: `{"log":"gen seq=0","stream":"stdout","time":"2026-01-01T00:00:00.000000000Z"}` + "\n" +
+				`{"log":"0000000\n","stream":"stdout","time":"2026-01-01T00:00:00.001000000Z"}` + "\n",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(string(testCase.format), func(t *testing.T) {
+			t.Parallel()
+			g := LogGenerator{Name: "gen", Records: 1, RuntimeFormat: testCase.format, PartialSize: 9, Start: start}
+			logFile, err := g.RuntimeLogFile()
+			require.NoError(t, err)
+			require.Equal(t, testCase.expected, logFile)
+
+			w, err := g.Workload()
+			require.NoError(t, err)
+			require.Equal(t, logFile, w.ConfigMap.Data["0.log"])
+			require.True(t, *w.Pod.Spec.Containers[0].SecurityContext.Privileged)
+			require.Equal(t, "generated", g.Expected()[0].Attributes["k8s.container.name"])
+		})
+	}
+}
+
+func TestPrometheusGenerator(t *testing.T) {
+	t.Parallel()
+
+	g := PrometheusGenerator{Name: "prom", Path: "/custom/metrics.txt", Metrics: []PrometheusMetric{
+		{Name: "gen_requests_total", Type: "counter", Help: "Requests.", Labels: map[string]string{"path": "/", "code": "200"}, Value: 42},
+		{Name: "gen_temperature", Value: 21.5},
+		{Name: "gen_requests_total", Type: "counter", Labels: map[string]string{"path": "/", "code": "500"}, Value: 1},
+	}}
+	require.Equal(t, `# HELP gen_requests_total Requests.
+# TYPE gen_requests_total counter
+gen_requests_total{code="200",path="/"} 42
+gen_requests_total{code="500",path="/"} 1
+# TYPE gen_temperature gauge
+gen_temperature 21.5
+`, g.Exposition())
+
+	w, err := g.Workload()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   "9090",
+		"prometheus.io/path":   "/custom/metrics.txt",
+	}, w.Pod.Annotations)
+	require.Equal(t, "custom/metrics.txt", w.Pod.Spec.Volumes[0].ConfigMap.Items[0].Path)
+	require.Len(t, g.Expected(), 3)
+
+	g.Path = ""
+	w, err = g.Workload()
+	require.NoError(t, err)
+	require.Equal(t, "/metrics.txt", w.Pod.Annotations["prometheus.io/path"])
+}
+
+func TestSpanGenerator(t *testing.T) {
+	t.Parallel()
+
+	g := SpanGenerator{Name: "shop", Traces: 2, Edges: []ServiceEdge{
+		{Client: "frontend", Server: "cart"},
+		{Client: "cart", Server: "redis"},
+		{Client: "frontend", Server: "checkout"},
+	}}
+	traces, err := g.Expected()
+	require.NoError(t, err)
+	require.Len(t, traces, 2)
+
+	trees := GroupSpansByTrace(traces)
+	require.Len(t, trees, 2)
+	for _, tree := range trees {
+		require.NoError(t, tree.Validate())
+		require.ElementsMatch(t, g.Edges, tree.ServiceEdges())
+		require.Len(t, tree.Roots(), 1)
+	}
+
+	again, err := g.Expected()
+	require.NoError(t, err)
+	require.Equal(t, traces[1].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID(),
+		again[1].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID())
+
+	rerun := g
+	rerun.Seed = "rerun"
+	other, err := rerun.Expected()
+	require.NoError(t, err)
+	require.NotEqual(t, traces[1].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID(),
+		other[1].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID())
+
+	w, err := g.Workload()
+	require.NoError(t, err)
+	require.Len(t, w.ConfigMap.Data, 2)
+	unmarshaled, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces([]byte(w.ConfigMap.Data["trace-000000.json"]))
+	require.NoError(t, err)
+	require.Equal(t, 1+2*len(g.Edges), unmarshaled.SpanCount())
+
+	_, err = SpanGenerator{Name: "bad", Traces: 1, Edges: []ServiceEdge{
+		{Client: "a", Server: "b"},
+		{Client: "c", Server: "d"},
+	}}.Expected()
+	require.ErrorContains(t, err, "c has no incoming call")
+}
@@ -296,6 +296,7 @@ func sendWorkloadHTTPRequests(t *testing.T, requests []request) {
 }
 
 func Test_IstioMetrics(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		t.Log("Running teardown before setup as TEARDOWN_BEFORE_SETUP is set to true")
 		teardown(t)
@@ -392,6 +393,7 @@ func testIstioMetrics(t *testing.T, expectedMetricsFile string, includeMetricNam
 }
 
 func Test_IstioTraces(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		t.Log("Running teardown before setup as TEARDOWN_BEFORE_SETUP is set to true")
 		teardown(t)
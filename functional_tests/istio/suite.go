@@ -0,0 +1,14 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package istio
+
+import "github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+
+var Requirements = internal.SuiteRequirements{
+	Name: "istio",
+	Capabilities: []internal.Capability{
+		internal.CapabilityHostSinks, internal.CapabilityNodePorts, internal.CapabilityClusterAdmin, internal.CapabilityInternet,
+	},
+	ClusterExclusive: true,
+}
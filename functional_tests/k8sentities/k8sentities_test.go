@@ -11,67 +11,49 @@
 // data to arrive at a local OTLP HTTP sink that mimics the v3/event endpoint,
 // and compares the collected logs against a golden file.
 //
-// Environment variables (all optional):
-//
-//	TEARDOWN_BEFORE_SETUP    – run teardown before setup when set to "true"
-//	SKIP_SETUP               – skip chart installation when set to "true"
-//	SKIP_TEARDOWN            – skip chart uninstall in cleanup when set to "true"
-//	SKIP_TESTS               – skip assertions when set to "true"
-//	UPDATE_EXPECTED_RESULTS  – overwrite golden file with actual results when set to "true"
-//	KUBECONFIG               – path to a kubeconfig file (required for setup/teardown)
+// The suite honours the lifecycle environment variables of internal.Suite, and
+// UPDATE_EXPECTED_RESULTS overwrites the golden file with the actual results when set to "true".
 package k8sentities
 
 import (
-	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"testing"
 	"time"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/golden"
-	k8stest "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/xk8stest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
 )
 
-const otlpEntitiesPort = 4319
-
-var entitiesLogsSink *consumertest.LogsSink
-
-func Test_K8SEntities(t *testing.T) {
-	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
-		t.Log("Running teardown before setup as TEARDOWN_BEFORE_SETUP is set to true")
-		testKubeConfig, setKubeConfig := os.LookupEnv("KUBECONFIG")
-		require.True(t, setKubeConfig, "the environment variable KUBECONFIG must be set")
-		k8sClient, err := k8stest.NewK8sClient(testKubeConfig)
-		require.NoError(t, err)
-		teardown(t, k8sClient)
-	}
-
-	internal.SetupSignalFxAPIServer(t)
+const (
+	otlpEntitiesPort = 4319
+	entitiesSink     = "entities"
+)
 
+var suite = internal.Suite{
+	SuiteRequirements: Requirements,
+	SignalFxAPI:       true,
 	// Receive OTLP logs sent by the otlp_http/o11y_entities exporter to the /v3/event path.
-	entitiesLogsSink = internal.SetupOTLPLogsSinkOnPort(t, otlpEntitiesPort, "/v3/event")
-
-	if os.Getenv("SKIP_SETUP") == "true" {
-		t.Log("Skipping setup as SKIP_SETUP is set to true")
-	} else {
-		deployCollector(t)
-	}
+	Sinks:          []internal.SuiteSink{internal.OTLPLogsSuiteSink(entitiesSink, otlpEntitiesPort, "/v3/event")},
+	ValuesTemplate: "k8sentities_values.yaml.tmpl",
+	Replacements: map[string]any{
+		"IngestURL": internal.SinkURL(otlpEntitiesPort),
+		"ApiURL":    internal.SinkURL(internal.SignalFxAPIPort),
+	},
+	Deploy: waitForClusterReceiver,
+}
 
-	if os.Getenv("SKIP_TESTS") == "true" {
-		t.Log("Skipping tests as SKIP_TESTS is set to true")
-		return
-	}
+func Test_K8SEntities(t *testing.T) {
+	suite.Run(t, testK8SEntities)
+}
 
+func testK8SEntities(t *testing.T, env *internal.SuiteEnv) {
+	entitiesLogsSink := env.LogsSink(t, entitiesSink)
 	internal.WaitForLogs(t, 1, entitiesLogsSink)
 
 	t.Run("CheckK8SEntitiesLogs", func(t *testing.T) {
@@ -185,63 +167,19 @@ func Test_K8SEntities(t *testing.T) {
 	})
 }
 
-func deployCollector(t *testing.T) {
-	testKubeConfig, setKubeConfig := os.LookupEnv("KUBECONFIG")
-	require.True(t, setKubeConfig, "the environment variable KUBECONFIG must be set")
-
-	hostEp := internal.HostEndpoint(t)
-	require.NotEmpty(t, hostEp, "host endpoint not found")
-
-	valuesFile, err := filepath.Abs(filepath.Join("testdata", "k8sentities_values.yaml.tmpl"))
-	require.NoError(t, err)
-
-	replacements := map[string]any{
-		"IngestURL": internal.HostPortHTTP(hostEp, otlpEntitiesPort),
-		"ApiURL":    internal.HostPortHTTP(hostEp, internal.SignalFxAPIPort),
-	}
-
-	internal.ChartInstallOrUpgrade(t, testKubeConfig, valuesFile, replacements, 0, internal.GetDefaultChartOptions())
-
-	config, err := clientcmd.BuildConfigFromFlags("", testKubeConfig)
-	require.NoError(t, err)
-	clientset, err := kubernetes.NewForConfig(config)
-	require.NoError(t, err)
-
-	internal.CheckPodsReady(t, clientset, internal.DefaultNamespace, "component=otel-k8s-cluster-receiver", 3*time.Minute, 0)
+func waitForClusterReceiver(t *testing.T, env *internal.SuiteEnv) {
+	internal.CheckPodsReady(t, env.Clientset, internal.DefaultNamespace, "component=otel-k8s-cluster-receiver", 3*time.Minute, 0)
 	// Give the cluster receiver time to emit entity data.
 	time.Sleep(30 * time.Second)
-
-	k8sClient, err := k8stest.NewK8sClient(testKubeConfig)
-	require.NoError(t, err)
-
-	t.Cleanup(func() {
-		if os.Getenv("SKIP_TEARDOWN") == "true" {
-			t.Log("Skipping teardown as SKIP_TEARDOWN is set to true")
-			return
-		}
-		teardown(t, k8sClient)
-	})
-}
-
-func teardown(t *testing.T, _ *k8stest.K8sClient) {
-	testKubeConfig := os.Getenv("KUBECONFIG")
-	internal.ChartUninstall(t, testKubeConfig)
 }
 
 // extractEntityTypes returns the set of entity types found in the logs.
 func extractEntityTypes(logs plog.Logs) map[string]struct{} {
 	entityTypes := make(map[string]struct{})
-	rl := logs.ResourceLogs()
-	for i := 0; i < rl.Len(); i++ {
-		sl := rl.At(i).ScopeLogs()
-		for j := 0; j < sl.Len(); j++ {
-			lr := sl.At(j).LogRecords()
-			for k := 0; k < lr.Len(); k++ {
-				if v, ok := lr.At(k).Attributes().Get("otel.entity.type"); ok {
-					entityTypes[v.Str()] = struct{}{}
-				}
-			}
-		}
+	for _, et := range internal.DistinctValues(internal.QueryLogs([]plog.Logs{logs}), func(r internal.LogRow) (string, bool) {
+		return r.Attr("otel.entity.type")
+	}) {
+		entityTypes[et] = struct{}{}
 	}
 	return entityTypes
 }
@@ -257,26 +195,15 @@ type entityKeys struct {
 // interest here.
 func entityRecordKeysByType(logs plog.Logs) map[string][]entityKeys {
 	result := make(map[string][]entityKeys)
-	rl := logs.ResourceLogs()
-	for i := 0; i < rl.Len(); i++ {
-		sl := rl.At(i).ScopeLogs()
-		for j := 0; j < sl.Len(); j++ {
-			lr := sl.At(j).LogRecords()
-			for k := 0; k < lr.Len(); k++ {
-				attrs := lr.At(k).Attributes()
-				etypeVal, ok := attrs.Get("otel.entity.type")
-				if !ok {
-					continue
-				}
-				if et, hasEvent := attrs.Get("otel.entity.event.type"); !hasEvent || et.Str() != "entity_state" {
-					continue
-				}
-				result[etypeVal.Str()] = append(result[etypeVal.Str()], entityKeys{
-					id:    mapKeys(attrs, "otel.entity.id"),
-					attrs: mapKeys(attrs, "otel.entity.attributes"),
-				})
-			}
-		}
+	rows := internal.QueryLogs([]plog.Logs{logs},
+		internal.WithRecordAttributeKey("otel.entity.type"),
+		internal.WithRecordAttribute("otel.entity.event.type", "entity_state"))
+	for _, row := range rows {
+		etype, _ := row.Attr("otel.entity.type")
+		result[etype] = append(result[etype], entityKeys{
+			id:    mapKeys(row.Attributes, "otel.entity.id"),
+			attrs: mapKeys(row.Attributes, "otel.entity.attributes"),
+		})
 	}
 	return result
 }
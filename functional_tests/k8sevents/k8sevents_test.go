@@ -5,8 +5,6 @@ package k8sevents
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"testing"
@@ -19,14 +17,10 @@ import (
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
 )
 
-var eventsLogsConsumer *consumertest.LogsSink
-
 // Regexes for normalizing k8s event log bodies.
 var (
 	rePulledImage      = regexp.MustCompile(`Successfully pulled image "(busybox|alpine):latest" in .* \(.* including waiting\).*`)
@@ -34,43 +28,38 @@ var (
 	reStartedContainer = regexp.MustCompile(`Started container( .*)?`)
 )
 
-// Env vars to control the test behavior
-// TEARDOWN_BEFORE_SETUP: if set to true, the test will run teardown before setup
-// SKIP_SETUP: if set to true, the test will skip setup
-// SKIP_TEARDOWN: if set to true, the test will skip teardown
-// SKIP_TESTS: if set to true, the test will skip the test
-// UPDATE_EXPECTED_RESULTS: if set to true, the test will update the expected results
-// KUBECONFIG: the path to the kubeconfig file
-func Test_K8SEvents(t *testing.T) {
-	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
-		t.Log("Running teardown before setup as TEARDOWN_BEFORE_SETUP is set to true")
-		testKubeConfig, setKubeConfig := os.LookupEnv("KUBECONFIG")
-		require.True(t, setKubeConfig, "the environment variable KUBECONFIG must be set")
-		k8sClient, err := k8stest.NewK8sClient(testKubeConfig)
-		require.NoError(t, err)
-		teardown(t, k8sClient)
-	}
-
-	internal.SetupSignalFxAPIServer(t)
-
-	eventsLogsConsumer = internal.SetupHECLogsSink(t)
+const (
+	eventsSink    = "events"
+	testNamespace = "k8sevents-test"
+)
 
-	if os.Getenv("SKIP_SETUP") == "true" {
-		t.Log("Skipping setup as SKIP_SETUP is set to true")
-	} else {
-		deployWorkloadAndCollector(t)
-	}
+var suite = internal.Suite{
+	SuiteRequirements: Requirements,
+	SignalFxAPI:       true,
+	Sinks:             []internal.SuiteSink{internal.HECLogsSuiteSink(eventsSink)},
+	ValuesTemplate:    "k8sevents_values.yaml.tmpl",
+	Replacements: map[string]any{
+		"ApiURL": internal.SinkURL(internal.SignalFxAPIPort),
+		"LogURL": internal.SinkURL(internal.HECLogsReceiverPort),
+	},
+	Deploy:                deployWorkload,
+	Teardown:              teardownWorkload,
+	DiagnosticsNamespaces: []string{testNamespace},
+}
 
-	if os.Getenv("SKIP_TESTS") == "true" {
-		t.Log("Skipping tests as SKIP_TESTS is set to true")
-		return
-	}
+// Test_K8SEvents honours the suite lifecycle env vars (see internal.Suite) and
+// UPDATE_EXPECTED_RESULTS: if set to true, the test will update the expected results.
+func Test_K8SEvents(t *testing.T) {
+	suite.Run(t, testK8SEvents)
+}
 
+func testK8SEvents(t *testing.T, env *internal.SuiteEnv) {
+	eventsLogsConsumer := env.LogsSink(t, eventsSink)
 	internal.WaitForLogs(t, 3, eventsLogsConsumer)
 
 	t.Run("CheckK8SEventsLogs", func(t *testing.T) {
 		actualLogs := selectResLogs("com.splunk.sourcetype", "kube:events", eventsLogsConsumer)
-		k8sEventsLogs := selectLogs("k8s.namespace.name", "k8sevents-test", &actualLogs, func(body string) string {
+		k8sEventsLogs := selectLogs("k8s.namespace.name", testNamespace, &actualLogs, func(body string) string {
 			s := body
 			// 1.35+ event messages have capitalized first letter
 			if len(s) > 0 && s[0] >= 'a' && s[0] <= 'z' {
@@ -131,55 +120,21 @@ func Test_K8SEvents(t *testing.T) {
 	})
 }
 
-func deployWorkloadAndCollector(t *testing.T) {
-	testKubeConfig, setKubeConfig := os.LookupEnv("KUBECONFIG")
-	require.True(t, setKubeConfig, "the environment variable KUBECONFIG must be set")
-	k8sClient, err := k8stest.NewK8sClient(testKubeConfig)
-	require.NoError(t, err)
-
-	valuesFile, err := filepath.Abs(filepath.Join("testdata", "k8sevents_values.yaml.tmpl"))
-	require.NoError(t, err)
-	hostEp := internal.HostEndpoint(t)
-	if len(hostEp) == 0 {
-		require.Fail(t, "host endpoint not found")
-	}
-	replacements := map[string]any{
-		"ApiURL": internal.HostPortHTTP(hostEp, internal.SignalFxAPIPort),
-		"LogURL": internal.HostPortHTTP(hostEp, internal.HECLogsReceiverPort),
-	}
-	internal.ChartInstallOrUpgrade(t, testKubeConfig, valuesFile, replacements, 0, internal.GetDefaultChartOptions())
-
-	config, err := clientcmd.BuildConfigFromFlags("", testKubeConfig)
-	require.NoError(t, err)
-	clientset, err := kubernetes.NewForConfig(config)
-	require.NoError(t, err)
-
-	internal.CheckPodsReady(t, clientset, internal.DefaultNamespace, "component=otel-k8s-cluster-receiver", 3*time.Minute, 0)
+func deployWorkload(t *testing.T, env *internal.SuiteEnv) {
+	internal.CheckPodsReady(t, env.Clientset, internal.DefaultNamespace, "component=otel-k8s-cluster-receiver", 3*time.Minute, 0)
 	time.Sleep(30 * time.Second)
 
-	// Deploy the workload
-	internal.CreateNamespace(t, clientset, "k8sevents-test")
-	internal.AnnotateNamespace(t, clientset, "k8sevents-test", "com.splunk.index", "index_from_namespace")
-	createdObjs, err := k8stest.CreateObjects(k8sClient, "testdata/testobjects")
+	internal.CreateNamespace(t, env.Clientset, testNamespace)
+	internal.AnnotateNamespace(t, env.Clientset, testNamespace, "com.splunk.index", "index_from_namespace")
+	createdObjs, err := k8stest.CreateObjects(env.K8sClient(t), "testdata/testobjects")
 	require.NoError(t, err)
 	require.NotEmpty(t, createdObjs)
 
-	internal.CheckPodsReady(t, clientset, "k8sevents-test", "app=k8sevents-test", 2*time.Minute, 0)
-
-	t.Cleanup(func() {
-		if os.Getenv("SKIP_TEARDOWN") == "true" {
-			t.Log("Skipping teardown as SKIP_TEARDOWN is set to true")
-			return
-		}
-		teardown(t, k8sClient)
-	})
+	internal.CheckPodsReady(t, env.Clientset, testNamespace, "app=k8sevents-test", 2*time.Minute, 0)
 }
 
-func teardown(t *testing.T, k8sClient *k8stest.K8sClient) {
-	testKubeConfig := os.Getenv("KUBECONFIG")
-	internal.ChartUninstall(t, testKubeConfig)
-
-	internal.DeleteObject(t, k8sClient, `
+func teardownWorkload(t *testing.T, env *internal.SuiteEnv) {
+	internal.DeleteObject(t, env.K8sClient(t), `
 apiVersion: v1
 kind: Namespace
 metadata:
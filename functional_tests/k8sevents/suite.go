@@ -0,0 +1,12 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package k8sevents
+
+import "github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+
+var Requirements = internal.SuiteRequirements{
+	Name:             "k8sevents",
+	Capabilities:     []internal.Capability{internal.CapabilityHostSinks},
+	ClusterExclusive: true,
+}
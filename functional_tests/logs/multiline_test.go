@@ -42,6 +42,7 @@ const (
 func Test_MultilineLogs(t *testing.T) {
 	testKubeConfig, setKubeConfig := os.LookupEnv("KUBECONFIG")
 	require.True(t, setKubeConfig, "the environment variable KUBECONFIG must be set")
+	internal.RequireSuite(t, testKubeConfig, Requirements)
 
 	k8sClient, err := k8stest.NewK8sClient(testKubeConfig)
 	require.NoError(t, err)
@@ -4,12 +4,15 @@
 package logs
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -18,16 +21,25 @@ import (
 )
 
 const (
-	testFile                   = "test.log"
-	testDir                    = "testdata"
-	remoteTestFile             = "/tmp/temp-log-test/test.log"
-	valuesTemplateFile         = "no_drop_logs_values.yaml.tmpl"
-	dropLogsValuesTemplateFile = "drop_logs_values.yaml.tmpl"
-	testLogLineCount           = 600
+	testDir                           = "testdata"
+	remoteTestFile                    = "/tmp/temp-log-test/test.log"
+	valuesTemplateFile                = "no_drop_logs_values.yaml.tmpl"
+	dropLogsValuesTemplateFile        = "drop_logs_values.yaml.tmpl"
+	persistentQueueValuesTemplateFile = "persistent_queue_logs_values.yaml.tmpl"
+	testLogLineCount                  = 600
+	// persistentQueueAgentPath is the hostPath of the agent file storage, see
+	// splunkPlatform.sendingQueue.persistentQueue.storagePath.
+	persistentQueueAgentPath = "/var/addon/splunk/exporter_queue/agent"
+	// deliverySettle is how long the delivery is watched for late and duplicate records.
+	deliverySettle = 10 * time.Second
 )
 
 var podName string
 
+// testLogGenerator renders the test log file. Every line carries a sequence number, so that
+// the records lost, duplicated or reordered on the way can be told apart.
+var testLogGenerator = internal.LogGenerator{Name: "no-drop-logs", Records: testLogLineCount, Size: 40}
+
 // Env vars to control the test behavior
 // TEARDOWN_BEFORE_SETUP: if set to true, the test will run teardown before setup
 // SKIP_SETUP: if set to true, the test will skip setup
@@ -36,6 +48,7 @@ var podName string
 // UPDATE_EXPECTED_RESULTS: if set to true, the test will update the expected results
 // KUBECONFIG: the path to the kubeconfig file
 func Test_NoDropLogs(t *testing.T) {
+	internal.RequireSuite(t, os.Getenv("KUBECONFIG"), Requirements)
 	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
 		t.Log("Running teardown before setup as TEARDOWN_BEFORE_SETUP is set to true")
 		teardown(t)
@@ -63,12 +76,15 @@ func Test_NoDropLogs(t *testing.T) {
 		// wait to ensure the queue fills and backpressure is applied before HEC starts
 		time.Sleep(10 * time.Second)
 		logsConsumer := internal.SetupHECLogsSink(t)
+		tracker := internal.TrackLogDelivery(t, logsConsumer)
 
 		// test log file contains 600 log lines, min_size=100 so expect 6 batches
 		internal.WaitForLogs(t, 6, logsConsumer)
 		podLogs, podLogsErr := internal.GetPodLogs(t, clientset, internal.DefaultNamespace, podName, internal.CollectorContainerName, 1000)
 		require.NoError(t, podLogsErr, "failed to get logs for pod: %s", podName)
 		require.NotContains(t, podLogs, "Exporting failed. Rejecting data.", "unexpected drop log message found — records shouldn't be dropped with noDropLogsPipeline feature gate")
+		report := internal.WaitForDelivery(t, tracker, internal.ExpectedLogStreams(testLogGenerator.Expected()), deliverySettle, 3*time.Minute)
+		internal.AssertDelivery(t, report, 0)
 		require.Equal(t, testLogLineCount, logsConsumer.LogRecordCount(), "expected number of log records does not match what received")
 		if os.Getenv("SKIP_TEARDOWN") != "true" {
 			teardown(t)
@@ -86,11 +102,48 @@ func Test_NoDropLogs(t *testing.T) {
 		time.Sleep(10 * time.Second)
 
 		logsConsumer := internal.SetupHECLogsSink(t)
+		tracker := internal.TrackLogDelivery(t, logsConsumer)
 		internal.WaitForLogs(t, 1, logsConsumer)
 
 		podLogs, podLogsErr := internal.GetPodLogs(t, clientset, internal.DefaultNamespace, podName, internal.CollectorContainerName, 1000)
 		require.NoError(t, podLogsErr, "failed to get logs for pod: %s", podName)
 		require.Contains(t, podLogs, "Exporting failed. Rejecting data.", "expected drop log message not found — records should be dropped without noDropLogsPipeline feature gate")
+		report := internal.WaitForDelivery(t, tracker, internal.ExpectedLogStreams(testLogGenerator.Expected()), deliverySettle, 3*time.Minute)
+		require.Positive(t, report.Lost(), "expected records to be lost without noDropLogsPipeline feature gate")
+		if os.Getenv("SKIP_TEARDOWN") != "true" {
+			teardown(t)
+		}
+	})
+
+	// PersistentQueue: the sending queue is backed by the file storage extension. The agent is
+	// killed while the records wait in the queue for HEC; its replacement on the same node must
+	// deliver them all from the queue files, with at most a few duplicates from retried requests.
+	t.Run("PersistentQueue", func(t *testing.T) {
+		if os.Getenv("SKIP_SETUP") != "true" {
+			teardown(t)
+			nodeExec := internal.PodNodeExecutor(t, testKubeConfig)
+			clearPersistentQueue(t, clientset, nodeExec)
+			t.Cleanup(func() {
+				if os.Getenv("SKIP_TEARDOWN") != "true" {
+					clearPersistentQueue(t, clientset, nodeExec)
+				}
+			})
+			deployChart(t, testKubeConfig, clientset, persistentQueueValuesTemplateFile)
+			deployTestLogToPod(t, clientset, config)
+		}
+		// wait for the log file to be read into the queue while HEC is not listening yet
+		time.Sleep(10 * time.Second)
+
+		faults := internal.StartFaults(t, internal.TimedFault{
+			Fault: internal.DeletePodsFault(clientset, internal.DefaultNamespace, internal.AgentLabelSelector, true),
+		})
+		faults.Wait(t)
+		internal.CheckPodsReady(t, clientset, internal.DefaultNamespace, internal.AgentLabelSelector, 3*time.Minute, 5*time.Second)
+
+		logsConsumer := internal.SetupHECLogsSink(t)
+		tracker := internal.TrackLogDelivery(t, logsConsumer)
+		report := internal.WaitForDelivery(t, tracker, internal.ExpectedLogStreams(testLogGenerator.Expected()), deliverySettle, 3*time.Minute)
+		internal.AssertNoLossUnderFaults(t, faults, report, 0.01)
 		if os.Getenv("SKIP_TEARDOWN") != "true" {
 			teardown(t)
 		}
@@ -128,8 +181,12 @@ func deployTestLogToPod(t *testing.T, clientset *kubernetes.Clientset, config *r
 		require.Failf(t, "no pods found for label %s", internal.AgentLabelSelector)
 	}
 	podName = pods.Items[0].Name
-	testLogFile, err := filepath.Abs(filepath.Join(testDir, testFile))
-	require.NoError(t, err)
+	var content strings.Builder
+	for _, record := range testLogGenerator.Expected() {
+		content.WriteString(record.Body + "\n")
+	}
+	testLogFile := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, os.WriteFile(testLogFile, []byte(content.String()), 0o600))
 	internal.CopyFileToPod(t, clientset, config, internal.DefaultNamespace, podName, internal.CollectorContainerName, testLogFile, remoteTestFile)
 }
 
@@ -138,3 +195,17 @@ func teardown(t *testing.T) {
 	require.True(t, setKubeConfig, "the environment variable KUBECONFIG must be set")
 	internal.ChartUninstall(t, testKubeConfig)
 }
+
+// clearPersistentQueue removes the agent queue files from the nodes. They outlive the chart, so
+// the records an earlier run left queued would otherwise be delivered again as duplicates.
+func clearPersistentQueue(t *testing.T, clientset *kubernetes.Clientset, nodeExec internal.NodeExecutor) {
+	// Use context.Background because t.Context() is already cancelled during t.Cleanup.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute) //nolint:usetesting
+	defer cancel()
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	for _, node := range nodes.Items {
+		_, err = nodeExec(ctx, node.Name, "rm", "-rf", persistentQueueAgentPath)
+		require.NoError(t, err, "failed to clear the persistent queue on node %s", node.Name)
+	}
+}
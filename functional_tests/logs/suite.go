@@ -0,0 +1,12 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package logs
+
+import "github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+
+var Requirements = internal.SuiteRequirements{
+	Name:             "logs",
+	Capabilities:     []internal.Capability{internal.CapabilityHostSinks, internal.CapabilityPrivilegedPods},
+	ClusterExclusive: true,
+}
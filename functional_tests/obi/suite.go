@@ -0,0 +1,12 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package obi
+
+import "github.com/signalfx/splunk-otel-collector-chart/functional_tests/internal"
+
+var Requirements = internal.SuiteRequirements{
+	Name:             "obi",
+	Capabilities:     []internal.Capability{internal.CapabilityHostSinks, internal.CapabilityPrivilegedPods, internal.CapabilityClusterAdmin},
+	ClusterExclusive: true,
+}
@@ -13,13 +13,7 @@
 //
 //	CSA agent → otlp receiver (agent) → routing/logs → logs/secureapp → otlp_http/secureapp → sink
 //
-// Environment variables:
-//
-//	TEARDOWN_BEFORE_SETUP   – run teardown before setup when set to "true"
-//	SKIP_SETUP              – skip chart/app installation when set to "true"
-//	SKIP_TEARDOWN           – skip cleanup when set to "true"
-//	SKIP_TESTS              – skip assertions when set to "true"
-//	KUBECONFIG              – path to kubeconfig file (required)
+// The suite honours the lifecycle environment variables of internal.Suite.
 package secureapp
 
 import (
@@ -47,7 +41,6 @@ const (
 	secureAppLogsPort = internal.SecureAppLogsReceiverPort // 4320
 	javaAppLabel      = "app=java-test"
 	javaContainerName = "java-test"
-	testDir           = "testdata"
 )
 
 // javaDeploymentPath is the java_test deployment manifest shared with the functional suite.
@@ -57,57 +50,30 @@ var javaDeploymentPath = filepath.Join("..", "functional", "testdata", "java", "
 
 var decoder = serializer.NewCodecFactory(scheme.Scheme).UniversalDeserializer()
 
-func Test_SecureApp_JavaAttackEvents(t *testing.T) {
-	kubeconfig, hasKubeconfig := os.LookupEnv("KUBECONFIG")
-	require.True(t, hasKubeconfig, "KUBECONFIG environment variable must be set")
-
-	if os.Getenv("TEARDOWN_BEFORE_SETUP") == "true" {
-		t.Log("Running teardown before setup")
-		internal.ChartUninstall(t, kubeconfig)
-	}
-
-	sink := internal.SetupOTLPLogsSinkOnPort(t, secureAppLogsPort, "/v3/event")
-	internal.SetupSignalFxAPIServer(t)
-
-	if os.Getenv("SKIP_SETUP") != "true" {
-		deployAll(t, kubeconfig)
-	}
+const eventsSink = "secureapp"
+
+var suite = internal.Suite{
+	SuiteRequirements: Requirements,
+	SignalFxAPI:       true,
+	Sinks:             []internal.SuiteSink{internal.OTLPLogsSuiteSink(eventsSink, secureAppLogsPort, "/v3/event")},
+	ValuesTemplate:    "secureapp_values.yaml.tmpl",
+	Replacements: map[string]any{
+		"IngestURL": internal.SinkURL(secureAppLogsPort),
+		"ApiURL":    internal.SinkURL(internal.SignalFxAPIPort),
+	},
+	Deploy: deployWorkload,
+}
 
-	t.Cleanup(func() {
-		if os.Getenv("SKIP_TEARDOWN") == "true" {
-			return
-		}
-		internal.ChartUninstall(t, kubeconfig)
+func Test_SecureApp_JavaAttackEvents(t *testing.T) {
+	suite.Run(t, func(t *testing.T, env *internal.SuiteEnv) {
+		triggerAttacks(t, env.KubeConfig)
+		assertSecureAppEvents(t, env.LogsSink(t, eventsSink))
 	})
-
-	if os.Getenv("SKIP_TESTS") == "true" {
-		t.Log("Skipping tests as SKIP_TESTS is set to true")
-		return
-	}
-
-	triggerAttacks(t, kubeconfig)
-
-	assertSecureAppEvents(t, sink)
 }
 
-// deployAll installs the Helm chart and the java_test workload.
-func deployAll(t *testing.T, kubeconfig string) {
-	hostEp := internal.HostEndpoint(t)
-	require.NotEmpty(t, hostEp, "host endpoint not found")
-
-	valuesFile, err := filepath.Abs(filepath.Join(testDir, "secureapp_values.yaml.tmpl"))
-	require.NoError(t, err)
-
-	replacements := map[string]any{
-		"IngestURL": internal.HostPortHTTP(hostEp, secureAppLogsPort),
-		"ApiURL":    internal.HostPortHTTP(hostEp, internal.SignalFxAPIPort),
-	}
-	internal.ChartInstallOrUpgrade(t, kubeconfig, valuesFile, replacements, 0, internal.GetDefaultChartOptions())
-
-	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	require.NoError(t, err)
-	clientset, err := kubernetes.NewForConfig(restConfig)
-	require.NoError(t, err)
+// deployWorkload deploys the java_test workload once the chart is installed.
+func deployWorkload(t *testing.T, env *internal.SuiteEnv) {
+	clientset := env.Clientset
 
 	// Wait for the OTel operator pod and its webhook endpoint to be ready before
 	// deploying the workload so the mutation webhook is active for the CSA image swap.
@@ -0,0 +1,108 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// splunkstandin serves a hermetic stand-in for Splunk: an HTTP Event Collector for the
+// collector to send to, and the search job API the splunk_integration tests query. Both
+// listen with a self-signed certificate, as the CI Splunk instance does.
+//
+// Usage (from test):
+//
+//	go run ./cmd/splunkstandin [-hec-addr :8088] [-api-addr :8089]
+//
+// The credentials default to the CI_SPLUNK_* environment variables of the tests.
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"flag"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	test "splunk-integration-tests"
+)
+
+func main() {
+	hecAddr := flag.String("hec-addr", ":8088", "HTTP Event Collector listen address")
+	apiAddr := flag.String("api-addr", ":8089", "management (search API) listen address")
+	hecToken := flag.String("hec-token", os.Getenv("CI_SPLUNK_HEC_TOKEN"), "HEC token to accept; empty accepts any")
+	username := flag.String("username", os.Getenv(test.UserEnvVar), "search API username; empty accepts any")
+	password := flag.String("password", os.Getenv(test.PasswordEnvVar), "search API password")
+	flag.Parse()
+
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	standIn := test.NewSplunkStandIn(test.SplunkStandInConfig{
+		HECToken: *hecToken,
+		Username: *username,
+		Password: *password,
+		Token:    os.Getenv(test.TokenEnvVar),
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	servers := []*http.Server{
+		{Addr: *hecAddr, Handler: standIn.HECHandler(), ReadHeaderTimeout: 10 * time.Second},
+		{Addr: *apiAddr, Handler: standIn.SearchHandler(), ReadHeaderTimeout: 10 * time.Second},
+	}
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		go func() {
+			log.Printf("listening on %s", server.Addr)
+			errs <- server.ListenAndServeTLS("", "")
+		}()
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case err = <-errs:
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		case <-ticker.C:
+			events, metrics := standIn.Events()
+			log.Printf("received %d events and %d metrics", events, metrics)
+		case <-ctx.Done():
+			for _, server := range servers {
+				_ = server.Close()
+			}
+			return
+		}
+	}
+}
+
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "splunk-standin"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -3,34 +3,16 @@
 
 package test
 
-import (
-	"os"
-)
-
 const (
 	HostEnvVar           = "CI_SPLUNK_HOST"
 	UserEnvVar           = "CI_SPLUNK_USERNAME"
 	PasswordEnvVar       = "CI_SPLUNK_PASSWORD" //nolint:gosec
 	ManagementPortEnvVar = "CI_SPLUNK_PORT"
+	// TokenEnvVar is an optional Splunk authentication token used instead of the username and password.
+	TokenEnvVar = "CI_SPLUNK_TOKEN" //nolint:gosec
+	// CAFileEnvVar is an optional PEM bundle to verify the Splunk certificate with.
+	CAFileEnvVar = "CI_SPLUNK_CA_FILE"
+	// InsecureSkipVerifyEnvVar overrides certificate verification, which is skipped by default
+	// when no CA file is given.
+	InsecureSkipVerifyEnvVar = "CI_SPLUNK_INSECURE_SKIP_VERIFY"
 )
-
-// GetConfigVariable returns the value of the environment variable with the given name.
-func GetConfigVariable(variableName string) string {
-	envVariableName := ""
-	switch variableName {
-	case "HOST":
-		envVariableName = HostEnvVar
-	case "USER":
-		envVariableName = UserEnvVar
-	case "PASSWORD":
-		envVariableName = PasswordEnvVar
-	case "MANAGEMENT_PORT":
-		envVariableName = ManagementPortEnvVar
-	}
-	value := os.Getenv(envVariableName)
-
-	if value != "" {
-		return value
-	}
-	panic(envVariableName + " environment variable is not set")
-}
@@ -0,0 +1,97 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// RoutingBackend finds where the data of a pod landed.
+type RoutingBackend interface {
+	Routes(ctx context.Context, signal Signal, namespace, pod string) ([]Route, error)
+}
+
+// SearchRoutingBackend finds routes with searches: the log events of the pod in every index,
+// and the data points of one of its metrics with mstats.
+type SearchRoutingBackend struct {
+	Client   *SearchClient
+	Earliest string
+	// Metric is counted to find the metrics routes. Defaults to k8s.pod.memory.usage, which
+	// the kubelet_stats receiver reports for every pod.
+	Metric string
+}
+
+// Routes implements RoutingBackend.
+func (b SearchRoutingBackend) Routes(ctx context.Context, signal Signal, namespace, pod string) ([]Route, error) {
+	if signal == SignalLogs {
+		query := fmt.Sprintf("| search index=* k8s.namespace.name::%s k8s.pod.name::%s", splValue(namespace), splValue(pod))
+		events, err := b.Client.Search(ctx, SearchRequest{Query: query, Earliest: b.Earliest})
+		if err != nil {
+			return nil, err
+		}
+		counts := map[Route]int{}
+		for _, event := range events {
+			counts[Route{Index: event.Index, Sourcetype: event.Sourcetype, CustomField: event.Field("customField")}]++
+		}
+		return sortedRoutes(counts), nil
+	}
+
+	metric := b.Metric
+	if metric == "" {
+		metric = "k8s.pod.memory.usage"
+	}
+	series, err := b.Client.MetricStats(ctx, MetricStatsRequest{
+		Index:       "*",
+		MetricName:  metric,
+		Aggregation: "count",
+		Filters:     map[string]string{"k8s.namespace.name": namespace, "k8s.pod.name": pod},
+		By:          []string{"index", "sourcetype"},
+		Earliest:    b.Earliest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := map[Route]int{}
+	for _, s := range series {
+		for _, point := range s.Points {
+			counts[Route{Index: s.Dimensions["index"], Sourcetype: s.Dimensions["sourcetype"]}] += int(point.Value)
+		}
+	}
+	return sortedRoutes(counts), nil
+}
+
+// StandInRoutingBackend finds routes in what the HEC of an in-process SplunkStandIn received.
+type StandInRoutingBackend struct {
+	StandIn *SplunkStandIn
+}
+
+// Routes implements RoutingBackend.
+func (b StandInRoutingBackend) Routes(_ context.Context, signal Signal, namespace, pod string) ([]Route, error) {
+	b.StandIn.mu.Lock()
+	defer b.StandIn.mu.Unlock()
+	counts := map[Route]int{}
+	for _, e := range b.StandIn.entries {
+		if e.isMetric() != (signal == SignalMetrics) || fmt.Sprint(e.Fields["k8s.namespace.name"]) != namespace || fmt.Sprint(e.Fields["k8s.pod.name"]) != pod {
+			continue
+		}
+		route := Route{Index: e.Index, Sourcetype: e.Sourcetype}
+		if customField, ok := e.Fields["customField"]; ok && signal == SignalLogs {
+			route.CustomField = fmt.Sprint(customField)
+		}
+		counts[route]++
+	}
+	return sortedRoutes(counts), nil
+}
+
+func sortedRoutes(counts map[Route]int) []Route {
+	routes := make([]Route, 0, len(counts))
+	for route, count := range counts {
+		route.Count = count
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].String() < routes[j].String() })
+	return routes
+}
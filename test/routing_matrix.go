@@ -0,0 +1,456 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The annotations the chart routes Splunk platform data with.
+const (
+	IndexAnnotation             = "splunk.com/index"
+	MetricsIndexAnnotation      = "splunk.com/metricsIndex"
+	SourcetypeAnnotation        = "splunk.com/sourcetype"
+	MetricsSourcetypeAnnotation = "splunk.com/metricsSourcetype"
+	ExcludeAnnotation           = "splunk.com/exclude"
+	// CustomFieldAnnotation is extracted as the customField attribute by the extraAttributes
+	// of ci_scripts/sck_otel_values.yaml.
+	CustomFieldAnnotation = "splunk.com/customField"
+)
+
+// Placement is where a routing case sets an annotation.
+type Placement string
+
+const (
+	OnNamespace Placement = "namespace"
+	OnPod       Placement = "pod"
+	// OnBoth sets the same value on the namespace and the pod.
+	OnBoth Placement = "both"
+	// Conflicting sets different values on the namespace and the pod.
+	Conflicting Placement = "conflicting"
+)
+
+// Signal is a kind of data the routing of a case is checked for.
+type Signal string
+
+const (
+	SignalLogs    Signal = "logs"
+	SignalMetrics Signal = "metrics"
+)
+
+// AnnotationValues are the values a routing matrix sets an annotation to.
+type AnnotationValues struct {
+	// Namespace is set on the namespace, for the OnNamespace and Conflicting placements.
+	Namespace string
+	// Pod is set on the pod, and on the namespace too for the OnBoth placement.
+	Pod string
+	// Conflicting is set on the pod for the Conflicting placement. Defaults to Pod.
+	Conflicting string
+}
+
+// DefaultRoutingValues only route to the indexes created by ci_scripts/k8s-splunk.yml. A
+// conflicting exclusion excludes the namespace but not the pod.
+var DefaultRoutingValues = map[string]AnnotationValues{
+	IndexAnnotation:             {Namespace: "ns-anno", Pod: "pod-anno"},
+	MetricsIndexAnnotation:      {Namespace: "test_metrics", Pod: "test_metrics_annotation"},
+	SourcetypeAnnotation:        {Namespace: "ns-sourcetype", Pod: "pod-sourcetype"},
+	MetricsSourcetypeAnnotation: {Namespace: "ns-metrics-sourcetype", Pod: "pod-metrics-sourcetype"},
+	ExcludeAnnotation:           {Namespace: "true", Pod: "true", Conflicting: "false"},
+	CustomFieldAnnotation:       {Namespace: "ns-value", Pod: "pod-value"},
+}
+
+// RoutingDefaults are the routes of data without annotations, as configured by
+// ci_scripts/sck_otel_values.yaml.
+type RoutingDefaults struct {
+	EventsIndex       string
+	MetricsIndex      string
+	MetricsSourcetype string
+}
+
+// RoutingCase is a namespace and a pod annotated as Annotations place them.
+type RoutingCase struct {
+	// Name names the namespace and the pod of the case, prefixed by the matrix name.
+	Name        string
+	Annotations map[string]Placement
+	// Signals are checked for the case. Defaults to logs and metrics.
+	Signals []Signal
+}
+
+// controlCaseName names the case Apply adds to a matrix with excluded cases.
+const controlCaseName = "control"
+
+// RoutingMatrix declares routing cases; Apply creates them in a cluster, Expect computes where
+// the chart sends their data and RoutingOutcome.Check compares that with what a
+// RoutingBackend found.
+type RoutingMatrix struct {
+	// Name prefixes the namespaces and pods of the cases. Defaults to "routing".
+	Name  string
+	Cases []RoutingCase
+	// Values defaults to DefaultRoutingValues.
+	Values map[string]AnnotationValues
+	// Defaults defaults to the ci_events and ci_metrics indexes and the httpevent sourcetype.
+	Defaults RoutingDefaults
+	// Messages is the number of log lines each pod writes. Defaults to 20.
+	Messages int
+	// Image runs the pods; it needs sh, seq and sleep. Defaults to busybox:1.38.0.
+	Image string
+}
+
+// RoutingCases declares one case per annotation and placement, named after both, e.g.
+// "metricsindex-on-pod".
+func RoutingCases(annotations []string, placements []Placement, signals ...Signal) []RoutingCase {
+	var cases []RoutingCase
+	for _, annotation := range annotations {
+		for _, placement := range placements {
+			name := strings.ToLower(strings.TrimPrefix(annotation, "splunk.com/")) + "-on-" + string(placement)
+			cases = append(cases, RoutingCase{
+				Name:        name,
+				Annotations: map[string]Placement{annotation: placement},
+				Signals:     signals,
+			})
+		}
+	}
+	return cases
+}
+
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func (m RoutingMatrix) withDefaults() RoutingMatrix {
+	if m.Name == "" {
+		m.Name = "routing"
+	}
+	if m.Values == nil {
+		m.Values = DefaultRoutingValues
+	}
+	if m.Defaults.EventsIndex == "" {
+		m.Defaults.EventsIndex = "ci_events"
+	}
+	if m.Defaults.MetricsIndex == "" {
+		m.Defaults.MetricsIndex = "ci_metrics"
+	}
+	if m.Defaults.MetricsSourcetype == "" {
+		m.Defaults.MetricsSourcetype = "httpevent"
+	}
+	if m.Messages <= 0 {
+		m.Messages = 20
+	}
+	if m.Image == "" {
+		m.Image = "busybox:1.38.0"
+	}
+	return m
+}
+
+// Validate checks the cases have distinct names usable as namespace names, and only place
+// annotations the matrix has values for.
+func (m RoutingMatrix) Validate() error {
+	m = m.withDefaults()
+	var errs []error
+	seen := map[string]bool{}
+	for _, c := range m.applied() {
+		name := m.PodName(c)
+		if !dnsLabel.MatchString(name) || len(name) > 63 {
+			errs = append(errs, fmt.Errorf("case %q: %q is not a valid namespace name", c.Name, name))
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("case %q is declared twice", c.Name))
+		}
+		seen[name] = true
+		for annotation, placement := range c.Annotations {
+			if _, ok := m.Values[annotation]; !ok {
+				errs = append(errs, fmt.Errorf("case %q: no values for annotation %s", c.Name, annotation))
+			}
+			switch placement {
+			case OnNamespace, OnPod, OnBoth, Conflicting:
+			default:
+				errs = append(errs, fmt.Errorf("case %q: unknown placement %q of %s", c.Name, placement, annotation))
+			}
+		}
+		for _, signal := range c.Signals {
+			if signal != SignalLogs && signal != SignalMetrics {
+				errs = append(errs, fmt.Errorf("case %q: unknown signal %q", c.Name, signal))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PodName is the name of the namespace, the pod and the container of a case.
+func (m RoutingMatrix) PodName(c RoutingCase) string {
+	return m.withDefaults().Name + "-" + c.Name
+}
+
+// ControlCase returns the case without annotations that Apply adds when a case excludes logs,
+// and whether there is one. Its logs are always routed: excluded logs are only known to be
+// dropped once the control logs, written at the same time, were received.
+func (m RoutingMatrix) ControlCase() (RoutingCase, bool) {
+	for _, c := range m.Cases {
+		for _, signal := range m.CaseSignals(c) {
+			if m.Expect(c, signal).Excluded {
+				return RoutingCase{Name: controlCaseName, Signals: []Signal{SignalLogs}}, true
+			}
+		}
+	}
+	return RoutingCase{}, false
+}
+
+// applied returns the cases Apply creates: Cases and the control case.
+func (m RoutingMatrix) applied() []RoutingCase {
+	if control, ok := m.ControlCase(); ok {
+		return append(slices.Clone(m.Cases), control)
+	}
+	return m.Cases
+}
+
+// CaseSignals returns the signals checked for a case.
+func (m RoutingMatrix) CaseSignals(c RoutingCase) []Signal {
+	if len(c.Signals) == 0 {
+		return []Signal{SignalLogs, SignalMetrics}
+	}
+	return c.Signals
+}
+
+// annotations returns the annotations of the namespace and of the pod of a case.
+func (m RoutingMatrix) annotations(c RoutingCase) (namespace, pod map[string]string) {
+	namespace, pod = map[string]string{}, map[string]string{}
+	for annotation, placement := range c.Annotations {
+		values := m.Values[annotation]
+		switch placement {
+		case OnNamespace:
+			namespace[annotation] = values.Namespace
+		case OnPod:
+			pod[annotation] = values.Pod
+		case OnBoth:
+			namespace[annotation] = values.Pod
+			pod[annotation] = values.Pod
+		case Conflicting:
+			namespace[annotation] = values.Namespace
+			pod[annotation] = values.Pod
+			if values.Conflicting != "" {
+				pod[annotation] = values.Conflicting
+			}
+		}
+	}
+	return namespace, pod
+}
+
+// RoutingOutcome is where the chart sends a signal of the pod of a case.
+type RoutingOutcome struct {
+	Signal Signal
+	// Excluded logs are dropped by the agent.
+	Excluded   bool
+	Index      string
+	Sourcetype string
+	// CustomField is the customField of log events, empty when the pod does not set it.
+	CustomField string
+	// Count is the exact number of log events expected; metrics only need one data point.
+	Count int
+}
+
+// Expect computes the outcome of a case from the precedence rules of the chart's
+// k8s_attributes processors: a pod annotation wins over the same namespace annotation. Logs
+// take their index from splunk.com/index and their sourcetype from the pod's
+// splunk.com/sourcetype only; metrics use splunk.com/metricsIndex, and
+// splunk.com/metricsSourcetype over splunk.com/sourcetype. Only logs can be excluded.
+func (m RoutingMatrix) Expect(c RoutingCase, signal Signal) RoutingOutcome {
+	m = m.withDefaults()
+	namespace, pod := m.annotations(c)
+	outcome := RoutingOutcome{Signal: signal}
+	switch signal {
+	case SignalLogs:
+		outcome.Excluded = firstNonEmpty(pod[ExcludeAnnotation], namespace[ExcludeAnnotation]) == "true"
+		outcome.Index = firstNonEmpty(pod[IndexAnnotation], namespace[IndexAnnotation], m.Defaults.EventsIndex)
+		outcome.Sourcetype = firstNonEmpty(pod[SourcetypeAnnotation], "kube:container:"+m.PodName(c))
+		outcome.CustomField = pod[CustomFieldAnnotation]
+		if !outcome.Excluded {
+			outcome.Count = m.Messages
+		}
+	case SignalMetrics:
+		outcome.Index = firstNonEmpty(pod[MetricsIndexAnnotation], namespace[MetricsIndexAnnotation], m.Defaults.MetricsIndex)
+		outcome.Sourcetype = firstNonEmpty(pod[MetricsSourcetypeAnnotation], pod[SourcetypeAnnotation],
+			namespace[MetricsSourcetypeAnnotation], namespace[SourcetypeAnnotation], m.Defaults.MetricsSourcetype)
+	}
+	return outcome
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Route is where some data of a pod landed.
+type Route struct {
+	Index       string
+	Sourcetype  string
+	CustomField string
+	// Count is the number of log events or metric data points.
+	Count int
+}
+
+func (r Route) String() string {
+	return fmt.Sprintf("index=%s sourcetype=%s customField=%q count=%d", r.Index, r.Sourcetype, r.CustomField, r.Count)
+}
+
+// Check returns an error describing how routes differ from the outcome: excluded logs must
+// have no route, other data exactly the expected one. No route only shows logs were excluded
+// once the control case's logs arrived and no route appeared for a while after.
+func (o RoutingOutcome) Check(routes []Route) error {
+	if o.Excluded {
+		if len(routes) > 0 {
+			return fmt.Errorf("excluded %s were received: %v", o.Signal, routes)
+		}
+		return nil
+	}
+	if len(routes) != 1 {
+		return fmt.Errorf("expected %s in index=%s sourcetype=%s, got %d routes: %v", o.Signal, o.Index, o.Sourcetype, len(routes), routes)
+	}
+	route := routes[0]
+	if route.Index != o.Index || route.Sourcetype != o.Sourcetype || route.CustomField != o.CustomField {
+		return fmt.Errorf("expected %s in index=%s sourcetype=%s customField=%q, got %v", o.Signal, o.Index, o.Sourcetype, o.CustomField, route)
+	}
+	if o.Signal == SignalLogs && route.Count != o.Count {
+		return fmt.Errorf("expected %d log events, got %d", o.Count, route.Count)
+	}
+	if route.Count == 0 {
+		return fmt.Errorf("expected %s, got none", o.Signal)
+	}
+	return nil
+}
+
+// Apply creates the annotated namespace and pod of every case, and of the control case.
+// Existing namespaces are updated and existing pods recreated, so a matrix can be applied again
+// after a failed run.
+func (m RoutingMatrix) Apply(ctx context.Context, client kubernetes.Interface) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	m = m.withDefaults()
+	for _, c := range m.applied() {
+		name := m.PodName(c)
+		nsAnnotations, podAnnotations := m.annotations(c)
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: nsAnnotations}}
+		_, err := client.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			_, err = client.CoreV1().Namespaces().Update(ctx, namespace, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("case %q: failed to create namespace %s: %w", c.Name, name, err)
+		}
+		if err = recreatePod(ctx, client, m.pod(name, podAnnotations)); err != nil {
+			return fmt.Errorf("case %q: failed to create pod %s: %w", c.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// recreatePod creates the pod, replacing one left by an earlier apply: pods cannot be
+// updated in place, and the new one logs its messages again for the new run.
+func recreatePod(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) error {
+	pods := client.CoreV1().Pods(pod.Namespace)
+	gracePeriod := int64(0)
+	for {
+		_, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+		if !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		err = pods.Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		// The old pod is terminating until the kubelet confirms it stopped.
+		if _, err = pods.Get(ctx, pod.Name, metav1.GetOptions{}); k8serrors.IsNotFound(err) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// pod writes Messages log lines and keeps running so that its metrics are collected.
+func (m RoutingMatrix) pod(name string, annotations map[string]string) *corev1.Pod {
+	gracePeriod := int64(1)
+	script := fmt.Sprintf(`for i in $(seq 1 %d); do echo "%s routing line $i"; done; sleep 86400`, m.Messages, name)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   name,
+			Labels:      map[string]string{"app": name},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			TerminationGracePeriodSeconds: &gracePeriod,
+			Containers: []corev1.Container{{
+				Name:    name,
+				Image:   m.Image,
+				Command: []string{"sh", "-c", script},
+			}},
+		},
+	}
+}
+
+// WaitRunning waits until the pod of every case, and of the control case, runs.
+func (m RoutingMatrix) WaitRunning(ctx context.Context, client kubernetes.Interface, interval time.Duration) error {
+	pending := map[string]bool{}
+	for _, c := range m.applied() {
+		pending[m.PodName(c)] = true
+	}
+	for {
+		for name := range pending {
+			pod, err := client.CoreV1().Pods(name).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get pod %s: %w", name, err)
+			}
+			switch pod.Status.Phase {
+			case corev1.PodRunning:
+				delete(pending, name)
+			case corev1.PodFailed, corev1.PodSucceeded:
+				return fmt.Errorf("pod %s is %s", name, pod.Status.Phase)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			names := make([]string, 0, len(pending))
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("pods %s are not running: %w", strings.Join(names, ", "), ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Delete deletes the namespaces of the cases and of the control case, and so their pods.
+func (m RoutingMatrix) Delete(ctx context.Context, client kubernetes.Interface) error {
+	var errs []error
+	for _, c := range m.applied() {
+		err := client.CoreV1().Namespaces().Delete(ctx, m.PodName(c), metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
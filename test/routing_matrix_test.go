@@ -0,0 +1,264 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRoutingMatrixExpect(t *testing.T) {
+	t.Parallel()
+
+	matrix := RoutingMatrix{Name: "rt"}
+	testCases := []struct {
+		name        string
+		annotations map[string]Placement
+		logs        RoutingOutcome
+		metrics     RoutingOutcome
+	}{
+		{
+			name:    "no annotations",
+			logs:    RoutingOutcome{Index: "ci_events", Sourcetype: "kube:container:rt-no-annotations", Count: 20},
+			metrics: RoutingOutcome{Index: "ci_metrics", Sourcetype: "httpevent"},
+		},
+		{
+			name:        "namespace index",
+			annotations: map[string]Placement{IndexAnnotation: OnNamespace, MetricsIndexAnnotation: OnNamespace},
+			logs:        RoutingOutcome{Index: "ns-anno", Sourcetype: "kube:container:rt-namespace-index", Count: 20},
+			metrics:     RoutingOutcome{Index: "test_metrics", Sourcetype: "httpevent"},
+		},
+		{
+			name:        "conflicting index",
+			annotations: map[string]Placement{IndexAnnotation: Conflicting, MetricsIndexAnnotation: Conflicting},
+			logs:        RoutingOutcome{Index: "pod-anno", Sourcetype: "kube:container:rt-conflicting-index", Count: 20},
+			metrics:     RoutingOutcome{Index: "test_metrics_annotation", Sourcetype: "httpevent"},
+		},
+		{
+			name:        "namespace sourcetype",
+			annotations: map[string]Placement{SourcetypeAnnotation: OnNamespace},
+			logs:        RoutingOutcome{Index: "ci_events", Sourcetype: "kube:container:rt-namespace-sourcetype", Count: 20},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "ns-sourcetype"},
+		},
+		{
+			name:        "pod sourcetype and namespace metrics sourcetype",
+			annotations: map[string]Placement{SourcetypeAnnotation: OnPod, MetricsSourcetypeAnnotation: OnNamespace},
+			logs:        RoutingOutcome{Index: "ci_events", Sourcetype: "pod-sourcetype", Count: 20},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "pod-sourcetype"},
+		},
+		{
+			name:        "conflicting metrics sourcetype",
+			annotations: map[string]Placement{SourcetypeAnnotation: Conflicting, MetricsSourcetypeAnnotation: Conflicting},
+			logs:        RoutingOutcome{Index: "ci_events", Sourcetype: "pod-sourcetype", Count: 20},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "pod-metrics-sourcetype"},
+		},
+		{
+			name:        "namespace exclude",
+			annotations: map[string]Placement{ExcludeAnnotation: OnNamespace, IndexAnnotation: OnPod},
+			logs:        RoutingOutcome{Excluded: true, Index: "pod-anno", Sourcetype: "kube:container:rt-namespace-exclude"},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "httpevent"},
+		},
+		{
+			name:        "conflicting exclude",
+			annotations: map[string]Placement{ExcludeAnnotation: Conflicting},
+			logs:        RoutingOutcome{Index: "ci_events", Sourcetype: "kube:container:rt-conflicting-exclude", Count: 20},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "httpevent"},
+		},
+		{
+			name:        "namespace custom field",
+			annotations: map[string]Placement{CustomFieldAnnotation: OnNamespace},
+			logs:        RoutingOutcome{Index: "ci_events", Sourcetype: "kube:container:rt-namespace-custom-field", Count: 20},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "httpevent"},
+		},
+		{
+			name:        "both custom field",
+			annotations: map[string]Placement{CustomFieldAnnotation: OnBoth},
+			logs:        RoutingOutcome{Index: "ci_events", Sourcetype: "kube:container:rt-both-custom-field", CustomField: "pod-value", Count: 20},
+			metrics:     RoutingOutcome{Index: "ci_metrics", Sourcetype: "httpevent"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			c := RoutingCase{Name: strings.ReplaceAll(testCase.name, " ", "-"), Annotations: testCase.annotations}
+			testCase.logs.Signal = SignalLogs
+			testCase.metrics.Signal = SignalMetrics
+			require.Equal(t, testCase.logs, matrix.Expect(c, SignalLogs))
+			require.Equal(t, testCase.metrics, matrix.Expect(c, SignalMetrics))
+		})
+	}
+}
+
+func TestRoutingOutcomeCheck(t *testing.T) {
+	t.Parallel()
+
+	logs := RoutingOutcome{Signal: SignalLogs, Index: "pod-anno", Sourcetype: "pod-sourcetype", Count: 20}
+	require.NoError(t, logs.Check([]Route{{Index: "pod-anno", Sourcetype: "pod-sourcetype", Count: 20}}))
+	require.EqualError(t, logs.Check([]Route{{Index: "pod-anno", Sourcetype: "pod-sourcetype", Count: 19}}), "expected 20 log events, got 19")
+	require.ErrorContains(t, logs.Check([]Route{{Index: "ns-anno", Sourcetype: "pod-sourcetype", Count: 20}}), "expected logs in index=pod-anno")
+	require.ErrorContains(t, logs.Check(nil), "got 0 routes")
+
+	excluded := RoutingOutcome{Signal: SignalLogs, Excluded: true, Index: "ci_events"}
+	require.NoError(t, excluded.Check(nil))
+	require.ErrorContains(t, excluded.Check([]Route{{Index: "ci_events", Count: 1}}), "excluded logs were received")
+
+	metrics := RoutingOutcome{Signal: SignalMetrics, Index: "ci_metrics", Sourcetype: "httpevent"}
+	require.NoError(t, metrics.Check([]Route{{Index: "ci_metrics", Sourcetype: "httpevent", Count: 7}}))
+}
+
+func TestRoutingMatrixApply(t *testing.T) {
+	t.Parallel()
+
+	matrix := RoutingMatrix{
+		Cases:    RoutingCases([]string{IndexAnnotation, ExcludeAnnotation}, []Placement{OnPod, Conflicting}),
+		Messages: 5,
+	}
+	client := fake.NewClientset()
+	require.NoError(t, matrix.Apply(t.Context(), client))
+
+	ns, err := client.CoreV1().Namespaces().Get(t.Context(), "routing-exclude-on-conflicting", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{ExcludeAnnotation: "true"}, ns.Annotations)
+	pod, err := client.CoreV1().Pods("routing-exclude-on-conflicting").Get(t.Context(), "routing-exclude-on-conflicting", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{ExcludeAnnotation: "false"}, pod.Annotations)
+	require.Equal(t, "routing-exclude-on-conflicting", pod.Spec.Containers[0].Name)
+	require.Contains(t, pod.Spec.Containers[0].Command[2], "seq 1 5")
+
+	ns, err = client.CoreV1().Namespaces().Get(t.Context(), "routing-index-on-pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, ns.Annotations)
+
+	// The excluded cases come with a control case whose logs are routed by default.
+	control, ok := matrix.ControlCase()
+	require.True(t, ok)
+	require.Equal(t, RoutingOutcome{Signal: SignalLogs, Index: "ci_events", Sourcetype: "kube:container:routing-control", Count: 5},
+		matrix.Expect(control, SignalLogs))
+	pod, err = client.CoreV1().Pods("routing-control").Get(t.Context(), "routing-control", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, pod.Annotations)
+
+	// Applying again after a failed run replaces the pods.
+	matrix.Messages = 7
+	require.NoError(t, matrix.Apply(t.Context(), client))
+	pod, err = client.CoreV1().Pods("routing-index-on-pod").Get(t.Context(), "routing-index-on-pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, pod.Spec.Containers[0].Command[2], "seq 1 7")
+	require.Equal(t, "busybox:1.38.0", pod.Spec.Containers[0].Image)
+
+	require.NoError(t, matrix.Delete(t.Context(), client))
+	namespaces, err := client.CoreV1().Namespaces().List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, namespaces.Items)
+
+	invalid := RoutingMatrix{Cases: []RoutingCase{
+		{Name: "Upper_Case"},
+		{Name: "twice"},
+		{Name: "twice", Annotations: map[string]Placement{"splunk.com/unknown": OnPod, IndexAnnotation: "everywhere"}},
+		{Name: "control", Annotations: map[string]Placement{ExcludeAnnotation: OnPod}},
+	}}
+	err = invalid.Apply(t.Context(), client)
+	require.ErrorContains(t, err, `"routing-Upper_Case" is not a valid namespace name`)
+	require.ErrorContains(t, err, `case "twice" is declared twice`)
+	require.ErrorContains(t, err, "no values for annotation splunk.com/unknown")
+	require.ErrorContains(t, err, `unknown placement "everywhere"`)
+	require.ErrorContains(t, err, `case "control" is declared twice`)
+}
+
+func TestRoutingMatrixWaitRunning(t *testing.T) {
+	t.Parallel()
+
+	matrix := RoutingMatrix{Cases: []RoutingCase{{Name: "a"}}}
+	_, ok := matrix.ControlCase()
+	require.False(t, ok, "a matrix without excluded cases needs no control case")
+	client := fake.NewClientset()
+	require.NoError(t, matrix.Apply(t.Context(), client))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pod, err := client.CoreV1().Pods("routing-a").Get(t.Context(), "routing-a", metav1.GetOptions{})
+		if err != nil {
+			return
+		}
+		pod.Status.Phase = "Running"
+		_, _ = client.CoreV1().Pods("routing-a").UpdateStatus(t.Context(), pod, metav1.UpdateOptions{})
+	}()
+	require.NoError(t, matrix.WaitRunning(t.Context(), client, 5*time.Millisecond))
+}
+
+func TestRoutingBackends(t *testing.T) {
+	t.Parallel()
+
+	matrix := RoutingMatrix{Cases: []RoutingCase{
+		{Name: "pod-index", Annotations: map[string]Placement{IndexAnnotation: OnPod, CustomFieldAnnotation: OnPod, MetricsSourcetypeAnnotation: OnNamespace}},
+		{Name: "excluded", Annotations: map[string]Placement{ExcludeAnnotation: OnNamespace}},
+	}, Messages: 3}
+
+	// What the agent sends for the cases: the log lines of the pods not excluded and a metric
+	// for each pod.
+	now := time.Now().Unix()
+	var payload strings.Builder
+	for _, c := range matrix.Cases {
+		name := matrix.PodName(c)
+		for signal, count := range map[Signal]int{SignalLogs: matrix.Messages, SignalMetrics: 2} {
+			outcome := matrix.Expect(c, signal)
+			if outcome.Excluded {
+				continue
+			}
+			for i := range count {
+				fields := fmt.Sprintf(`"k8s.namespace.name":%q,"k8s.pod.name":%q`, name, name)
+				if outcome.CustomField != "" {
+					fields += fmt.Sprintf(`,"customField":%q`, outcome.CustomField)
+				}
+				event := fmt.Sprintf(`"%s routing line %d"`, name, i)
+				if signal == SignalMetrics {
+					event = `"metric"`
+					fields += `,"metric_name:k8s.pod.memory.usage":1024`
+				}
+				fmt.Fprintf(&payload, `{"time":%d,"index":%q,"sourcetype":%q,"event":%s,"fields":{%s}}`+"\n", now, outcome.Index, outcome.Sourcetype, event, fields)
+			}
+		}
+	}
+
+	standIn := NewSplunkStandIn(SplunkStandInConfig{})
+	hec := httptest.NewServer(standIn.HECHandler())
+	t.Cleanup(hec.Close)
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, hec.URL+"/services/collector", strings.NewReader(payload.String()))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	search := httptest.NewTLSServer(standIn.SearchHandler())
+	t.Cleanup(search.Close)
+	client, err := NewSearchClient(SearchClientConfig{BaseURL: search.URL, Username: "admin", InsecureSkipVerify: true})
+	require.NoError(t, err)
+
+	backends := map[string]RoutingBackend{
+		"search":   SearchRoutingBackend{Client: client, Earliest: "-1h@h"},
+		"stand-in": StandInRoutingBackend{StandIn: standIn},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, c := range matrix.Cases {
+				for _, signal := range matrix.CaseSignals(c) {
+					routes, err := backend.Routes(t.Context(), signal, matrix.PodName(c), matrix.PodName(c))
+					require.NoError(t, err)
+					require.NoError(t, matrix.Expect(c, signal).Check(routes), "case %s", c.Name)
+				}
+			}
+			routes, err := backend.Routes(t.Context(), SignalMetrics, "routing-pod-index", "routing-pod-index")
+			require.NoError(t, err)
+			require.Equal(t, []Route{{Index: "ci_metrics", Sourcetype: "ns-metrics-sourcetype", Count: 2}}, routes)
+		})
+	}
+}
@@ -7,8 +7,10 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,6 +27,10 @@ const (
 	METRIC_SEARCH_QUERY_STRING = "| mpreview "
 	splunkIngestionTimeout     = 90 * time.Second
 	splunkSearchInterval       = 5 * time.Second
+	splunkSearchTimeout        = 30 * time.Second
+	// routingSettleWindow is how long excluded logs must stay missing after the control logs
+	// of the routing matrix arrived.
+	routingSettleWindow = 30 * time.Second
 )
 
 func Test_Functions(t *testing.T) {
@@ -32,6 +38,86 @@ func Test_Functions(t *testing.T) {
 	t.Run("custom metadata fields annotations", testVerifyCustomMetadataFieldsAnnotations)
 	t.Run("metric namespace annotations", testVerifyMetricNamespaceAnnotations)
 	t.Run("metric pod annotations", testVerifyMetricPodAnnotations)
+	t.Run("annotation routing matrix", testAnnotationRoutingMatrix)
+}
+
+// routingMatrix places every routing annotation on the namespace, the pod, both or both with
+// conflicting values, each case in its own namespace.
+var routingMatrix = RoutingMatrix{
+	Cases: append(RoutingCases(
+		[]string{IndexAnnotation, MetricsIndexAnnotation, SourcetypeAnnotation, MetricsSourcetypeAnnotation, ExcludeAnnotation, CustomFieldAnnotation},
+		[]Placement{OnNamespace, OnPod, OnBoth, Conflicting},
+	),
+		RoutingCase{Name: "metrics-sourcetype-over-sourcetype", Annotations: map[string]Placement{SourcetypeAnnotation: OnPod, MetricsSourcetypeAnnotation: OnNamespace}},
+		RoutingCase{Name: "exclude-with-routing", Annotations: map[string]Placement{ExcludeAnnotation: OnNamespace, IndexAnnotation: OnPod, MetricsIndexAnnotation: OnPod}},
+	),
+}
+
+func testAnnotationRoutingMatrix(t *testing.T) {
+	client := createK8sClient(t)
+	startTime := fmt.Sprintf("%d", time.Now().Unix())
+	require.NoError(t, routingMatrix.Apply(t.Context(), client))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		assert.NoError(t, routingMatrix.Delete(ctx, client))
+	})
+	ctx, cancel := context.WithTimeout(t.Context(), 3*time.Minute)
+	defer cancel()
+	require.NoError(t, routingMatrix.WaitRunning(ctx, client, 2*time.Second))
+
+	searchClient, err := splunkSearchClient()
+	require.NoError(t, err, "failed to configure the Splunk search client")
+	backend := SearchRoutingBackend{Client: searchClient, Earliest: startTime}
+	if control, ok := routingMatrix.ControlCase(); ok {
+		// The excluded cases are checked once logs written at the same time arrived, which
+		// also shows the search finds the logs of the matrix pods at all.
+		name := routingMatrix.PodName(control)
+		outcome := routingMatrix.Expect(control, SignalLogs)
+		waitForSplunk(t, fmt.Sprintf("expected control logs of %s routed as %+v", name, outcome), func(ctx context.Context) ([]Route, bool, error) {
+			ctx, cancel := context.WithTimeout(ctx, splunkSearchTimeout)
+			defer cancel()
+			routes, err := backend.Routes(ctx, SignalLogs, name, name)
+			return routes, err == nil && outcome.Check(routes) == nil, err
+		})
+	}
+	for _, c := range routingMatrix.Cases {
+		name := routingMatrix.PodName(c)
+		for _, signal := range routingMatrix.CaseSignals(c) {
+			outcome := routingMatrix.Expect(c, signal)
+			t.Run(c.Name+"/"+string(signal), func(t *testing.T) {
+				t.Parallel()
+				if outcome.Excluded {
+					requireNoRoutesWhileSettling(t, backend, outcome, name)
+					return
+				}
+				waitForSplunk(t, fmt.Sprintf("expected %s of %s routed as %+v", signal, name, outcome), func(ctx context.Context) ([]Route, bool, error) {
+					ctx, cancel := context.WithTimeout(ctx, splunkSearchTimeout)
+					defer cancel()
+					routes, err := backend.Routes(ctx, signal, name, name)
+					return routes, err == nil && outcome.Check(routes) == nil, err
+				})
+			})
+		}
+	}
+}
+
+// requireNoRoutesWhileSettling searches the excluded logs of a pod until routingSettleWindow
+// passed, failing as soon as some arrive.
+func requireNoRoutesWhileSettling(t *testing.T, backend RoutingBackend, outcome RoutingOutcome, name string) {
+	t.Helper()
+	deadline := time.Now().Add(routingSettleWindow)
+	for {
+		ctx, cancel := context.WithTimeout(t.Context(), splunkSearchTimeout)
+		routes, err := backend.Routes(ctx, outcome.Signal, name, name)
+		cancel()
+		require.NoError(t, err)
+		require.NoError(t, outcome.Check(routes))
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(splunkSearchInterval)
+	}
 }
 
 func testVerifyLogsIngestionUsingAnnotations(t *testing.T) {
@@ -90,7 +176,8 @@ func testVerifyMetricIndexAndSourcetypeAnnotations(t *testing.T) {
 		sourcetype := "sourcetype-anno"
 		searchQuery := METRIC_SEARCH_QUERY_STRING + "index=" + index + " filter=\"sourcetype=" + sourcetype + "\""
 		startTime := "-1h@h"
-		events := CheckEventsFromSplunk(searchQuery, startTime)
+		events, err := searchSplunk(t.Context(), t, searchQuery, startTime)
+		require.NoError(t, err)
 		fmt.Println(" =========>  Events received: ", len(events))
 		assert.Greater(t, len(events), 1)
 	})
@@ -142,12 +229,7 @@ func testVerifyMetricNamespaceAnnotations(t *testing.T) {
 				index = podAnnotationIndex
 				sourcetype = podAnnotationSourcetype
 			}
-			searchQuery := METRIC_SEARCH_QUERY_STRING + "index=" + index + " filter=\"sourcetype=" + sourcetype + "\" | search \"k8s.namespace.name\"=" + namespace + " \"k8s.pod.name\"=" + podName
-			fmt.Println("Search Query: ", searchQuery)
-			startTime := fmt.Sprintf("%d", time.Now().Unix())
-			events := waitForMinimumEventCount(t, searchQuery, startTime, 2)
-			fmt.Println(" =========>  Events received: ", len(events))
-			assert.GreaterOrEqual(t, len(events), 2)
+			verifyPodMetrics(t, index, sourcetype, namespace, podName, fmt.Sprintf("%d", time.Now().Unix()))
 		})
 	}
 }
@@ -193,36 +275,108 @@ func testVerifyMetricPodAnnotations(t *testing.T) {
 			if tt.annotationMetricSourcetypeValue != "" {
 				sourcetype = tt.annotationMetricSourcetypeValue
 			}
-			searchQuery := METRIC_SEARCH_QUERY_STRING + "index=" + index + " filter=\"sourcetype=" + sourcetype + "\" | search \"k8s.pod.name\"=" + podName
-			fmt.Println("Search Query: ", searchQuery)
-			startTime := fmt.Sprintf("%d", time.Now().Unix())
-			events := waitForMinimumEventCount(t, searchQuery, startTime, 2)
-			fmt.Println(" =========>  Events received: ", len(events))
-			assert.GreaterOrEqual(t, len(events), 2)
+			verifyPodMetrics(t, index, sourcetype, namespace, podName, fmt.Sprintf("%d", time.Now().Unix()))
 		})
 	}
 }
 
-func waitForExactEventCount(t *testing.T, searchQuery string, startTime string, expected int) []any {
+func waitForExactEventCount(t *testing.T, searchQuery string, startTime string, expected int) []SearchResult {
 	t.Helper()
+	return waitForEvents(t, searchQuery, startTime, func(n int) bool { return n == expected },
+		fmt.Sprintf("expected %d Splunk events", expected))
+}
+
+// waitForEvents repeats the search until the number of events satisfies ok.
+func waitForEvents(t *testing.T, searchQuery string, startTime string, ok func(n int) bool, description string) []SearchResult {
+	t.Helper()
+	return waitForSplunk(t, fmt.Sprintf("%s for query %q", description, searchQuery), func(ctx context.Context) ([]SearchResult, bool, error) {
+		events, err := searchSplunk(ctx, t, searchQuery, startTime)
+		return events, err == nil && ok(len(events)), err
+	})
+}
+
+// verifyPodMetrics checks that the metrics of the pod landed in the index with the sourcetype,
+// carrying the pod and namespace dimensions, and that they aggregate into a single pod series.
+func verifyPodMetrics(t *testing.T, index, sourcetype, namespace, podName, startTime string) {
+	t.Helper()
+	filters := map[string]string{"sourcetype": sourcetype, "k8s.namespace.name": namespace, "k8s.pod.name": podName}
+	catalog := waitForSplunk(t, fmt.Sprintf("expected metrics of pod %s in index %s with sourcetype %s", podName, index, sourcetype),
+		func(ctx context.Context) ([]MetricCatalogEntry, bool, error) {
+			client, err := splunkSearchClient()
+			require.NoError(t, err, "failed to configure the Splunk search client")
+			ctx, cancel := context.WithTimeout(ctx, splunkSearchTimeout)
+			defer cancel()
+			catalog, err := client.MetricCatalog(ctx, MetricCatalogRequest{Index: index, Filters: filters, Earliest: startTime})
+			return catalog, err == nil && len(catalog) > 0, err
+		})
+	t.Logf("Metrics received in %s: %d", index, len(catalog))
+	for _, entry := range catalog {
+		assert.True(t, entry.HasDimensions("k8s.namespace.name", "k8s.pod.name"), "metric %s dimensions: %v", entry.MetricName, entry.Dimensions)
+	}
 
-	var events []any
-	require.Eventually(t, func() bool {
-		events = CheckEventsFromSplunk(searchQuery, startTime)
-		return len(events) == expected
-	}, splunkIngestionTimeout, splunkSearchInterval, "expected %d Splunk events for query %q", expected, searchQuery)
-	return events
+	client, err := splunkSearchClient()
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(t.Context(), splunkSearchTimeout)
+	defer cancel()
+	series, err := client.MetricStats(ctx, MetricStatsRequest{
+		Index:       index,
+		MetricName:  catalog[0].MetricName,
+		Aggregation: "count",
+		Filters:     filters,
+		By:          []string{"k8s.pod.name"},
+		Earliest:    startTime,
+	})
+	require.NoError(t, err)
+	require.Len(t, series, 1, "series of %s", catalog[0].MetricName)
+	assert.Equal(t, podName, series[0].Dimensions["k8s.pod.name"])
+	assert.Positive(t, series[0].Points[0].Value)
 }
 
-func waitForMinimumEventCount(t *testing.T, searchQuery string, startTime string, minimum int) []any {
+// waitForSplunk repeats search until it reports done. Credential, query and truncation errors
+// fail the test at once; failed or slow searches are retried until the ingestion timeout.
+func waitForSplunk[T any](t *testing.T, description string, search func(ctx context.Context) (T, bool, error)) T {
 	t.Helper()
 
-	var events []any
-	require.Eventually(t, func() bool {
-		events = CheckEventsFromSplunk(searchQuery, startTime)
-		return len(events) >= minimum
-	}, splunkIngestionTimeout, splunkSearchInterval, "expected at least %d Splunk events for query %q", minimum, searchQuery)
-	return events
+	ctx, cancel := context.WithTimeout(t.Context(), splunkIngestionTimeout)
+	defer cancel()
+	for {
+		result, done, err := search(ctx)
+		var authErr *AuthError
+		var dispatchErr *DispatchError
+		var truncatedErr *TruncatedError
+		if errors.As(err, &authErr) || errors.As(err, &dispatchErr) || errors.As(err, &truncatedErr) {
+			require.NoError(t, err)
+		}
+		if done {
+			return result
+		}
+		select {
+		case <-ctx.Done():
+			require.NoError(t, err, description)
+			require.Fail(t, fmt.Sprintf("%s, got %v", description, result))
+			return result
+		case <-time.After(splunkSearchInterval):
+		}
+	}
+}
+
+var splunkSearchClient = sync.OnceValues(func() (*SearchClient, error) {
+	cfg, err := SearchClientConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewSearchClient(cfg)
+})
+
+// searchSplunk runs one search, bounded by splunkSearchTimeout.
+func searchSplunk(ctx context.Context, t *testing.T, searchQuery string, startTime string) ([]SearchResult, error) {
+	t.Helper()
+	client, err := splunkSearchClient()
+	require.NoError(t, err, "failed to configure the Splunk search client")
+	ctx, cancel := context.WithTimeout(ctx, splunkSearchTimeout)
+	defer cancel()
+	t.Logf("Splunk search: %s (earliest=%s)", searchQuery, startTime)
+	return client.Search(ctx, SearchRequest{Query: searchQuery, Earliest: startTime})
 }
 
 func createK8sClient(t *testing.T) *kubernetes.Clientset {
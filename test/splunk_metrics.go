@@ -0,0 +1,204 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricCatalogRequest lists the metrics of an index with mcatalog.
+type MetricCatalogRequest struct {
+	Index string
+	// Filters restricts the catalog to data points with these dimension or metadata values,
+	// e.g. sourcetype or k8s.pod.name.
+	Filters  map[string]string
+	Earliest string
+	Latest   string
+}
+
+// MetricCatalogEntry is a metric name and the dimensions its data points carry.
+type MetricCatalogEntry struct {
+	MetricName string
+	Dimensions []string
+}
+
+// HasDimensions reports whether the metric carries every one of the dimensions.
+func (e MetricCatalogEntry) HasDimensions(dimensions ...string) bool {
+	for _, dimension := range dimensions {
+		if !slices.Contains(e.Dimensions, dimension) {
+			return false
+		}
+	}
+	return true
+}
+
+// MetricStatsRequest aggregates the values of a metric with mstats.
+type MetricStatsRequest struct {
+	Index      string
+	MetricName string
+	// Aggregation is the mstats function applied to the values, e.g. avg, sum, max or count.
+	// Defaults to avg.
+	Aggregation string
+	Filters     map[string]string
+	// By splits the result into one series per combination of these dimensions.
+	By []string
+	// Span buckets the values over time; zero aggregates the whole time range into one point.
+	Span     time.Duration
+	Earliest string
+	Latest   string
+}
+
+// MetricSeries is the aggregated values of a metric for one combination of the By dimensions.
+type MetricSeries struct {
+	MetricName string
+	Dimensions map[string]string
+	Points     []MetricPoint
+}
+
+// MetricPoint is an aggregated value; Time is zero when the request had no span.
+type MetricPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// MetricCatalog returns the metric names of the index and their dimensions, sorted by name.
+func (c *SearchClient) MetricCatalog(ctx context.Context, req MetricCatalogRequest) ([]MetricCatalogEntry, error) {
+	query := "| mcatalog values(_dims) AS dims WHERE " + metricWhere(req.Index, "", req.Filters) + " BY metric_name"
+	rows, err := c.SearchResults(ctx, SearchRequest{Query: query, Earliest: req.Earliest, Latest: req.Latest})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]MetricCatalogEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := MetricCatalogEntry{MetricName: row.Field("metric_name"), Dimensions: row.Values("dims")}
+		sort.Strings(entry.Dimensions)
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].MetricName < entries[j].MetricName })
+	return entries, nil
+}
+
+// MetricStats returns the aggregated values of a metric, one series per combination of the
+// By dimensions, sorted by their values.
+func (c *SearchClient) MetricStats(ctx context.Context, req MetricStatsRequest) ([]MetricSeries, error) {
+	if req.MetricName == "" {
+		return nil, errors.New("a metric name is required")
+	}
+	aggregation := req.Aggregation
+	if aggregation == "" {
+		aggregation = "avg"
+	}
+	query := fmt.Sprintf("| mstats %s(_value) AS value WHERE %s", aggregation, metricWhere(req.Index, req.MetricName, req.Filters))
+	if len(req.By) > 0 {
+		fields := make([]string, len(req.By))
+		for i, field := range req.By {
+			fields[i] = splField(field)
+		}
+		query += " BY " + strings.Join(fields, ", ")
+	}
+	if req.Span > 0 {
+		query += fmt.Sprintf(" span=%ds", int(req.Span/time.Second))
+	}
+	rows, err := c.SearchResults(ctx, SearchRequest{Query: query, Earliest: req.Earliest, Latest: req.Latest})
+	if err != nil {
+		return nil, err
+	}
+
+	series := map[string]*MetricSeries{}
+	var keys []string
+	for _, row := range rows {
+		dimensions := make(map[string]string, len(req.By))
+		values := make([]string, len(req.By))
+		for i, field := range req.By {
+			dimensions[field] = row.Field(field)
+			values[i] = dimensions[field]
+		}
+		key := strings.Join(values, "\x00")
+		s, ok := series[key]
+		if !ok {
+			s = &MetricSeries{MetricName: req.MetricName, Dimensions: dimensions}
+			series[key] = s
+			keys = append(keys, key)
+		}
+		point, pointErr := metricPoint(row)
+		if pointErr != nil {
+			return nil, pointErr
+		}
+		s.Points = append(s.Points, point)
+	}
+	sort.Strings(keys)
+	out := make([]MetricSeries, len(keys))
+	for i, key := range keys {
+		out[i] = *series[key]
+		sort.Slice(out[i].Points, func(a, b int) bool { return out[i].Points[a].Time.Before(out[i].Points[b].Time) })
+	}
+	return out, nil
+}
+
+func metricPoint(row SearchResult) (MetricPoint, error) {
+	var point MetricPoint
+	value, err := strconv.ParseFloat(row.Field("value"), 64)
+	if err != nil {
+		return point, fmt.Errorf("invalid mstats value %q: %w", row.Field("value"), err)
+	}
+	point.Value = value
+	if row.Time != "" {
+		if point.Time, err = parseResultTime(row.Time); err != nil {
+			return point, err
+		}
+	}
+	return point, nil
+}
+
+// parseResultTime parses the _time of a result, as an ISO 8601 time or epoch seconds.
+func parseResultTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02T15:04:05.000-07:00", value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.UnixMilli(int64(seconds * 1000)), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid _time %q", value)
+}
+
+// metricWhere renders the WHERE clause of mcatalog and mstats, with the filters sorted so the
+// query is stable.
+func metricWhere(index, metricName string, filters map[string]string) string {
+	clauses := []string{"index=" + splValue(index)}
+	if metricName != "" {
+		clauses = append(clauses, "metric_name="+splValue(metricName))
+	}
+	fields := make([]string, 0, len(filters))
+	for field := range filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		clauses = append(clauses, splField(field)+"="+splValue(filters[field]))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+var plainSPLField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// splField quotes a field name that is not a plain, possibly dotted, identifier.
+func splField(field string) string {
+	if plainSPLField.MatchString(field) {
+		return field
+	}
+	return splValue(field)
+}
+
+// splValue quotes a value for a search clause.
+func splValue(value string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`) + `"`
+}
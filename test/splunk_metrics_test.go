@@ -0,0 +1,100 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricSearch(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Truncate(time.Minute)
+	ts := func(at time.Time) string {
+		return strconv.FormatInt(at.Unix(), 10)
+	}
+	client := startStandIn(t, `{"time":`+ts(now.Add(-2*time.Minute))+`,"sourcetype":"httpevent","index":"ci_metrics","event":"metric","fields":{"metric_name:k8s.pod.cpu.time":1,"metric_name:k8s.pod.memory.usage":100,"k8s.pod.name":"pod-a","k8s.namespace.name":"ns"}}
+{"time":`+ts(now.Add(-2*time.Minute+10*time.Second))+`,"sourcetype":"httpevent","index":"ci_metrics","event":"metric","fields":{"metric_name:k8s.pod.cpu.time":3,"k8s.pod.name":"pod-a","k8s.namespace.name":"ns"}}
+{"time":`+ts(now.Add(-time.Minute))+`,"sourcetype":"httpevent","index":"ci_metrics","event":"metric","fields":{"metric_name:k8s.pod.cpu.time":5,"k8s.pod.name":"pod-a","k8s.namespace.name":"ns"}}
+{"time":`+ts(now.Add(-time.Minute))+`,"sourcetype":"annotation_sourcetype","index":"ci_metrics","event":"metric","fields":{"metric_name:k8s.pod.cpu.time":7,"k8s.pod.name":"pod-b","k8s.namespace.name":"ns","k8s.node.name":"node"}}
+{"time":`+ts(now.Add(-time.Minute))+`,"sourcetype":"httpevent","index":"test_metrics","event":"metric","fields":{"metric_name:k8s.node.cpu.time":9,"k8s.node.name":"node"}}
+`)
+
+	t.Run("catalog", func(t *testing.T) {
+		t.Parallel()
+		catalog, err := client.MetricCatalog(t.Context(), MetricCatalogRequest{Index: "ci_metrics", Earliest: "-1h"})
+		require.NoError(t, err)
+		require.Equal(t, []MetricCatalogEntry{
+			{MetricName: "k8s.pod.cpu.time", Dimensions: []string{"k8s.namespace.name", "k8s.node.name", "k8s.pod.name"}},
+			{MetricName: "k8s.pod.memory.usage", Dimensions: []string{"k8s.namespace.name", "k8s.pod.name"}},
+		}, catalog)
+		require.True(t, catalog[1].HasDimensions("k8s.pod.name", "k8s.namespace.name"))
+		require.False(t, catalog[1].HasDimensions("k8s.node.name"))
+
+		catalog, err = client.MetricCatalog(t.Context(), MetricCatalogRequest{
+			Index:    "ci_metrics",
+			Filters:  map[string]string{"sourcetype": "annotation_sourcetype", "k8s.pod.name": "pod-b"},
+			Earliest: "-1h",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []MetricCatalogEntry{
+			{MetricName: "k8s.pod.cpu.time", Dimensions: []string{"k8s.namespace.name", "k8s.node.name", "k8s.pod.name"}},
+		}, catalog)
+	})
+
+	t.Run("stats by dimension", func(t *testing.T) {
+		t.Parallel()
+		series, err := client.MetricStats(t.Context(), MetricStatsRequest{
+			Index:      "ci_metrics",
+			MetricName: "k8s.pod.cpu.time",
+			By:         []string{"k8s.pod.name"},
+			Earliest:   "-1h",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []MetricSeries{
+			{MetricName: "k8s.pod.cpu.time", Dimensions: map[string]string{"k8s.pod.name": "pod-a"}, Points: []MetricPoint{{Value: 3}}},
+			{MetricName: "k8s.pod.cpu.time", Dimensions: map[string]string{"k8s.pod.name": "pod-b"}, Points: []MetricPoint{{Value: 7}}},
+		}, series)
+	})
+
+	t.Run("stats over time", func(t *testing.T) {
+		t.Parallel()
+		series, err := client.MetricStats(t.Context(), MetricStatsRequest{
+			Index:       "ci_metrics",
+			MetricName:  "k8s.pod.cpu.time",
+			Aggregation: "max",
+			Filters:     map[string]string{"k8s.pod.name": "pod-a"},
+			Span:        time.Minute,
+			Earliest:    "-1h",
+		})
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		require.Len(t, series[0].Points, 2)
+		require.True(t, now.Add(-2*time.Minute).Equal(series[0].Points[0].Time), "got %s", series[0].Points[0].Time)
+		require.Equal(t, 3.0, series[0].Points[0].Value)
+		require.Equal(t, 5.0, series[0].Points[1].Value)
+	})
+
+	t.Run("other index", func(t *testing.T) {
+		t.Parallel()
+		series, err := client.MetricStats(t.Context(), MetricStatsRequest{Index: "test_metrics", MetricName: "k8s.pod.cpu.time", Earliest: "-1h"})
+		require.NoError(t, err)
+		require.Empty(t, series)
+	})
+}
+
+func TestMetricWhere(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, `index="ci_metrics" AND metric_name="k8s.pod.cpu.time" AND k8s.pod.name="pod \"a\"" AND "odd field"="x" AND sourcetype="httpevent"`,
+		metricWhere("ci_metrics", "k8s.pod.cpu.time", map[string]string{
+			"sourcetype":   "httpevent",
+			"k8s.pod.name": `pod "a"`,
+			"odd field":    "x",
+		}))
+}
@@ -4,11 +4,13 @@
 package test
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,143 +19,559 @@ import (
 	"time"
 )
 
-func CheckEventsFromSplunk(searchQuery string, startTime string, endTimeOptional ...string) []any {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
-	logger.Println("-->> Splunk Search: checking events in Splunk --")
-	user := GetConfigVariable("USER")
-	password := GetConfigVariable("PASSWORD")
-	baseURL := "https://" + GetConfigVariable("HOST") + ":" + GetConfigVariable("MANAGEMENT_PORT")
-	endTime := "now"
-	if len(endTimeOptional) > 0 {
-		endTime = endTimeOptional[0]
-	}
-	// post search
-	jobID := postSearchRequest(user, password, baseURL, searchQuery, startTime, endTime)
-	// wait for search status done == true
-	for i := 0; i < 20; i++ { // limit loop - not allowing infinite looping
-		logger.Println("Checking Search Status ...")
-		isDone := checkSearchJobStatusCode(user, password, baseURL, jobID)
-		if isDone == true {
-			break
-		}
-		time.Sleep(1 * time.Second)
-	}
-	// get events
-	results := getSplunkSearchResults(user, password, baseURL, jobID)
-	return results
-}
-
-func getSplunkSearchResults(user string, password string, baseURL string, jobID string) []any {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
-	eventURL := fmt.Sprintf("%s/services/search/jobs/%s/events?output_mode=json", baseURL, jobID)
-	logger.Println("URL: " + eventURL)
-	reqEvents, err := http.NewRequest(http.MethodGet, eventURL, nil)
-	if err != nil {
-		panic(err)
+// SearchClientConfig configures a SearchClient.
+type SearchClientConfig struct {
+	// BaseURL is the Splunk management endpoint, e.g. https://splunk:8089.
+	BaseURL string
+	// Username and Password authenticate with basic auth, unless Token is set.
+	Username string
+	Password string
+	// Token is a Splunk authentication token sent as a bearer token.
+	Token string
+	// CAFile is a PEM bundle to verify the Splunk certificate with.
+	CAFile string
+	// InsecureSkipVerify disables certificate verification, for the self-signed test instance.
+	InsecureSkipVerify bool
+	// PollInterval is how often Wait checks the job status. Defaults to 1s.
+	PollInterval time.Duration
+	// PageSize is the number of rows fetched per request. Defaults to 1000.
+	PageSize int
+	// Logf logs the requests the client makes. Defaults to no logging.
+	Logf func(format string, args ...any)
+}
+
+// SearchClient runs searches against the Splunk REST API. A client is safe for concurrent use
+// and reuses its connections.
+type SearchClient struct {
+	cfg        SearchClientConfig
+	httpClient *http.Client
+}
+
+// NewSearchClient returns a client for the Splunk instance described by cfg.
+func NewSearchClient(cfg SearchClientConfig) (*SearchClient, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("the Splunk base URL is required")
 	}
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	if cfg.Token == "" && cfg.Username == "" {
+		return nil, errors.New("a Splunk token or username is required")
 	}
-	client := &http.Client{Transport: tr}
-	reqEvents.SetBasicAuth(user, password)
-	respEvents, err := client.Do(reqEvents)
-	if err != nil {
-		panic(err)
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 1000
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...any) {}
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} //nolint:gosec // the CI Splunk instance uses a self-signed certificate
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Splunk CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
 	}
-	defer respEvents.Body.Close()
-	logger.Println("Send Request: Get query status code: " + strconv.Itoa(respEvents.StatusCode))
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &SearchClient{cfg: cfg, httpClient: &http.Client{Transport: transport}}, nil
+}
+
+// AuthError is returned when Splunk rejects the credentials.
+type AuthError struct {
+	StatusCode int
+	Messages   []string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("splunk authentication failed (HTTP %d): %s", e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+// DispatchError is returned when Splunk does not accept a search, e.g. for a syntax error.
+type DispatchError struct {
+	Query      string
+	StatusCode int
+	Messages   []string
+}
+
+func (e *DispatchError) Error() string {
+	return fmt.Sprintf("splunk rejected search %q (HTTP %d): %s", e.Query, e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+// JobFailedError is returned when a dispatched search job fails.
+type JobFailedError struct {
+	SID    string
+	Status JobStatus
+}
+
+func (e *JobFailedError) Error() string {
+	return fmt.Sprintf("splunk search job %s failed in state %s: %s", e.SID, e.Status.DispatchState, strings.Join(e.Status.Messages, "; "))
+}
+
+// TimeoutError is returned when a search job does not finish before the context is done.
+// It unwraps to the context error.
+type TimeoutError struct {
+	SID string
+	// Status is the last status seen, to tell a queued job from a slow one.
+	Status JobStatus
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("splunk search job %s did not finish (state %s, %d events scanned, %.0f%% done): %v",
+		e.SID, e.Status.DispatchState, e.Status.ScanCount, e.Status.DoneProgress*100, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TruncatedError is returned when fewer rows were retrieved than the job reported, e.g.
+// because of a server-side limit on the number of results kept.
+type TruncatedError struct {
+	SID      string
+	Expected int
+	Received int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("splunk search job %s reported %d rows, only %d were retrieved", e.SID, e.Expected, e.Received)
+}
 
-	bodyEvents, err := io.ReadAll(respEvents.Body)
+// APIError is returned for any other unexpected response of the Splunk REST API.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Messages   []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("splunk %s %s returned HTTP %d: %s", e.Method, e.Path, e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+// SearchRequest is a search to dispatch. Earliest and Latest take Splunk time modifiers or
+// epoch seconds; Latest defaults to "now".
+type SearchRequest struct {
+	Query    string
+	Earliest string
+	Latest   string
+}
+
+// JobStatus is the state of a search job.
+type JobStatus struct {
+	DispatchState string   `json:"dispatchState"`
+	IsDone        bool     `json:"isDone"`
+	IsFailed      bool     `json:"isFailed"`
+	DoneProgress  float64  `json:"doneProgress"`
+	ScanCount     int      `json:"scanCount"`
+	EventCount    int      `json:"eventCount"`
+	ResultCount   int      `json:"resultCount"`
+	Messages      []string `json:"-"`
+}
+
+// SearchResult is a row of search results. The common fields are typed; every field, those
+// included, is available through Field and Fields.
+type SearchResult struct {
+	Raw        string
+	Time       string
+	Index      string
+	Source     string
+	Sourcetype string
+	Host       string
+	Fields     map[string]any
+}
+
+// UnmarshalJSON decodes a result row as returned with output_mode=json.
+func (r *SearchResult) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Fields); err != nil {
+		return err
+	}
+	r.Raw = r.Field("_raw")
+	r.Time = r.Field("_time")
+	r.Index = r.Field("index")
+	r.Source = r.Field("source")
+	r.Sourcetype = r.Field("sourcetype")
+	r.Host = r.Field("host")
+	return nil
+}
+
+// Field returns a field as a string. Multivalue fields are joined with commas.
+func (r SearchResult) Field(name string) string {
+	switch v := r.Fields[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = fmt.Sprint(item)
+		}
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Values returns a multivalue field as a list; a single value is a list of one.
+func (r SearchResult) Values(name string) []string {
+	switch v := r.Fields[name].(type) {
+	case nil:
+		return nil
+	case []any:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = fmt.Sprint(item)
+		}
+		return values
+	default:
+		return []string{r.Field(name)}
+	}
+}
+
+// Search dispatches the search, waits for it and returns all of its events. The job is
+// cancelled when the search fails or ctx is done before it finishes. A TruncatedError is
+// returned when fewer events can be retrieved than the job counted.
+func (c *SearchClient) Search(ctx context.Context, req SearchRequest) ([]SearchResult, error) {
+	sid, status, err := c.run(ctx, req)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	events, err := c.Events(ctx, sid)
+	if err == nil && len(events) < status.EventCount {
+		err = &TruncatedError{SID: sid, Expected: status.EventCount, Received: len(events)}
 	}
+	return events, err
+}
 
-	var jsonResponseEvents map[string]any
-	err = json.Unmarshal(bodyEvents, &jsonResponseEvents)
+// SearchResults is Search for reporting searches such as mstats and mcatalog, whose output
+// is their results rather than events.
+func (c *SearchClient) SearchResults(ctx context.Context, req SearchRequest) ([]SearchResult, error) {
+	sid, status, err := c.run(ctx, req)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	results, err := c.Results(ctx, sid)
+	if err == nil && len(results) < status.ResultCount {
+		err = &TruncatedError{SID: sid, Expected: status.ResultCount, Received: len(results)}
 	}
+	return results, err
+}
 
-	// logger.Println("json Response Events --->")   # debug
-	// logger.Println(jsonResponseEvents)			# debug
-	results := jsonResponseEvents["results"].([]any)
-	return results
+// Count dispatches the search and waits for it without retrieving any row. The status holds
+// the number of events and results of the job, however large.
+func (c *SearchClient) Count(ctx context.Context, req SearchRequest) (JobStatus, error) {
+	_, status, err := c.run(ctx, req)
+	return status, err
 }
 
-func checkSearchJobStatusCode(user string, password string, baseURL string, jobID string) any {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
-	checkEventURL := baseURL + "/services/search/jobs/" + jobID + "?output_mode=json"
-	logger.Println("URL: " + checkEventURL)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+func (c *SearchClient) run(ctx context.Context, req SearchRequest) (string, JobStatus, error) {
+	sid, err := c.Dispatch(ctx, req)
+	if err != nil {
+		return "", JobStatus{}, err
 	}
-	client := &http.Client{Transport: tr}
-	checkReqEvents, err := http.NewRequest(http.MethodGet, checkEventURL, nil)
+	status, err := c.Wait(ctx, sid)
 	if err != nil {
-		panic(err)
+		cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if cancelErr := c.Cancel(cancelCtx, sid); cancelErr != nil {
+			c.cfg.Logf("Failed to cancel Splunk search job %s: %v", sid, cancelErr)
+		}
+		return sid, status, err
+	}
+	return sid, status, nil
+}
+
+// Dispatch creates a search job and returns its search ID.
+func (c *SearchClient) Dispatch(ctx context.Context, req SearchRequest) (string, error) {
+	var response struct {
+		SID string `json:"sid"`
+	}
+	err := c.do(ctx, http.MethodPost, "/services/search/jobs", c.searchForm("search", req), &response)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return "", &DispatchError{Query: req.Query, StatusCode: apiErr.StatusCode, Messages: apiErr.Messages}
 	}
-	checkReqEvents.SetBasicAuth(user, password)
-	checkResp, err := client.Do(checkReqEvents)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	if response.SID == "" {
+		return "", &DispatchError{Query: req.Query, StatusCode: http.StatusOK, Messages: []string{"no search ID in the response"}}
+	}
+	return response.SID, nil
+}
+
+// Status returns the current state of a search job.
+func (c *SearchClient) Status(ctx context.Context, sid string) (JobStatus, error) {
+	var response struct {
+		Entry []struct {
+			Content struct {
+				JobStatus
+				Messages []splunkMessage `json:"messages"`
+			} `json:"content"`
+		} `json:"entry"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/services/search/jobs/"+url.PathEscape(sid), nil, &response); err != nil {
+		return JobStatus{}, err
+	}
+	if len(response.Entry) == 0 {
+		return JobStatus{}, &APIError{Method: http.MethodGet, Path: "/services/search/jobs/" + sid, StatusCode: http.StatusOK, Messages: []string{"no job entry in the response"}}
+	}
+	content := response.Entry[0].Content
+	status := content.JobStatus
+	for _, m := range content.Messages {
+		status.Messages = append(status.Messages, m.String())
+	}
+	return status, nil
+}
+
+// Wait polls a search job until it is done. It returns a JobFailedError when the job fails
+// and a TimeoutError when ctx is done first.
+func (c *SearchClient) Wait(ctx context.Context, sid string) (JobStatus, error) {
+	var status JobStatus
+	for {
+		current, err := c.Status(ctx, sid)
+		if err != nil {
+			if ctx.Err() != nil {
+				return status, &TimeoutError{SID: sid, Status: status, Err: ctx.Err()}
+			}
+			return status, err
+		}
+		status = current
+		if status.IsFailed || status.DispatchState == "FAILED" {
+			return status, &JobFailedError{SID: sid, Status: status}
+		}
+		if status.IsDone {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return status, &TimeoutError{SID: sid, Status: status, Err: ctx.Err()}
+		case <-time.After(c.cfg.PollInterval):
+		}
+	}
+}
+
+// Cancel cancels a search job and releases its results.
+func (c *SearchClient) Cancel(ctx context.Context, sid string) error {
+	form := url.Values{}
+	form.Set("action", "cancel")
+	return c.do(ctx, http.MethodPost, "/services/search/jobs/"+url.PathEscape(sid)+"/control", form, nil)
+}
+
+// Events returns all events of a finished search job, a page at a time.
+func (c *SearchClient) Events(ctx context.Context, sid string) ([]SearchResult, error) {
+	return c.rows(ctx, sid, "events")
+}
+
+// Results returns all results of a finished search job, i.e. the output of its last command,
+// a page at a time.
+func (c *SearchClient) Results(ctx context.Context, sid string) ([]SearchResult, error) {
+	return c.rows(ctx, sid, "results")
+}
+
+// rows pages through the rows of a job until a page comes back short.
+func (c *SearchClient) rows(ctx context.Context, sid, endpoint string) ([]SearchResult, error) {
+	var rows []SearchResult
+	for {
+		var response struct {
+			Results []SearchResult `json:"results"`
+		}
+		query := url.Values{}
+		query.Set("count", strconv.Itoa(c.cfg.PageSize))
+		query.Set("offset", strconv.Itoa(len(rows)))
+		if err := c.do(ctx, http.MethodGet, "/services/search/jobs/"+url.PathEscape(sid)+"/"+endpoint+"?"+query.Encode(), nil, &response); err != nil {
+			return rows, err
+		}
+		rows = append(rows, response.Results...)
+		if len(response.Results) < c.cfg.PageSize {
+			return rows, nil
+		}
+	}
+}
+
+// Export runs the search through the export endpoint, which streams the results as they are
+// found instead of keeping a job, and calls fn for each of them. It stops at the first error
+// fn returns.
+func (c *SearchClient) Export(ctx context.Context, req SearchRequest, fn func(SearchResult) error) error {
+	resp, err := c.send(ctx, http.MethodPost, "/services/search/jobs/export", c.searchForm("export", req))
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &DispatchError{Query: req.Query, StatusCode: apiErr.StatusCode, Messages: apiErr.Messages}
 	}
-	defer checkResp.Body.Close()
-	logger.Println("Send Request: Check query status code: " + strconv.Itoa(checkResp.StatusCode))
-	checkBody, err := io.ReadAll(checkResp.Body)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	var checkJSONResponse map[string]any
-	err = json.Unmarshal(checkBody, &checkJSONResponse)
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line struct {
+			Preview  bool            `json:"preview"`
+			Result   *SearchResult   `json:"result"`
+			Messages []splunkMessage `json:"messages"`
+		}
+		if err = decoder.Decode(&line); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("splunk export: failed to decode the response: %w", err)
+		}
+		for _, m := range line.Messages {
+			if m.Type == "FATAL" || m.Type == "ERROR" {
+				return &DispatchError{Query: req.Query, StatusCode: resp.StatusCode, Messages: []string{m.String()}}
+			}
+		}
+		if line.Preview || line.Result == nil {
+			continue
+		}
+		if err = fn(*line.Result); err != nil {
+			return err
+		}
+	}
+}
+
+// searchForm is the form of a search job or export request, latest defaulting to now.
+func (c *SearchClient) searchForm(kind string, req SearchRequest) url.Values {
+	latest := req.Latest
+	if latest == "" {
+		latest = "now"
+	}
+	c.cfg.Logf("Splunk %s: %s (earliest=%s latest=%s)", kind, req.Query, req.Earliest, latest)
+	form := url.Values{}
+	form.Set("search", req.Query)
+	form.Set("earliest_time", req.Earliest)
+	form.Set("latest_time", latest)
+	return form
+}
+
+// do sends a request with output_mode=json and decodes the response into out, when not nil.
+func (c *SearchClient) do(ctx context.Context, method, path string, form url.Values, out any) error {
+	resp, err := c.send(ctx, method, path, form)
 	if err != nil {
-		panic(err)
-	}
-	// logger.Println(checkJSONResponse) // debug
-	// Print isDone field from response
-	isDone := checkJSONResponse["entry"].([]any)[0].(map[string]any)["content"].(map[string]any)["isDone"]
-	logger.Printf("Is Splunk Search completed [isDone flag]: %v\n", isDone)
-	return isDone
-}
-
-func postSearchRequest(user string, password string, baseURL string, searchQuery string, startTime string, endTime string) string {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
-	searchURL := fmt.Sprintf("%s/services/search/jobs?output_mode=json", baseURL)
-	query := searchQuery
-	logger.Println("Search query: " + query)
-	data := url.Values{}
-	data.Set("search", query)
-	data.Set("earliest_time", startTime)
-	data.Set("latest_time", endTime)
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	req, err := http.NewRequest(http.MethodPost, searchURL, strings.NewReader(data.Encode()))
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.Printf("Error while preparing POST request")
-		panic(err)
+		return fmt.Errorf("splunk %s %s: failed to read the response: %w", method, path, err)
 	}
-	req.SetBasicAuth(user, password)
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("splunk %s %s: failed to decode the response: %w", method, path, err)
+	}
+	return nil
+}
 
-	resp, err := client.Do(req)
+// send sends a request with output_mode=json. On success the caller reads and closes the
+// body; error statuses are returned as an AuthError or an APIError.
+func (c *SearchClient) send(ctx context.Context, method, path string, form url.Values) (*http.Response, error) {
+	target := c.cfg.BaseURL + path
+	if strings.Contains(path, "?") {
+		target += "&output_mode=json"
+	} else {
+		target += "?output_mode=json"
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
 	if err != nil {
-		logger.Printf("Error while executing Http POST request")
-		panic(err)
+		return nil, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	} else {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
 	}
-	defer resp.Body.Close()
-	logger.Println("Send Request: Post query status code: " + strconv.Itoa(resp.StatusCode))
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("splunk %s %s: %w", method, path, err)
 	}
-	var jsonResponse map[string]any
-	err = json.Unmarshal(body, &jsonResponse)
+	c.cfg.Logf("Splunk %s %s: HTTP %d", method, path, resp.StatusCode)
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("splunk %s %s: failed to read the response: %w", method, path, err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, &AuthError{StatusCode: resp.StatusCode, Messages: responseMessages(data)}
+	}
+	return nil, &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Messages: responseMessages(data)}
+}
+
+type splunkMessage struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (m splunkMessage) String() string {
+	return m.Type + ": " + m.Text
+}
+
+// responseMessages extracts the messages of a Splunk error response, or returns the body.
+func responseMessages(data []byte) []string {
+	var response struct {
+		Messages []splunkMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil || len(response.Messages) == 0 {
+		return []string{strings.TrimSpace(string(data))}
+	}
+	messages := make([]string, len(response.Messages))
+	for i, m := range response.Messages {
+		messages[i] = m.String()
+	}
+	return messages
+}
+
+// SearchClientConfigFromEnv builds the client configuration of the CI Splunk instance from
+// the CI_SPLUNK_* environment variables.
+func SearchClientConfigFromEnv() (SearchClientConfig, error) {
+	var missing []string
+	lookup := func(name string) string {
+		value := os.Getenv(name)
+		if value == "" {
+			missing = append(missing, name)
+		}
+		return value
+	}
+	host := lookup(HostEnvVar)
+	port := lookup(ManagementPortEnvVar)
+	cfg := SearchClientConfig{
+		BaseURL: "https://" + host + ":" + port,
+		Token:   os.Getenv(TokenEnvVar),
+		CAFile:  os.Getenv(CAFileEnvVar),
+	}
+	if cfg.Token == "" {
+		cfg.Username = lookup(UserEnvVar)
+		cfg.Password = lookup(PasswordEnvVar)
+	}
+	if len(missing) > 0 {
+		return cfg, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	// Without a CA, only the self-signed CI instance is expected.
+	cfg.InsecureSkipVerify = cfg.CAFile == ""
+	if value := os.Getenv(InsecureSkipVerifyEnvVar); value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", InsecureSkipVerifyEnvVar, err)
+		}
+		cfg.InsecureSkipVerify = insecure
 	}
-	logger.Println(jsonResponse) // debug
-	return jsonResponse["sid"].(string)
+	return cfg, nil
 }
@@ -0,0 +1,158 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSplunk serves the search job endpoints; the job finishes after doneAfter status checks.
+func fakeSplunk(t *testing.T, doneAfter int32, cancelled *atomic.Bool) *httptest.Server {
+	var checks atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /services/search/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); r.Header.Get("Authorization") != "Bearer token" && (!ok || user != "admin" || password != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"messages":[{"type":"WARN","text":"call not properly authenticated"}]}`))
+			return
+		}
+		if r.FormValue("search") == "| bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"messages":[{"type":"FATAL","text":"Unknown search command 'bad'."}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"sid":"1234.5"}`))
+	})
+	mux.HandleFunc("GET /services/search/jobs/1234.5", func(w http.ResponseWriter, _ *http.Request) {
+		done := checks.Add(1) > doneAfter
+		state := "RUNNING"
+		if done {
+			state = "DONE"
+		}
+		_, _ = w.Write([]byte(`{"entry":[{"content":{"dispatchState":"` + state + `","isDone":` + map[bool]string{true: "true", false: "false"}[done] +
+			`,"doneProgress":0.5,"scanCount":42,"messages":[]}}]}`))
+	})
+	mux.HandleFunc("POST /services/search/jobs/1234.5/control", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("action") == "cancel" {
+			cancelled.Store(true)
+		}
+		_, _ = w.Write([]byte(`{"messages":[{"type":"INFO","text":"Search job cancelled."}]}`))
+	})
+	mux.HandleFunc("GET /services/search/jobs/1234.5/events", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "json", r.URL.Query().Get("output_mode"))
+		require.Equal(t, "1000", r.URL.Query().Get("count"))
+		require.Equal(t, "0", r.URL.Query().Get("offset"))
+		_, _ = w.Write([]byte(`{"results":[{"_raw":"hello","_time":"2026-01-01T00:00:00.000+00:00","index":"ci_events","sourcetype":"kube:container:app","k8s.pod.labels.app":["a","b"]}]}`))
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestSearchClient(t *testing.T) {
+	t.Parallel()
+
+	var cancelled atomic.Bool
+	server := fakeSplunk(t, 2, &cancelled)
+	client, err := NewSearchClient(SearchClientConfig{
+		BaseURL:      server.URL,
+		Username:     "admin",
+		Password:     "secret",
+		CAFile:       writeServerCA(t, server),
+		PollInterval: time.Millisecond,
+		Logf:         t.Logf,
+	})
+	require.NoError(t, err)
+
+	results, err := client.Search(t.Context(), SearchRequest{Query: "search index=ci_events", Earliest: "-1h@h"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "hello", results[0].Raw)
+	require.Equal(t, "ci_events", results[0].Index)
+	require.Equal(t, "kube:container:app", results[0].Sourcetype)
+	require.Equal(t, "a,b", results[0].Field("k8s.pod.labels.app"))
+	require.False(t, cancelled.Load())
+}
+
+func TestSearchClientErrors(t *testing.T) {
+	t.Parallel()
+
+	var cancelled atomic.Bool
+	server := fakeSplunk(t, 1000, &cancelled)
+	newClient := func(cfg SearchClientConfig) *SearchClient {
+		cfg.BaseURL = server.URL
+		cfg.PollInterval = time.Millisecond
+		client, err := NewSearchClient(cfg)
+		require.NoError(t, err)
+		return client
+	}
+
+	// The certificate is not trusted without the CA or InsecureSkipVerify.
+	_, err := newClient(SearchClientConfig{Token: "token"}).Dispatch(t.Context(), SearchRequest{Query: "search *"})
+	require.ErrorContains(t, err, "certificate")
+
+	_, err = newClient(SearchClientConfig{Username: "admin", Password: "wrong", InsecureSkipVerify: true}).Search(t.Context(), SearchRequest{Query: "search *"})
+	var authErr *AuthError
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, http.StatusUnauthorized, authErr.StatusCode)
+	require.Equal(t, []string{"WARN: call not properly authenticated"}, authErr.Messages)
+
+	client := newClient(SearchClientConfig{Token: "token", InsecureSkipVerify: true})
+	_, err = client.Search(t.Context(), SearchRequest{Query: "| bad"})
+	var dispatchErr *DispatchError
+	require.ErrorAs(t, err, &dispatchErr)
+	require.Equal(t, []string{"FATAL: Unknown search command 'bad'."}, dispatchErr.Messages)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Search(ctx, SearchRequest{Query: "search *"})
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, "1234.5", timeoutErr.SID)
+	require.Equal(t, "RUNNING", timeoutErr.Status.DispatchState)
+	require.Equal(t, 42, timeoutErr.Status.ScanCount)
+	require.True(t, cancelled.Load(), "the timed out job was not cancelled")
+}
+
+func TestSearchClientConfigFromEnv(t *testing.T) {
+	t.Setenv(HostEnvVar, "splunk")
+	t.Setenv(ManagementPortEnvVar, "8089")
+	t.Setenv(UserEnvVar, "")
+	t.Setenv(PasswordEnvVar, "")
+	t.Setenv(TokenEnvVar, "")
+	t.Setenv(CAFileEnvVar, "")
+	t.Setenv(InsecureSkipVerifyEnvVar, "")
+
+	_, err := SearchClientConfigFromEnv()
+	require.EqualError(t, err, "environment variables not set: CI_SPLUNK_USERNAME, CI_SPLUNK_PASSWORD")
+
+	t.Setenv(TokenEnvVar, "token")
+	cfg, err := SearchClientConfigFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, SearchClientConfig{BaseURL: "https://splunk:8089", Token: "token", InsecureSkipVerify: true}, cfg)
+
+	t.Setenv(CAFileEnvVar, "/etc/splunk/ca.pem")
+	cfg, err = SearchClientConfigFromEnv()
+	require.NoError(t, err)
+	require.False(t, cfg.InsecureSkipVerify)
+}
@@ -0,0 +1,626 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SplunkStandIn is a hermetic stand-in for the parts of Splunk the integration tests use: an
+// HTTP Event Collector that stores what the collector sends, and the search job API answering
+// the "| search" and "| mpreview" queries of the tests from the stored data.
+type SplunkStandIn struct {
+	cfg SplunkStandInConfig
+
+	mu      sync.Mutex
+	entries []hecEntry
+	jobs    map[string]standInJob
+	nextSID int
+}
+
+// SplunkStandInConfig configures a SplunkStandIn. Empty credentials accept any request.
+type SplunkStandInConfig struct {
+	// HECToken is the token the collector sends.
+	HECToken string
+	// Username, Password and Token authenticate search requests, like SearchClientConfig.
+	Username string
+	Password string
+	Token    string
+	// DefaultIndex receives the events sent without an index. Defaults to "main".
+	DefaultIndex string
+	// MaxResultRows caps the rows a job keeps, like the limits of a real search head, while
+	// its status still counts all of them. Zero keeps every row.
+	MaxResultRows int
+}
+
+// NewSplunkStandIn returns an empty stand-in.
+func NewSplunkStandIn(cfg SplunkStandInConfig) *SplunkStandIn {
+	if cfg.DefaultIndex == "" {
+		cfg.DefaultIndex = "main"
+	}
+	return &SplunkStandIn{cfg: cfg, jobs: map[string]standInJob{}}
+}
+
+// hecEntry is an event or a metric as received on the HTTP Event Collector.
+type hecEntry struct {
+	Time       float64        `json:"time"`
+	Host       string         `json:"host"`
+	Source     string         `json:"source"`
+	Sourcetype string         `json:"sourcetype"`
+	Index      string         `json:"index"`
+	Event      any            `json:"event"`
+	Fields     map[string]any `json:"fields"`
+}
+
+// isMetric reports whether the entry is a metric in the HEC metric format.
+func (e hecEntry) isMetric() bool {
+	if e.Event != "metric" {
+		return false
+	}
+	for key := range e.Fields {
+		if strings.HasPrefix(key, "metric_name:") {
+			return true
+		}
+	}
+	return false
+}
+
+func (e hecEntry) timestamp() time.Time {
+	seconds, fraction := math.Modf(e.Time)
+	return time.Unix(int64(seconds), int64(fraction*1e9))
+}
+
+// row is the entry as a search result row.
+func (e hecEntry) row() map[string]any {
+	row := make(map[string]any, len(e.Fields)+6)
+	for key, value := range e.Fields {
+		row[key] = value
+	}
+	raw, ok := e.Event.(string)
+	if !ok {
+		data, _ := json.Marshal(e.Event)
+		raw = string(data)
+	}
+	if e.isMetric() {
+		data, _ := json.Marshal(e.Fields)
+		raw = string(data)
+	}
+	row["_raw"] = raw
+	row["_time"] = e.timestamp().Format("2006-01-02T15:04:05.000-07:00")
+	row["host"] = e.Host
+	row["source"] = e.Source
+	row["sourcetype"] = e.Sourcetype
+	row["index"] = e.Index
+	return row
+}
+
+// Events returns the number of events and metrics received so far.
+func (s *SplunkStandIn) Events() (events, metrics int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.isMetric() {
+			metrics++
+		} else {
+			events++
+		}
+	}
+	return events, metrics
+}
+
+// HECHandler serves the HTTP Event Collector endpoints.
+func (s *SplunkStandIn) HECHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /services/collector/health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"text": "HEC is healthy", "code": 17})
+	})
+	receive := func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.HECToken != "" && r.Header.Get("Authorization") != "Splunk "+s.cfg.HECToken {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"text": "Invalid token", "code": 4})
+			return
+		}
+		entries, err := decodeHEC(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"text": err.Error(), "code": 6})
+			return
+		}
+		s.mu.Lock()
+		for _, e := range entries {
+			if e.Index == "" {
+				e.Index = s.cfg.DefaultIndex
+			}
+			if e.Time == 0 {
+				e.Time = float64(time.Now().UnixNano()) / 1e9
+			}
+			s.entries = append(s.entries, e)
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"text": "Success", "code": 0})
+	}
+	mux.HandleFunc("POST /services/collector", receive)
+	mux.HandleFunc("POST /services/collector/event", receive)
+	mux.HandleFunc("POST /services/collector/event/1.0", receive)
+	return mux
+}
+
+// decodeHEC reads the concatenated JSON events of a HEC request, gzip-compressed or not.
+func decodeHEC(r *http.Request) ([]hecEntry, error) {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	decoder := json.NewDecoder(body)
+	var entries []hecEntry
+	for {
+		var e hecEntry
+		err := decoder.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid event %d: %w", len(entries), err)
+		}
+		if e.Event == nil {
+			return nil, fmt.Errorf("event %d has no event field", len(entries))
+		}
+		entries = append(entries, e)
+	}
+}
+
+// SearchHandler serves the search job endpoints of the management port.
+func (s *SplunkStandIn) SearchHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /services/search/jobs", s.createJob)
+	mux.HandleFunc("POST /services/search/jobs/export", s.export)
+	mux.HandleFunc("GET /services/search/jobs/{sid}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := s.job(r.PathValue("sid"))
+		if !ok {
+			writeMessages(w, http.StatusNotFound, "FATAL", "Unknown sid.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"entry": []any{map[string]any{
+			"name": r.PathValue("sid"),
+			"content": map[string]any{
+				"dispatchState": "DONE",
+				"isDone":        true,
+				"isFailed":      false,
+				"doneProgress":  1,
+				"scanCount":     j.count,
+				"eventCount":    j.count,
+				"resultCount":   j.count,
+				"messages":      []any{},
+			},
+		}}})
+	})
+	results := func(w http.ResponseWriter, r *http.Request) {
+		j, ok := s.job(r.PathValue("sid"))
+		if !ok {
+			writeMessages(w, http.StatusNotFound, "FATAL", "Unknown sid.")
+			return
+		}
+		rows := j.rows
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		if err != nil {
+			// Splunk returns 100 rows unless asked otherwise; 0 means all of them.
+			count = 100
+		}
+		offset = min(max(offset, 0), len(rows))
+		end := len(rows)
+		if count > 0 {
+			end = min(offset+count, len(rows))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"init_offset": offset, "results": rows[offset:end]})
+	}
+	mux.HandleFunc("GET /services/search/jobs/{sid}/events", results)
+	mux.HandleFunc("GET /services/search/jobs/{sid}/results", results)
+	mux.HandleFunc("POST /services/search/jobs/{sid}/control", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		delete(s.jobs, r.PathValue("sid"))
+		s.mu.Unlock()
+		writeMessages(w, http.StatusOK, "INFO", "Search job cancelled.")
+	})
+	return s.authenticate(mux)
+}
+
+func (s *SplunkStandIn) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.Token == "" && s.cfg.Username == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, password, ok := r.BasicAuth()
+		if (s.cfg.Token != "" && r.Header.Get("Authorization") == "Bearer "+s.cfg.Token) ||
+			(s.cfg.Username != "" && ok && user == s.cfg.Username && password == s.cfg.Password) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeMessages(w, http.StatusUnauthorized, "WARN", "call not properly authenticated")
+	})
+}
+
+// standInJob is a finished search job: count is the number of matching rows, of which at
+// most MaxResultRows are kept.
+type standInJob struct {
+	rows  []map[string]any
+	count int
+}
+
+func (s *SplunkStandIn) job(sid string) (standInJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[sid]
+	return j, ok
+}
+
+// createJob evaluates the search right away; the job is done as soon as it exists.
+func (s *SplunkStandIn) createJob(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.evaluateForm(r)
+	if err != nil {
+		writeMessages(w, http.StatusBadRequest, "FATAL", err.Error())
+		return
+	}
+
+	j := standInJob{rows: rows, count: len(rows)}
+	if s.cfg.MaxResultRows > 0 && len(rows) > s.cfg.MaxResultRows {
+		j.rows = rows[:s.cfg.MaxResultRows]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextSID++
+	sid := fmt.Sprintf("standin.%d", s.nextSID)
+	s.jobs[sid] = j
+	writeJSON(w, http.StatusCreated, map[string]any{"sid": sid})
+}
+
+// export streams every row of the search, one JSON object per line like Splunk, without a job
+// and so without the MaxResultRows cap.
+func (s *SplunkStandIn) export(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.evaluateForm(r)
+	if err != nil {
+		writeMessages(w, http.StatusBadRequest, "FATAL", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for i, row := range rows {
+		line := map[string]any{"preview": false, "offset": i, "result": row}
+		if i == len(rows)-1 {
+			line["lastrow"] = true
+		}
+		if err = encoder.Encode(line); err != nil {
+			return
+		}
+	}
+}
+
+// evaluateForm runs the search of a job creation or export request over the stored entries.
+func (s *SplunkStandIn) evaluateForm(r *http.Request) ([]map[string]any, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	earliest, err := parseSplunkTime(r.PostForm.Get("earliest_time"), now)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := parseSplunkTime(r.PostForm.Get("latest_time"), now)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := parseSearch(r.PostForm.Get("search"))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pipeline.evaluate(s.entries, earliest, latest), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeMessages(w http.ResponseWriter, status int, severity, text string) {
+	writeJSON(w, status, map[string]any{"messages": []splunkMessage{{Type: severity, Text: text}}})
+}
+
+var relativeTime = regexp.MustCompile(`^(?:([+-]\d+)(s|sec|secs|m|min|mins|h|hr|hrs|d|day|days|w|week|weeks))?(?:@(s|m|h|d|w))?$`)
+
+// parseSplunkTime parses the time modifiers the tests use: epoch seconds, "now" and relative
+// times such as "-1h@h". An empty modifier means no bound.
+func parseSplunkTime(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "", "0":
+		return time.Time{}, nil
+	case "now":
+		return now, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		whole, fraction := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(fraction*1e9)), nil
+	}
+	m := relativeTime.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("unsupported time modifier %q", value)
+	}
+	t := now
+	if m[1] != "" {
+		amount, _ := strconv.Atoi(m[1])
+		t = t.Add(time.Duration(amount) * timeUnit(m[2]))
+	}
+	if m[3] != "" {
+		unit := timeUnit(m[3])
+		if unit >= 24*time.Hour {
+			year, month, day := t.Date()
+			t = time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+			if unit > 24*time.Hour {
+				t = t.AddDate(0, 0, -int(t.Weekday()))
+			}
+		} else {
+			t = t.Truncate(unit)
+		}
+	}
+	return t, nil
+}
+
+func timeUnit(unit string) time.Duration {
+	switch unit[0] {
+	case 's':
+		return time.Second
+	case 'm':
+		return time.Minute
+	case 'h':
+		return time.Hour
+	case 'd':
+		return 24 * time.Hour
+	default:
+		return 7 * 24 * time.Hour
+	}
+}
+
+// searchTerm is a "field=value" or "field::value" comparison, or a bare word matched against
+// _raw when field is empty. Values may hold * wildcards and match case-insensitively.
+type searchTerm struct {
+	field string
+	value *regexp.Regexp
+}
+
+func (t searchTerm) matches(row map[string]any) bool {
+	if t.field == "" {
+		raw, _ := row["_raw"].(string)
+		return t.value.MatchString(raw)
+	}
+	switch v := row[t.field].(type) {
+	case nil:
+		return false
+	case []any:
+		for _, item := range v {
+			if t.value.MatchString(fmt.Sprint(item)) {
+				return true
+			}
+		}
+		return false
+	default:
+		return t.value.MatchString(fmt.Sprint(v))
+	}
+}
+
+func newSearchTerm(field, value string, anchored bool) (searchTerm, error) {
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, `.*`)
+	if anchored {
+		pattern = "^" + pattern + "$"
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	return searchTerm{field: field, value: re}, err
+}
+
+// searchCommand is one command of a search pipeline.
+type searchCommand struct {
+	name  string
+	terms []searchTerm
+	// index restricts the rows a generating command reads; nil means every index.
+	index []searchTerm
+	// report is set for the mstats and mcatalog reporting commands.
+	report *metricReport
+}
+
+type searchPipeline []searchCommand
+
+// parseSearch parses the subset of SPL the tests use: a leading "search" or "mpreview" command
+// followed by "search" filters, or a single "mstats" or "mcatalog" command. Anything else is
+// rejected like a syntax error.
+func parseSearch(query string) (searchPipeline, error) {
+	segments := splitOutsideQuotes(strings.TrimSpace(query), '|')
+	if len(segments) > 0 && strings.TrimSpace(segments[0]) == "" {
+		segments = segments[1:]
+	} else if len(segments) > 0 {
+		// A query without a leading pipe starts with an implicit search command.
+		segments[0] = "search " + segments[0]
+	}
+	if len(segments) == 0 {
+		return nil, errors.New("empty search")
+	}
+	var pipeline searchPipeline
+	for i, segment := range segments {
+		tokens := splitOutsideQuotes(strings.TrimSpace(segment), ' ')
+		cmd := searchCommand{name: tokens[0]}
+		switch {
+		case (cmd.name == "mstats" || cmd.name == "mcatalog") && len(segments) == 1:
+			report, err := parseMetricReport(cmd.name, tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			cmd.report = report
+			return searchPipeline{cmd}, nil
+		case cmd.name == "mpreview" && i == 0:
+		case cmd.name == "search":
+		default:
+			return nil, fmt.Errorf("unknown or unsupported search command '%s'", cmd.name)
+		}
+		for _, token := range tokens[1:] {
+			if token == "" {
+				continue
+			}
+			field, value, comparison := cutComparison(token)
+			switch {
+			case cmd.name == "mpreview" && field == "filter":
+				filter, err := parseTerms(value)
+				if err != nil {
+					return nil, err
+				}
+				cmd.terms = append(cmd.terms, filter...)
+			case cmd.name == "mpreview" && field != "index":
+				return nil, fmt.Errorf("unsupported mpreview argument '%s'", token)
+			default:
+				term, err := newSearchTerm(field, value, comparison)
+				if err != nil {
+					return nil, err
+				}
+				if field == "index" && i == 0 {
+					cmd.index = append(cmd.index, term)
+				} else {
+					cmd.terms = append(cmd.terms, term)
+				}
+			}
+		}
+		pipeline = append(pipeline, cmd)
+	}
+	return pipeline, nil
+}
+
+func parseTerms(expression string) ([]searchTerm, error) {
+	var terms []searchTerm
+	for _, token := range splitOutsideQuotes(expression, ' ') {
+		if token == "" {
+			continue
+		}
+		field, value, comparison := cutComparison(token)
+		term, err := newSearchTerm(field, value, comparison)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// cutComparison splits a token such as `"k8s.pod.name"=pod` or `customField::value` into its
+// unquoted field and value. A token without a comparison is a bare word.
+func cutComparison(token string) (string, string, bool) {
+	inQuotes := false
+	for i := 0; i < len(token); i++ {
+		switch {
+		case token[i] == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case strings.HasPrefix(token[i:], "::"):
+			return unquote(token[:i]), unquote(token[i+2:]), true
+		case token[i] == '=':
+			return unquote(token[:i]), unquote(token[i+1:]), true
+		}
+	}
+	return "", unquote(token), false
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	}
+	return value
+}
+
+// splitOutsideQuotes splits s on sep, leaving quoted strings intact.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuotes:
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// evaluate runs the pipeline over the stored entries within [earliest, latest), newest first
+// like Splunk.
+func (p searchPipeline) evaluate(entries []hecEntry, earliest, latest time.Time) []map[string]any {
+	generator := p[0]
+	if generator.report != nil {
+		return generator.report.evaluate(entries, earliest, latest)
+	}
+	var rows []map[string]any
+	var times []time.Time
+	for _, e := range entries {
+		if e.isMetric() != (generator.name == "mpreview") {
+			continue
+		}
+		ts := e.timestamp()
+		if (!earliest.IsZero() && ts.Before(earliest)) || (!latest.IsZero() && !ts.Before(latest)) {
+			continue
+		}
+		row := e.row()
+		if !matchesAll(generator.index, row) {
+			continue
+		}
+		rows = append(rows, row)
+		times = append(times, ts)
+	}
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]].After(times[order[j]]) })
+
+	var out []map[string]any
+	for _, i := range order {
+		keep := true
+		for _, cmd := range p {
+			if !matchesAll(cmd.terms, rows[i]) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, rows[i])
+		}
+	}
+	return out
+}
+
+func matchesAll(terms []searchTerm, row map[string]any) bool {
+	for _, term := range terms {
+		if !term.matches(row) {
+			return false
+		}
+	}
+	return true
+}
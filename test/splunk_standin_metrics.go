@@ -0,0 +1,305 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricAggregation is a function of a reporting command, e.g. "avg(_value) AS value".
+type metricAggregation struct {
+	function string
+	argument string
+	as       string
+}
+
+func (a metricAggregation) name() string {
+	if a.as != "" {
+		return a.as
+	}
+	return a.function + "(" + a.argument + ")"
+}
+
+// metricReport is an mstats or mcatalog command:
+//
+//	| mstats avg(_value) [AS value] WHERE index=... [AND field=value ...] [BY field, ...] [span=60s]
+//	| mcatalog values(_dims) [AS dims] WHERE index=... [AND field=value ...] [BY metric_name]
+type metricReport struct {
+	command      string
+	aggregations []metricAggregation
+	where        []searchTerm
+	by           []string
+	span         time.Duration
+}
+
+var (
+	aggregationCall = regexp.MustCompile(`^(\w+)\(([^)]*)\)$`)
+	spanValue       = regexp.MustCompile(`^(\d+)(s|m|h|d)$`)
+)
+
+func parseMetricReport(command string, tokens []string) (*metricReport, error) {
+	report := &metricReport{command: command}
+	clause := "aggregations"
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token == "":
+			continue
+		case strings.EqualFold(token, "WHERE"):
+			clause = "where"
+			continue
+		case strings.EqualFold(token, "BY"):
+			clause = "by"
+			continue
+		case strings.HasPrefix(token, "span="):
+			m := spanValue.FindStringSubmatch(strings.TrimPrefix(token, "span="))
+			if m == nil || command != "mstats" {
+				return nil, fmt.Errorf("unsupported %s argument '%s'", command, token)
+			}
+			amount, _ := strconv.Atoi(m[1])
+			report.span = time.Duration(amount) * timeUnit(m[2])
+			continue
+		}
+		switch clause {
+		case "aggregations":
+			m := aggregationCall.FindStringSubmatch(token)
+			if m == nil {
+				return nil, fmt.Errorf("invalid %s aggregation '%s'", command, token)
+			}
+			aggregation := metricAggregation{function: m[1], argument: m[2]}
+			if err := validateAggregation(command, aggregation); err != nil {
+				return nil, err
+			}
+			if i+2 < len(tokens) && strings.EqualFold(tokens[i+1], "AS") {
+				aggregation.as = unquote(tokens[i+2])
+				i += 2
+			}
+			report.aggregations = append(report.aggregations, aggregation)
+		case "where":
+			if strings.EqualFold(token, "AND") {
+				continue
+			}
+			field, value, comparison := cutComparison(token)
+			if !comparison {
+				return nil, fmt.Errorf("unsupported %s WHERE term '%s'", command, token)
+			}
+			term, err := newSearchTerm(field, value, true)
+			if err != nil {
+				return nil, err
+			}
+			report.where = append(report.where, term)
+		case "by":
+			for _, field := range strings.Split(token, ",") {
+				if field = unquote(strings.TrimSpace(field)); field != "" {
+					report.by = append(report.by, field)
+				}
+			}
+		}
+	}
+	if len(report.aggregations) == 0 {
+		return nil, fmt.Errorf("%s requires an aggregation", command)
+	}
+	return report, nil
+}
+
+func validateAggregation(command string, aggregation metricAggregation) error {
+	if command == "mcatalog" {
+		if aggregation.function != "values" {
+			return fmt.Errorf("unsupported mcatalog function '%s'", aggregation.function)
+		}
+		return nil
+	}
+	switch aggregation.function {
+	case "avg", "sum", "min", "max", "count", "latest":
+	default:
+		return fmt.Errorf("unsupported mstats function '%s'", aggregation.function)
+	}
+	if aggregation.argument != "_value" {
+		return fmt.Errorf("unsupported mstats argument '%s', use _value with a metric_name filter", aggregation.argument)
+	}
+	return nil
+}
+
+// metricDataPoint is one measurement of a HEC metric entry with its dimensions and metadata.
+type metricDataPoint struct {
+	fields     map[string]any
+	dimensions []string
+	value      float64
+	time       time.Time
+}
+
+// metricDataPoints splits the HEC metric entries within [earliest, latest) into data points,
+// one per metric_name:<name> field.
+func metricDataPoints(entries []hecEntry, earliest, latest time.Time) []metricDataPoint {
+	var points []metricDataPoint
+	for _, e := range entries {
+		if !e.isMetric() {
+			continue
+		}
+		ts := e.timestamp()
+		if (!earliest.IsZero() && ts.Before(earliest)) || (!latest.IsZero() && !ts.Before(latest)) {
+			continue
+		}
+		base := map[string]any{"index": e.Index, "sourcetype": e.Sourcetype, "source": e.Source, "host": e.Host}
+		var dimensions []string
+		for key, value := range e.Fields {
+			if !strings.HasPrefix(key, "metric_name:") {
+				base[key] = value
+				dimensions = append(dimensions, key)
+			}
+		}
+		sort.Strings(dimensions)
+		for key, raw := range e.Fields {
+			name, ok := strings.CutPrefix(key, "metric_name:")
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseFloat(fmt.Sprint(raw), 64)
+			if err != nil {
+				continue
+			}
+			fields := make(map[string]any, len(base)+1)
+			for k, v := range base {
+				fields[k] = v
+			}
+			fields["metric_name"] = name
+			points = append(points, metricDataPoint{fields: fields, dimensions: dimensions, value: value, time: ts})
+		}
+	}
+	return points
+}
+
+// evaluate aggregates the data points matching the WHERE clause per BY group and, for mstats
+// with a span, per time bucket.
+func (r *metricReport) evaluate(entries []hecEntry, earliest, latest time.Time) []map[string]any {
+	type group struct {
+		key    string
+		by     []string
+		bucket time.Time
+		points []metricDataPoint
+	}
+	groups := map[string]*group{}
+	for _, point := range metricDataPoints(entries, earliest, latest) {
+		if !matchesAll(r.where, point.fields) {
+			continue
+		}
+		by := make([]string, len(r.by))
+		complete := true
+		for i, field := range r.by {
+			value, ok := point.fields[field]
+			if !ok {
+				complete = false
+				break
+			}
+			by[i] = fmt.Sprint(value)
+		}
+		if !complete {
+			continue
+		}
+		var bucket time.Time
+		if r.span > 0 {
+			bucket = point.time.Truncate(r.span)
+		}
+		key := strings.Join(by, "\x00") + "\x00" + strconv.FormatInt(bucket.UnixNano(), 10)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{key: key, by: by, bucket: bucket}
+			groups[key] = g
+		}
+		g.points = append(g.points, point)
+	}
+
+	ordered := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		ordered = append(ordered, g)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if a, b := strings.Join(ordered[i].by, "\x00"), strings.Join(ordered[j].by, "\x00"); a != b {
+			return a < b
+		}
+		return ordered[i].bucket.Before(ordered[j].bucket)
+	})
+	rows := make([]map[string]any, 0, len(ordered))
+	for _, g := range ordered {
+		row := map[string]any{}
+		for i, field := range r.by {
+			row[field] = g.by[i]
+		}
+		if r.span > 0 {
+			row["_time"] = g.bucket.Format("2006-01-02T15:04:05.000-07:00")
+		}
+		for _, aggregation := range r.aggregations {
+			row[aggregation.name()] = aggregate(aggregation, g.points)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// aggregate computes an aggregation; numbers are rendered as strings, as Splunk does.
+func aggregate(aggregation metricAggregation, points []metricDataPoint) any {
+	if aggregation.function == "values" {
+		seen := map[string]bool{}
+		for _, point := range points {
+			if aggregation.argument == "_dims" {
+				for _, dimension := range point.dimensions {
+					seen[dimension] = true
+				}
+			} else if value, ok := point.fields[aggregation.argument]; ok {
+				seen[fmt.Sprint(value)] = true
+			}
+		}
+		values := make([]string, 0, len(seen))
+		for value := range seen {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		if len(values) == 1 {
+			return values[0]
+		}
+		out := make([]any, len(values))
+		for i, value := range values {
+			out[i] = value
+		}
+		return out
+	}
+
+	var result float64
+	switch aggregation.function {
+	case "count":
+		result = float64(len(points))
+	case "sum", "avg":
+		for _, point := range points {
+			result += point.value
+		}
+		if aggregation.function == "avg" {
+			result /= float64(len(points))
+		}
+	case "min":
+		result = math.Inf(1)
+		for _, point := range points {
+			result = math.Min(result, point.value)
+		}
+	case "max":
+		result = math.Inf(-1)
+		for _, point := range points {
+			result = math.Max(result, point.value)
+		}
+	case "latest":
+		latest := points[0]
+		for _, point := range points[1:] {
+			if !point.time.Before(latest.time) {
+				latest = point
+			}
+		}
+		result = latest.value
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64)
+}
@@ -0,0 +1,232 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startStandIn serves a stand-in with the given HEC payload already received, and returns a
+// search client for it.
+func startStandIn(t *testing.T, payload string) *SearchClient {
+	url := serveStandIn(t, SplunkStandInConfig{}, payload)
+	client, err := NewSearchClient(SearchClientConfig{BaseURL: url, Username: "admin", Password: "secret", InsecureSkipVerify: true})
+	require.NoError(t, err)
+	return client
+}
+
+// serveStandIn serves a stand-in accepting the admin/secret credentials with the given HEC
+// payload already received, and returns the URL of its search API.
+func serveStandIn(t *testing.T, cfg SplunkStandInConfig, payload string) string {
+	cfg.HECToken, cfg.Username, cfg.Password = "hec-token", "admin", "secret"
+	standIn := NewSplunkStandIn(cfg)
+	hec := httptest.NewServer(standIn.HECHandler())
+	t.Cleanup(hec.Close)
+	search := httptest.NewTLSServer(standIn.SearchHandler())
+	t.Cleanup(search.Close)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, err := gz.Write([]byte(payload))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, hec.URL+"/services/collector", &body)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Splunk hec-token")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return search.URL
+}
+
+func TestSplunkStandInSearch(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	ts := func(ago time.Duration) string {
+		return strconv.FormatFloat(float64(now.Add(-ago).UnixMilli())/1000, 'f', 3, 64)
+	}
+	client := startStandIn(t, `{"time":`+ts(time.Minute)+`,"host":"node","source":"/var/log/pods/a","sourcetype":"kube:container:a","index":"pod-anno","event":"first line","fields":{"k8s.pod.labels.app":"pod-w-index","customField":"pod-value-1"}}
+{"time":`+ts(30*time.Second)+`,"host":"node","source":"/var/log/pods/a","sourcetype":"kube:container:a","index":"pod-anno","event":"second line","fields":{"k8s.pod.labels.app":"pod-w-index","customField":"pod-value-2"}}
+{"time":`+ts(10*time.Second)+`,"host":"node","source":"/var/log/pods/b","sourcetype":"kube:container:b","index":"ci_events","event":"other","fields":{"k8s.pod.labels.app":"pod-wo-index"}}
+{"time":`+ts(20*time.Second)+`,"host":"node","source":"kubernetes","sourcetype":"httpevent","index":"ci_metrics","event":"metric","fields":{"metric_name:k8s.pod.cpu.time":1.5,"k8s.pod.name":"pod-for-metric-annotation","k8s.namespace.name":"ns"}}
+{"time":`+ts(5*time.Second)+`,"host":"node","source":"kubernetes","sourcetype":"annotation_sourcetype","index":"test_metrics","event":"metric","fields":{"metric_name:k8s.pod.cpu.time":2.5,"k8s.pod.name":"pod-for-metric-annotation","k8s.namespace.name":"ns"}}
+`)
+
+	testCases := []struct {
+		name     string
+		query    string
+		earliest string
+		expected []string
+	}{
+		{"indexed field", "| search index=pod-anno k8s.pod.labels.app::pod-w-index", "-1h@h", []string{"second line", "first line"}},
+		{"custom field", "| search index=pod-anno k8s.pod.labels.app::pod-w-index customField::pod-value-1", "-1h@h", []string{"first line"}},
+		{"all indexes", "| search index=* k8s.pod.labels.app::pod-wo-index", "-1h@h", []string{"other"}},
+		{"wrong index", "| search index=ci_events k8s.pod.labels.app::pod-w-index", "-1h@h", nil},
+		{"implicit search and raw term", "index=pod-anno SECOND", "-1h@h", []string{"second line"}},
+		{"earliest time", "| search index=pod-anno", strconv.FormatInt(now.Add(-45*time.Second).Unix(), 10), []string{"second line"}},
+		{"metrics are not events", "| search index=ci_metrics", "-1h@h", nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			results, err := client.Search(t.Context(), SearchRequest{Query: testCase.query, Earliest: testCase.earliest})
+			require.NoError(t, err)
+			var raws []string
+			for _, result := range results {
+				raws = append(raws, result.Raw)
+			}
+			require.Equal(t, testCase.expected, raws)
+		})
+	}
+
+	t.Run("mpreview", func(t *testing.T) {
+		t.Parallel()
+		results, err := client.Search(t.Context(), SearchRequest{
+			Query:    `| mpreview index=test_metrics filter="sourcetype=annotation_sourcetype" | search "k8s.namespace.name"=ns "k8s.pod.name"=pod-for-metric-annotation`,
+			Earliest: "-1h@h",
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, "test_metrics", results[0].Index)
+		require.Equal(t, "2.5", results[0].Field("metric_name:k8s.pod.cpu.time"))
+
+		results, err = client.Search(t.Context(), SearchRequest{Query: `| mpreview index=ci_metrics filter="sourcetype=annotation_sourcetype"`, Earliest: "-1h@h"})
+		require.NoError(t, err)
+		require.Empty(t, results)
+	})
+
+	t.Run("unsupported command", func(t *testing.T) {
+		t.Parallel()
+		_, err := client.Search(t.Context(), SearchRequest{Query: "| stats count", Earliest: "-1h@h"})
+		var dispatchErr *DispatchError
+		require.ErrorAs(t, err, &dispatchErr)
+		require.Equal(t, []string{"FATAL: unknown or unsupported search command 'stats'"}, dispatchErr.Messages)
+	})
+}
+
+func TestSearchClientLargeSearches(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	var payload strings.Builder
+	for i := range 250 {
+		fmt.Fprintf(&payload, `{"time":%d,"sourcetype":"kube:container:app","index":"ci_events","event":"line %d"}`+"\n", now.Add(-time.Duration(i)*time.Second).Unix(), i)
+	}
+	newClient := func(url string) *SearchClient {
+		client, err := NewSearchClient(SearchClientConfig{BaseURL: url, Username: "admin", Password: "secret", InsecureSkipVerify: true, PageSize: 100})
+		require.NoError(t, err)
+		return client
+	}
+	req := SearchRequest{Query: "| search index=ci_events", Earliest: "-1h@h"}
+
+	t.Run("paging", func(t *testing.T) {
+		t.Parallel()
+		client := newClient(serveStandIn(t, SplunkStandInConfig{}, payload.String()))
+		events, err := client.Search(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, events, 250)
+		require.Equal(t, "line 0", events[0].Raw)
+		require.Equal(t, "line 249", events[249].Raw)
+
+		status, err := client.Count(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, 250, status.EventCount)
+		require.Equal(t, 250, status.ResultCount)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		client := newClient(serveStandIn(t, SplunkStandInConfig{MaxResultRows: 120}, payload.String()))
+		events, err := client.Search(t.Context(), req)
+		var truncatedErr *TruncatedError
+		require.ErrorAs(t, err, &truncatedErr)
+		require.Equal(t, 250, truncatedErr.Expected)
+		require.Equal(t, 120, truncatedErr.Received)
+		require.Len(t, events, 120)
+
+		// The export endpoint keeps no job, so it is not truncated.
+		var exported []string
+		require.NoError(t, client.Export(t.Context(), req, func(result SearchResult) error {
+			exported = append(exported, result.Raw)
+			return nil
+		}))
+		require.Len(t, exported, 250)
+		require.Equal(t, "line 0", exported[0])
+
+		stop := errors.New("stop")
+		seen := 0
+		err = client.Export(t.Context(), req, func(SearchResult) error {
+			seen++
+			if seen == 10 {
+				return stop
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, stop)
+		require.Equal(t, 10, seen)
+
+		err = client.Export(t.Context(), SearchRequest{Query: "| stats count"}, func(SearchResult) error { return nil })
+		var dispatchErr *DispatchError
+		require.ErrorAs(t, err, &dispatchErr)
+	})
+}
+
+func TestSplunkStandInRejectsBadTokens(t *testing.T) {
+	t.Parallel()
+
+	standIn := NewSplunkStandIn(SplunkStandInConfig{HECToken: "hec-token"})
+	hec := httptest.NewServer(standIn.HECHandler())
+	t.Cleanup(hec.Close)
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, hec.URL+"/services/collector/event", bytes.NewBufferString(`{"event":"x"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Splunk wrong")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	events, metrics := standIn.Events()
+	require.Zero(t, events+metrics)
+}
+
+func TestParseSplunkTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 12, 15, 47, 30, 0, time.UTC)
+	testCases := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"", time.Time{}},
+		{"now", now},
+		{"1773330000", time.Unix(1773330000, 0)},
+		{"-1h@h", time.Date(2026, 3, 12, 14, 0, 0, 0, time.UTC)},
+		{"-15m", now.Add(-15 * time.Minute)},
+		{"@d", time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{"-1d@w", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.value, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := parseSplunkTime(testCase.value, now)
+			require.NoError(t, err)
+			require.True(t, testCase.expected.Equal(parsed), "got %s", parsed)
+		})
+	}
+	_, err := parseSplunkTime("yesterday", now)
+	require.EqualError(t, err, `unsupported time modifier "yesterday"`)
+}
@@ -0,0 +1,258 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	chartPath     string = "helm-charts/splunk-otel-collector/Chart.yaml"
+	readmePath    string = "README.md"
+	upgradingPath string = "UPGRADING.md"
+	// supportSection is the README section stating which Kubernetes versions the chart supports.
+	supportSection string = "## Supported Kubernetes distributions"
+)
+
+var (
+	kubeVersionLine = regexp.MustCompile(`(?m)^kubeVersion:.*$`)
+	appVersionLine  = regexp.MustCompile(`(?m)^appVersion:.*$`)
+	// docsVersion matches the Kubernetes versions the docs state, e.g. "Kubernetes 1.33 and newer"
+	// or "Kubernetes versions 1.33 to 1.36"; bare numbers are chart or collector versions.
+	docsVersion = regexp.MustCompile(`(?i)\bkubernetes\s+(?:versions?\s+)?v?(1\.\d+)\b`)
+)
+
+// supportedMinors returns the minor versions that are supported upstream or by a managed
+// Kubernetes service, as the chart has to install on both.
+func supportedMinors(k8sVersions []KubernetesVersion, managedVersions map[string][]string) []string {
+	seen := map[string]bool{}
+	var minors []string
+	add := func(version string) {
+		m := minorVersion.FindStringSubmatch(version)
+		if m == nil {
+			return
+		}
+		minor := m[1] + "." + m[2]
+		if !seen[minor] {
+			seen[minor] = true
+			minors = append(minors, minor)
+		}
+	}
+	for _, v := range k8sVersions {
+		add(v.Cycle)
+	}
+	for _, versions := range managedVersions {
+		for _, v := range versions {
+			add(v)
+		}
+	}
+	sortMinorVersions(minors)
+	return minors
+}
+
+// kubeVersionRange returns the Chart.yaml kubeVersion constraint admitting every supported minor
+// version, e.g. >=1.33.0-0. It has no upper bound so that new releases install before the next
+// update, and the -0 suffix admits the pre-release versions of the cloud providers, e.g.
+// v1.33.4-eks-1234.
+func kubeVersionRange(minors []string) string {
+	if len(minors) == 0 {
+		return ""
+	}
+	return ">=" + minors[len(minors)-1] + ".0-0"
+}
+
+// readChartKubeVersion returns the kubeVersion constraint of the chart, or "" when it has none.
+func readChartKubeVersion(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	line := kubeVersionLine.Find(content)
+	if line == nil {
+		return "", nil
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(string(line), "kubeVersion:"))
+	return strings.Trim(value, `"'`), nil
+}
+
+// updateChartKubeVersion sets the kubeVersion constraint of the chart, after appVersion when the
+// chart has none yet. The rest of the file is kept as is.
+func updateChartKubeVersion(path, constraint string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	chart := string(content)
+	line := fmt.Sprintf("kubeVersion: %q", constraint)
+	if kubeVersionLine.MatchString(chart) {
+		chart = kubeVersionLine.ReplaceAllLiteralString(chart, line)
+	} else {
+		loc := appVersionLine.FindStringIndex(chart)
+		if loc == nil {
+			return fmt.Errorf("no appVersion in %s", path)
+		}
+		chart = chart[:loc[1]] + "\n" + line + chart[loc[1]:]
+	}
+	if err = os.WriteFile(path, []byte(chart), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to write updated file: %w", err)
+	}
+	return nil
+}
+
+// checkChartKubeVersion returns a warning for every supported minor version the constraint does
+// not admit, and when it admits the minor version before the oldest supported one.
+func checkChartKubeVersion(constraint string, minors []string) ([]string, error) {
+	if len(minors) == 0 {
+		return nil, nil
+	}
+	if constraint == "" {
+		return []string{fmt.Sprintf("the chart does not set kubeVersion, the supported range is %q", kubeVersionRange(minors))}, nil
+	}
+	var warnings []string
+	for _, minor := range minors {
+		admitted, err := admitsMinor(constraint, minor)
+		if err != nil {
+			return nil, err
+		}
+		if !admitted {
+			warnings = append(warnings, fmt.Sprintf("kubeVersion %q does not admit the supported Kubernetes %s", constraint, minor))
+		}
+	}
+	if eol, ok := previousMinor(minors[len(minors)-1]); ok {
+		admitted, err := admitsMinor(constraint, eol)
+		if err != nil {
+			return nil, err
+		}
+		if admitted {
+			warnings = append(warnings, fmt.Sprintf("kubeVersion %q admits Kubernetes %s, which is end of life", constraint, eol))
+		}
+	}
+	return warnings, nil
+}
+
+// checkSupportDocs returns warnings when the Kubernetes versions the text extracted from the doc
+// states do not start at the oldest supported minor version, or when it states none although
+// requireStatement is set.
+func checkSupportDocs(path string, extract func(string) string, requireStatement bool, minors []string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(minors) == 0 {
+		return nil, nil
+	}
+	var mentioned []string
+	for _, m := range docsVersion.FindAllStringSubmatch(extract(string(content)), -1) {
+		mentioned = append(mentioned, m[1])
+	}
+	name := filepath.Base(path)
+	oldest := minors[len(minors)-1]
+	if len(mentioned) == 0 {
+		if requireStatement {
+			return []string{fmt.Sprintf("%s states no supported Kubernetes version, the oldest supported one is %s", name, oldest)}, nil
+		}
+		return nil, nil
+	}
+	sortMinorVersions(mentioned)
+	var warnings []string
+	for _, m := range mentioned {
+		if compareMinors(m, oldest) < 0 {
+			warnings = append(warnings, fmt.Sprintf("%s claims support for Kubernetes %s, which is end of life", name, m))
+		}
+	}
+	if lowest := mentioned[len(mentioned)-1]; compareMinors(lowest, oldest) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s claims support from Kubernetes %s only, but %s is still supported", name, lowest, oldest))
+	}
+	return warnings, nil
+}
+
+// readmeSupportText returns the README section stating which Kubernetes versions the chart
+// supports.
+func readmeSupportText(content string) string {
+	_, section, found := strings.Cut(content, supportSection+"\n")
+	if !found {
+		return ""
+	}
+	return sectionBody(section)
+}
+
+// upgradingNotesText returns the upgrade notes of the latest release, the first section of
+// UPGRADING.md. The older sections state what was supported at the time and are not checked.
+func upgradingNotesText(content string) string {
+	_, section, found := strings.Cut(content, "\n## ")
+	if !found {
+		return ""
+	}
+	return sectionBody(section)
+}
+
+// sectionBody cuts text starting inside a level 2 section at the next one.
+func sectionBody(text string) string {
+	if end := strings.Index(text, "\n## "); end >= 0 {
+		return text[:end]
+	}
+	return text
+}
+
+// admitsMinor reports whether a patch version of minor satisfies the constraint. The constraint
+// uses the Helm syntax, which Helm evaluates with the same semver package.
+func admitsMinor(constraint, minor string) (bool, error) {
+	major, minorNumber, err := splitMinor(minor)
+	if err != nil {
+		return false, err
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, fmt.Errorf("invalid kubeVersion %q: %w", constraint, err)
+	}
+	for _, patch := range []uint64{0, 999} {
+		if c.Check(semver.New(uint64(major), uint64(minorNumber), patch, "", "")) { //nolint:gosec
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareMinors(a, b string) int {
+	aMajor, aMinor, _ := splitMinor(a)
+	bMajor, bMinor, _ := splitMinor(b)
+	return compareVersions([3]int{aMajor, aMinor}, [3]int{bMajor, bMinor})
+}
+
+func splitMinor(minor string) (int, int, error) {
+	m := minorVersion.FindStringSubmatch(minor)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid minor version %q", minor)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minorNumber, _ := strconv.Atoi(m[2])
+	return major, minorNumber, nil
+}
+
+func previousMinor(minor string) (string, bool) {
+	major, minorNumber, err := splitMinor(minor)
+	if err != nil || minorNumber == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%d.%d", major, minorNumber-1), true
+}
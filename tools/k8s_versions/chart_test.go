@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChart = `apiVersion: v2
+name: splunk-otel-collector
+version: 0.158.0
+appVersion: 0.158.0
+description: Splunk OpenTelemetry Collector for Kubernetes
+`
+
+func Test_SupportedMinors_UpstreamAndManaged_ReturnsUnionDescending(t *testing.T) {
+	k8sVersions := []KubernetesVersion{{Cycle: "1.36"}, {Cycle: "1.35"}, {Cycle: "1.34"}}
+	managed := map[string][]string{"eks": {"1.35", "1.34", "1.33"}, "aks": {"1.36", "1.34"}}
+
+	minors := supportedMinors(k8sVersions, managed)
+	assert.Equal(t, []string{"1.36", "1.35", "1.34", "1.33"}, minors)
+	assert.Equal(t, ">=1.33.0-0", kubeVersionRange(minors))
+}
+
+func Test_CheckChartKubeVersion_Constraint_ReturnsWarnings(t *testing.T) {
+	minors := []string{"1.36", "1.35", "1.34", "1.33"}
+	tests := []struct {
+		constraint string
+		expected   []string
+	}{
+		{">=1.33.0-0", nil},
+		{">= 1.33", nil},
+		{">=1.32.0-0", []string{`kubeVersion ">=1.32.0-0" admits Kubernetes 1.32, which is end of life`}},
+		{">=1.34.0-0", []string{`kubeVersion ">=1.34.0-0" does not admit the supported Kubernetes 1.33`}},
+		{">=1.33.0-0 <1.36.0-0", []string{`kubeVersion ">=1.33.0-0 <1.36.0-0" does not admit the supported Kubernetes 1.36`}},
+		{">=1.33.0-0, <1.36.0-0 || >=1.36.0-0", nil},
+		{"^1.33", nil},
+		{"1.33 - 1.36", nil},
+		{"~1.33", []string{
+			`kubeVersion "~1.33" does not admit the supported Kubernetes 1.36`,
+			`kubeVersion "~1.33" does not admit the supported Kubernetes 1.35`,
+			`kubeVersion "~1.33" does not admit the supported Kubernetes 1.34`,
+		}},
+		{"1.33.x || 1.34.x || 1.35.x || 1.36.x", nil},
+		{"", []string{`the chart does not set kubeVersion, the supported range is ">=1.33.0-0"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			warnings, err := checkChartKubeVersion(tt.constraint, minors)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, warnings)
+		})
+	}
+}
+
+func Test_CheckChartKubeVersion_InvalidConstraint_ReturnsError(t *testing.T) {
+	_, err := checkChartKubeVersion(">=one.33", []string{"1.33"})
+	require.ErrorContains(t, err, `invalid kubeVersion ">=one.33"`)
+}
+
+func Test_UpdateChartKubeVersion_KeepsTheRestOfTheChart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Chart.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testChart), 0o600))
+
+	require.NoError(t, updateChartKubeVersion(path, ">=1.33.0-0"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: v2
+name: splunk-otel-collector
+version: 0.158.0
+appVersion: 0.158.0
+kubeVersion: ">=1.33.0-0"
+description: Splunk OpenTelemetry Collector for Kubernetes
+`, string(content))
+
+	require.NoError(t, updateChartKubeVersion(path, ">=1.34.0-0"))
+	constraint, err := readChartKubeVersion(path)
+	require.NoError(t, err)
+	assert.Equal(t, ">=1.34.0-0", constraint)
+}
+
+func Test_CheckSupportDocs_MentionedVersions_ReturnsWarnings(t *testing.T) {
+	minors := []string{"1.36", "1.35", "1.34", "1.33"}
+	tests := []struct {
+		name     string
+		section  string
+		expected []string
+	}{
+		{"no versions", "Use actively supported versions.\n", []string{"README.md states no supported Kubernetes version, the oldest supported one is 1.33"}},
+		{"oldest supported", "Kubernetes 1.33 and newer are supported.\n", nil},
+		{"end of life", "Kubernetes 1.31 and newer are supported.\n", []string{"README.md claims support for Kubernetes 1.31, which is end of life"}},
+		{"missing supported", "Kubernetes versions 1.34 to 1.36 are supported.\n", []string{"README.md claims support from Kubernetes 1.34 only, but 1.33 is still supported"}},
+		{"chart versions", "Chart 0.158.0 requires collector 1.20 features.\n", []string{"README.md states no supported Kubernetes version, the oldest supported one is 1.33"}},
+		{"other sections", "Kubernetes 1.33 and newer are supported.\n\n## Upgrading\n\nKubernetes 1.20 stopped having active support.\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "README.md")
+			require.NoError(t, os.WriteFile(path, []byte("# Chart\n\n"+supportSection+"\n\n"+tt.section), 0o600))
+			warnings, err := checkSupportDocs(path, readmeSupportText, true, minors)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, warnings)
+		})
+	}
+}
+
+func Test_CheckSupportDocs_Upgrading_ChecksLatestNotesOnly(t *testing.T) {
+	minors := []string{"1.36", "1.35", "1.34", "1.33"}
+	path := filepath.Join(t.TempDir(), "UPGRADING.md")
+	upgrading := "# Upgrade guidelines\n\n## 0.158.0 to 0.159.0\n\nThe chart now requires Kubernetes 1.32 or newer.\n\n" +
+		"## 0.44.1 to 0.45.0\n\nKubernetes 1.20 is no longer supported.\n"
+	require.NoError(t, os.WriteFile(path, []byte(upgrading), 0o600))
+
+	warnings, err := checkSupportDocs(path, upgradingNotesText, false, minors)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"UPGRADING.md claims support for Kubernetes 1.32, which is end of life"}, warnings)
+
+	// The upgrade notes only state versions when the supported ones change.
+	require.NoError(t, os.WriteFile(path, []byte("# Upgrade guidelines\n\n## 0.158.0 to 0.159.0\n\nA value was renamed.\n"), 0o600))
+	warnings, err = checkSupportDocs(path, upgradingNotesText, false, minors)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func Test_CheckSupportDocs_RepositoryDocs_ReturnNoErrors(t *testing.T) {
+	minors := []string{"1.36", "1.35", "1.34", "1.33"}
+	for _, doc := range []struct {
+		path    string
+		extract func(string) string
+	}{
+		{filepath.Join("..", "..", readmePath), readmeSupportText},
+		{filepath.Join("..", "..", upgradingPath), upgradingNotesText},
+	} {
+		_, err := checkSupportDocs(doc.path, doc.extract, true, minors)
+		require.NoError(t, err)
+	}
+}
+
+func Test_Run_UpdateChart_SetsSupportedRange(t *testing.T) {
+	chart := filepath.Join(t.TempDir(), "Chart.yaml")
+	require.NoError(t, os.WriteFile(chart, []byte(testChart), 0o600))
+	cfg := defaultConfig().withFixtures(filepath.Join("testdata", "fixtures"))
+	cfg.now = func() time.Time { return time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, run(cfg, options{matrixPath: copyMatrix(t), chartPath: chart, updateChart: true}))
+	constraint, err := readChartKubeVersion(chart)
+	require.NoError(t, err)
+	// Upstream supports 1.34 and newer, the managed services still support 1.33.
+	assert.Equal(t, ">=1.33.0-0", constraint)
+}
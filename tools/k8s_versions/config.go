@@ -0,0 +1,117 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// config holds where the tool reads its data from, so that the whole pipeline can run against
+// local stand-ins or recorded responses, and for any date.
+type config struct {
+	endOfLifeURL string
+	// kindTagsURL is a Docker Hub tags query the tag name is appended to.
+	kindTagsURL string
+	minikubeURL string
+	// versionSources read the supported versions of managed Kubernetes services.
+	versionSources []versionSource
+	client         *http.Client
+	now            func() time.Time
+}
+
+func defaultConfig() config {
+	return config{
+		endOfLifeURL:   endOfLifeURL,
+		kindTagsURL:    kindDockerHubURL,
+		minikubeURL:    miniKubeURL,
+		versionSources: defaultVersionSources(),
+		client:         &http.Client{Timeout: time.Minute},
+		now:            time.Now,
+	}
+}
+
+// withFixtures serves every request from the responses recorded in dir, see fixturePath.
+func (c config) withFixtures(dir string) config {
+	c.client = &http.Client{Transport: fixtureTransport{dir: dir}}
+	return c
+}
+
+// withRecording saves every successful response into dir, in the layout withFixtures reads.
+func (c config) withRecording(dir string) config {
+	transport := c.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.client = &http.Client{Timeout: c.client.Timeout, Transport: recordingTransport{dir: dir, next: transport}}
+	return c
+}
+
+// fixturePath maps a URL to its recorded response: the host and the path, followed for the
+// Docker Hub tags query by the tag name, e.g.
+// hub.docker.com/v2/repositories/kindest/node/tags/1.34.0.json.
+func fixturePath(dir string, u *url.URL) string {
+	path := filepath.Join(dir, u.Host, filepath.FromSlash(u.Path))
+	if name := u.Query().Get("name"); name != "" {
+		path = filepath.Join(path, name+".json")
+	}
+	return path
+}
+
+type fixtureTransport struct {
+	dir string
+}
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := fixturePath(t.dir, req.URL)
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture %s for %s", path, req.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	path := fixturePath(t.dir, req.URL)
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", req.URL, err)
+	}
+	if err = os.WriteFile(path, body, 0o644); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("failed to record %s: %w", req.URL, err)
+	}
+	logDebug("Recorded %s to %s", req.URL, strings.TrimPrefix(path, t.dir+string(filepath.Separator)))
+	return resp, nil
+}
@@ -2,7 +2,10 @@ module k8sVersions
 
 go 1.26.6
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/Masterminds/semver/v3 v3.5.0
+	github.com/stretchr/testify v1.10.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,186 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	managedVersionsKey string = "managed_k8s_versions"
+	endOfLifeAPIURL    string = "https://endoflife.date/api/"
+)
+
+// versionSource reads the supported minor versions of a managed Kubernetes service from the
+// release data of its provider.
+type versionSource interface {
+	// provider is the managed service, matching the first part of KUBE_TEST_ENV, e.g. eks.
+	provider() string
+	supportedVersions(cfg config) ([]string, error)
+}
+
+func defaultVersionSources() []versionSource {
+	return []versionSource{
+		endOfLifeSource{name: "aks", url: endOfLifeAPIURL + "azure-kubernetes-service.json"},
+		endOfLifeSource{name: "eks", url: endOfLifeAPIURL + "amazon-eks.json"},
+		endOfLifeSource{name: "gke", url: endOfLifeAPIURL + "google-kubernetes-engine.json"},
+	}
+}
+
+// endOfLifeSource reads the release cycles endoflife.date tracks for a product. A cycle is
+// supported until its eol date, or its extendedSupport date when it is later.
+type endOfLifeSource struct {
+	name string
+	url  string
+}
+
+func (s endOfLifeSource) provider() string {
+	return s.name
+}
+
+func (s endOfLifeSource) supportedVersions(cfg config) ([]string, error) {
+	body, err := getRequestBody(cfg.client, s.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s versions: %w", s.name, err)
+	}
+	return parseEndOfLifeCycles(body, cfg.now())
+}
+
+// endOfLifeCycle is a release cycle of endoflife.date. The eol field is a date or a boolean
+// telling whether the cycle reached it; extendedSupport is a date or a boolean telling whether
+// the cycle has extended support at all.
+type endOfLifeCycle struct {
+	Cycle           string          `json:"cycle"`
+	EOL             json.RawMessage `json:"eol"`
+	ExtendedSupport json.RawMessage `json:"extendedSupport"`
+}
+
+func parseEndOfLifeCycles(body []byte, now time.Time) ([]string, error) {
+	var cycles []endOfLifeCycle
+	if err := json.Unmarshal(body, &cycles); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	var versions []string
+	for _, cycle := range cycles {
+		supported, err := cycle.supported(now)
+		if err != nil {
+			return nil, fmt.Errorf("cycle %s: %w", cycle.Cycle, err)
+		}
+		if supported {
+			versions = append(versions, cycle.Cycle)
+		} else {
+			logDebug("Skipping version %s, out of support", cycle.Cycle)
+		}
+	}
+	sortMinorVersions(versions)
+	return versions, nil
+}
+
+func (c endOfLifeCycle) supported(now time.Time) (bool, error) {
+	var ended bool
+	if err := json.Unmarshal(c.EOL, &ended); err != nil {
+		eol, dateErr := parseEndOfLifeDate(c.EOL)
+		if dateErr != nil {
+			return false, dateErr
+		}
+		ended = !eol.After(now)
+	}
+	if !ended {
+		return true, nil
+	}
+	var hasExtendedSupport bool
+	if len(c.ExtendedSupport) == 0 || json.Unmarshal(c.ExtendedSupport, &hasExtendedSupport) == nil {
+		return false, nil
+	}
+	extendedSupport, err := parseEndOfLifeDate(c.ExtendedSupport)
+	if err != nil {
+		return false, err
+	}
+	return extendedSupport.After(now), nil
+}
+
+func parseEndOfLifeDate(field json.RawMessage) (time.Time, error) {
+	var date string
+	if err := json.Unmarshal(field, &date); err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %s", field)
+	}
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing date: %w", err)
+	}
+	return day, nil
+}
+
+// getManagedKubernetesVersions returns the supported minor versions of every source, by
+// provider. Providers without supported versions are left out.
+func getManagedKubernetesVersions(cfg config) (map[string][]string, error) {
+	managed := map[string][]string{}
+	for _, source := range cfg.versionSources {
+		versions, err := source.supportedVersions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			logDebug("No supported versions found for %s", source.provider())
+			continue
+		}
+		managed[source.provider()] = versions
+	}
+	return managed, nil
+}
+
+var minorVersion = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+// sortMinorVersions sorts versions such as 1.35 in descending order, numerically.
+func sortMinorVersions(versions []string) {
+	key := func(version string) [2]int {
+		m := minorVersion.FindStringSubmatch(version)
+		if m == nil {
+			return [2]int{}
+		}
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		return [2]int{major, minor}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := key(versions[i]), key(versions[j])
+		if a[0] != b[0] {
+			return a[0] > b[0]
+		}
+		return a[1] > b[1]
+	})
+}
+
+// checkManagedVersion returns an error when the Kubernetes version of a cloud test environment
+// is not in the supported versions the matrix file tracks for its provider. Environments of
+// untracked providers, e.g. rosa or gce, are not checked.
+func checkManagedVersion(matrixPath, env, version string) error {
+	matrix, err := readCIMatrix(matrixPath, false)
+	if err != nil {
+		return err
+	}
+	provider, _, _ := strings.Cut(env, "/")
+	supported, ok := matrix.ManagedVersions[provider]
+	if !ok {
+		logDebug("No supported versions tracked for %s, skipping %s", provider, env)
+		return nil
+	}
+	m := minorVersion.FindStringSubmatch(version)
+	if m == nil {
+		return fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+	minor := m[1] + "." + m[2]
+	for _, v := range supported {
+		if v == minor {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s runs Kubernetes %s, which %s no longer supports (supported: %s)", env, minor, provider, strings.Join(supported, ", "))
+}
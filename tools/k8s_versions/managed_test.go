@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseEndOfLifeCycles_EOLAndExtendedSupport_ReturnsSupportedVersions(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{"eol date", `[{"cycle":"1.34","eol":"2026-12-02"},{"cycle":"1.33","eol":"2026-10-19"}]`, []string{"1.34"}},
+		{"eol boolean", `[{"cycle":"1.36","eol":false},{"cycle":"1.30","eol":true}]`, []string{"1.36"}},
+		{"extended support date", `[{"cycle":"1.33","eol":"2026-07-29","extendedSupport":"2027-07-29"},{"cycle":"1.32","eol":"2026-03-23","extendedSupport":"2026-09-23"}]`, []string{"1.33"}},
+		{"no extended support", `[{"cycle":"1.33","eol":"2026-07-29","extendedSupport":false},{"cycle":"1.32","eol":"2026-04-11","extendedSupport":null},{"cycle":"1.31","eol":"2026-01-11"}]`, nil},
+		{"sorted numerically", `[{"cycle":"1.9","eol":false},{"cycle":"1.10","eol":false},{"cycle":"2.0","eol":false}]`, []string{"2.0", "1.10", "1.9"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, err := parseEndOfLifeCycles([]byte(tt.body), now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, versions)
+		})
+	}
+}
+
+func Test_ParseEndOfLifeCycles_InvalidDate_ReturnsError(t *testing.T) {
+	_, err := parseEndOfLifeCycles([]byte(`[{"cycle":"1.33","eol":"July 2026"}]`), time.Now())
+	require.ErrorContains(t, err, "cycle 1.33")
+}
+
+func Test_CheckManagedVersion_ClusterVersion_ReturnsErrorWhenUnsupported(t *testing.T) {
+	matrixPath := filepath.Join(t.TempDir(), "ci-matrix.json")
+	require.NoError(t, os.WriteFile(matrixPath, []byte(`{"managed_k8s_versions":{"eks":["1.35","1.34","1.33"]},"functional_test_v3":{}}`), 0o600))
+	tests := []struct {
+		env         string
+		version     string
+		expectedErr string
+	}{
+		{"eks", "v1.34.2-eks-b707fbb", ""},
+		{"eks/auto-mode", "v1.33.5-eks-3025e55", ""},
+		{"eks/fargate", "v1.32.9-eks-113cf36", "eks/fargate runs Kubernetes 1.32, which eks no longer supports (supported: 1.35, 1.34, 1.33)"},
+		{"eks", "unknown", `invalid Kubernetes version "unknown"`},
+		{"rosa", "v1.28.3", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+" "+tt.version, func(t *testing.T) {
+			err := checkManagedVersion(matrixPath, tt.env, tt.version)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
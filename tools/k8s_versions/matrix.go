@@ -0,0 +1,320 @@
+// Copyright Splunk Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const testJob string = "test-job"
+
+// ciMatrix is the content of ci-matrix.json. The fields are in the order of the file so that
+// rewriting it only changes the updated versions.
+type ciMatrix struct {
+	FunctionalTest   minikubeSuite       `json:"functional_test"`
+	FunctionalTestV2 kindSuite           `json:"functional_test_v2"`
+	KubeconformTests kubeconformSuite    `json:"kubeconform_tests"`
+	ManagedVersions  map[string][]string `json:"managed_k8s_versions,omitempty"`
+	MigrationTests   migrationSuite      `json:"migration_tests"`
+}
+
+type minikubeSuite struct {
+	ContainerRuntime []string `json:"container_runtime"`
+	MinikubeVersions []string `json:"k8s-minikube-version"`
+	SplunkVersions   []string `json:"splunk_version"`
+}
+
+type kindSuite struct {
+	KindVersions []string `json:"k8s-kind-version"`
+	TestJobs     []string `json:"test-job"`
+	// Exclude are the GitHub matrix exclude rules, e.g. {"test-job": "istio", "k8s-kind-version": "v1.34.8"}.
+	Exclude []map[string]string `json:"exclude"`
+}
+
+type kubeconformSuite struct {
+	K8sVersions []string `json:"k8s-version"`
+}
+
+type migrationSuite struct {
+	KindVersions []string `json:"k8s-kind-version"`
+}
+
+type listOrder int
+
+const (
+	// unordered lists only have to be unique.
+	unordered listOrder = iota
+	ascending
+	descending
+)
+
+// matrixList is a list of the matrix, named after its suite and key.
+type matrixList struct {
+	suite  string
+	key    string
+	values []string
+	order  listOrder
+}
+
+// lists returns the lists of the matrix in file order. Only the ordered lists are versions.
+func (m ciMatrix) lists() []matrixList {
+	lists := []matrixList{
+		{"functional_test", "container_runtime", m.FunctionalTest.ContainerRuntime, unordered},
+		{"functional_test", kubeMinikubeVersion, m.FunctionalTest.MinikubeVersions, descending},
+		{"functional_test", "splunk_version", m.FunctionalTest.SplunkVersions, descending},
+		{"functional_test_v2", kubeKindVersion, m.FunctionalTestV2.KindVersions, descending},
+		{"functional_test_v2", testJob, m.FunctionalTestV2.TestJobs, unordered},
+		{"kubeconform_tests", "k8s-version", m.KubeconformTests.K8sVersions, ascending},
+	}
+	providers := make([]string, 0, len(m.ManagedVersions))
+	for provider := range m.ManagedVersions {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		lists = append(lists, matrixList{managedVersionsKey, provider, m.ManagedVersions[provider], descending})
+	}
+	return append(lists, matrixList{"migration_tests", kubeKindVersion, m.MigrationTests.KindVersions, descending})
+}
+
+// readCIMatrix reads the matrix file. Strict reading rejects unknown keys, so that the update
+// does not silently drop the lists it does not know about when it writes the file back; the
+// read-only checks of the cloud test jobs tolerate them.
+func readCIMatrix(path string, strict bool) (ciMatrix, error) {
+	var m ciMatrix
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return m, fmt.Errorf("failed to read file: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err = decoder.Decode(&m); err != nil {
+		return m, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return m, nil
+}
+
+func writeCIMatrix(path string, m ciMatrix) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated JSON: %w", err)
+	}
+	// Ensure the file ends with a new line to make the pre-commit check happy
+	content = append(content, '\n')
+	if err = os.WriteFile(path, content, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("failed to write updated file: %w", err)
+	}
+	return nil
+}
+
+// withVersions returns a copy of the matrix listing the given versions. Empty versions are left
+// as they are, and the exclude rules of dropped kind versions are removed.
+func (m ciMatrix) withVersions(kindVersions, minikubeVersions []string, managedVersions map[string][]string) ciMatrix {
+	updated := m
+	if len(kindVersions) > 0 {
+		updated.FunctionalTestV2.KindVersions = kindVersions
+		updated.MigrationTests.KindVersions = kindVersions
+		exclude := make([]map[string]string, 0, len(m.FunctionalTestV2.Exclude))
+		for _, rule := range m.FunctionalTestV2.Exclude {
+			if version, ok := rule[kubeKindVersion]; ok && !slices.Contains(kindVersions, version) {
+				logDebug("Removing the exclude rule %v of the dropped kind version %s", rule, version)
+				continue
+			}
+			exclude = append(exclude, rule)
+		}
+		updated.FunctionalTestV2.Exclude = exclude
+	}
+	if len(minikubeVersions) > 0 {
+		updated.FunctionalTest.MinikubeVersions = minikubeVersions
+	}
+	if len(managedVersions) > 0 {
+		updated.ManagedVersions = managedVersions
+	}
+	return updated
+}
+
+// matrixChecks are the repository facts the matrix is validated against. Checks with an empty
+// value are skipped.
+type matrixChecks struct {
+	// suitesDir is the functional_tests directory, which has a directory per test-job.
+	suitesDir string
+	// kindTags are the published kindest/node tags.
+	kindTags []string
+}
+
+// validate returns an error listing every problem of the matrix: duplicate or unsorted
+// versions, test jobs without a test suite, kind versions without an image and exclude rules
+// not matching the suite.
+func (m ciMatrix) validate(checks matrixChecks) error {
+	var errs []error
+	for _, list := range m.lists() {
+		name := list.suite + "." + list.key
+		if len(list.values) == 0 {
+			errs = append(errs, fmt.Errorf("%s is empty", name))
+		}
+		seen := map[string]bool{}
+		for i, value := range list.values {
+			if seen[value] {
+				errs = append(errs, fmt.Errorf("%s lists %s more than once", name, value))
+			}
+			seen[value] = true
+			if i == 0 || list.order == unordered {
+				continue
+			}
+			c := compareVersionStrings(list.values[i-1], value)
+			if (list.order == descending && c < 0) || (list.order == ascending && c > 0) {
+				errs = append(errs, fmt.Errorf("%s is not sorted: %s is listed before %s", name, list.values[i-1], value))
+			}
+		}
+	}
+
+	if checks.suitesDir != "" {
+		for _, job := range m.FunctionalTestV2.TestJobs {
+			if info, err := os.Stat(filepath.Join(checks.suitesDir, job)); err != nil || !info.IsDir() {
+				errs = append(errs, fmt.Errorf("functional_test_v2.%s %s has no test suite in %s", testJob, job, checks.suitesDir))
+			}
+		}
+	}
+	if checks.kindTags != nil {
+		kindSuites := map[string][]string{"functional_test_v2": m.FunctionalTestV2.KindVersions, "migration_tests": m.MigrationTests.KindVersions}
+		for _, suite := range []string{"functional_test_v2", "migration_tests"} {
+			for _, version := range kindSuites[suite] {
+				if !slices.Contains(checks.kindTags, version) {
+					errs = append(errs, fmt.Errorf("%s.%s %s is not a kindest/node tag", suite, kubeKindVersion, version))
+				}
+			}
+		}
+	}
+
+	allowed := map[string][]string{kubeKindVersion: m.FunctionalTestV2.KindVersions, testJob: m.FunctionalTestV2.TestJobs}
+	for _, rule := range m.FunctionalTestV2.Exclude {
+		if len(rule) == 0 {
+			errs = append(errs, errors.New("functional_test_v2.exclude has an empty rule"))
+		}
+		for key, value := range rule {
+			values, ok := allowed[key]
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Errorf("functional_test_v2.exclude rule %v uses the unknown key %s", rule, key))
+			case !slices.Contains(values, value):
+				errs = append(errs, fmt.Errorf("functional_test_v2.exclude rule %v excludes %s, which functional_test_v2.%s does not list", rule, value, key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readKindTags reads a list of kindest/node tags, one per line. Blank lines and lines starting
+// with # are ignored.
+func readKindTags(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	tags := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			tags = append(tags, line)
+		}
+	}
+	return tags, scanner.Err()
+}
+
+// matrixChangeSummary returns the versions added to and dropped from every version list of the
+// matrix as markdown, for the body of the pull request updating it. It avoids code spans as the
+// body is passed to the shell in double quotes.
+func matrixChangeSummary(before, after ciMatrix) string {
+	beforeLists := map[string][]string{}
+	for _, list := range before.lists() {
+		beforeLists[list.suite+"."+list.key] = list.values
+	}
+	var rows []string
+	for _, list := range after.lists() {
+		if list.order == unordered {
+			continue
+		}
+		old := beforeLists[list.suite+"."+list.key]
+		added, dropped := difference(list.values, old), difference(old, list.values)
+		if len(added) == 0 && len(dropped) == 0 {
+			continue
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s |", list.suite, list.key, joinOrNone(added), joinOrNone(dropped)))
+	}
+	for _, list := range before.lists() {
+		if list.order != unordered && list.suite == managedVersionsKey && after.ManagedVersions[list.key] == nil {
+			rows = append(rows, fmt.Sprintf("| %s | %s | - | %s |", list.suite, list.key, joinOrNone(list.values)))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("## Kubernetes version changes\n\n")
+	if len(rows) == 0 {
+		b.WriteString("No versions were added or dropped.\n")
+		return b.String()
+	}
+	b.WriteString("| Suite | Key | Added | Dropped |\n|---|---|---|---|\n")
+	for _, row := range rows {
+		b.WriteString(row + "\n")
+	}
+	if removed := len(before.FunctionalTestV2.Exclude) - len(after.FunctionalTestV2.Exclude); removed > 0 {
+		fmt.Fprintf(&b, "\nRemoved %d functional_test_v2 exclude rules of dropped kind versions.\n", removed)
+	}
+	return b.String()
+}
+
+// difference returns the values of a that are not in b, in the order of a.
+func difference(a, b []string) []string {
+	var diff []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+// compareVersionStrings compares dotted versions numerically, ignoring a v prefix, e.g. v1.9.0
+// is lower than v1.10.0.
+func compareVersionStrings(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		if aErr != nil || bErr != nil {
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
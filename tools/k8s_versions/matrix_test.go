@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CIMatrix_ReadWrite_KeepsTheFileAsIs(t *testing.T) {
+	path := copyMatrix(t)
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, writeCIMatrix(path, readMatrix(t, path)))
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func Test_ReadCIMatrix_UnknownKey_ReturnsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci-matrix.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"functional_test_v3": {}}`), 0o600))
+	_, err := readCIMatrix(path, true)
+	require.ErrorContains(t, err, `unknown field "functional_test_v3"`)
+}
+
+func Test_Validate_InvalidMatrix_ReturnsErrors(t *testing.T) {
+	suitesDir := t.TempDir()
+	for _, suite := range []string{"functional", "istio"} {
+		require.NoError(t, os.Mkdir(filepath.Join(suitesDir, suite), 0o755))
+	}
+	tests := []struct {
+		name     string
+		modify   func(m *ciMatrix)
+		checks   matrixChecks
+		expected []string
+	}{
+		{"valid", func(*ciMatrix) {}, matrixChecks{suitesDir: suitesDir, kindTags: []string{"v1.35.5", "v1.34.8"}}, nil},
+		{"duplicate version", func(m *ciMatrix) { m.FunctionalTest.SplunkVersions = []string{"9.4.4", "9.4.4"} }, matrixChecks{},
+			[]string{"functional_test.splunk_version lists 9.4.4 more than once"}},
+		{"unsorted descending", func(m *ciMatrix) { m.MigrationTests.KindVersions = []string{"v1.9.0", "v1.10.0"} }, matrixChecks{},
+			[]string{"migration_tests.k8s-kind-version is not sorted: v1.9.0 is listed before v1.10.0"}},
+		{"unsorted ascending", func(m *ciMatrix) { m.KubeconformTests.K8sVersions = []string{"1.33.1", "1.32.5"} }, matrixChecks{},
+			[]string{"kubeconform_tests.k8s-version is not sorted: 1.33.1 is listed before 1.32.5"}},
+		{"unsorted managed", func(m *ciMatrix) { m.ManagedVersions = map[string][]string{"eks": {"1.33", "1.34"}} }, matrixChecks{},
+			[]string{"managed_k8s_versions.eks is not sorted: 1.33 is listed before 1.34"}},
+		{"empty list", func(m *ciMatrix) { m.FunctionalTest.MinikubeVersions = nil }, matrixChecks{},
+			[]string{"functional_test.k8s-minikube-version is empty"}},
+		{"unknown suite", func(m *ciMatrix) { m.FunctionalTestV2.TestJobs = []string{"functional", "logs"} }, matrixChecks{suitesDir: suitesDir},
+			[]string{"functional_test_v2.test-job logs has no test suite in " + suitesDir}},
+		{"unknown kind tag", func(*ciMatrix) {}, matrixChecks{kindTags: []string{"v1.35.5"}}, []string{
+			"functional_test_v2.k8s-kind-version v1.34.8 is not a kindest/node tag",
+			"migration_tests.k8s-kind-version v1.34.8 is not a kindest/node tag",
+		}},
+		{"exclude rule", func(m *ciMatrix) {
+			m.FunctionalTestV2.Exclude = []map[string]string{{testJob: "istio", kubeKindVersion: "v1.33.11"}, {"os": "windows"}}
+		}, matrixChecks{}, []string{
+			"functional_test_v2.exclude rule map[k8s-kind-version:v1.33.11 test-job:istio] excludes v1.33.11, which functional_test_v2.k8s-kind-version does not list",
+			"functional_test_v2.exclude rule map[os:windows] uses the unknown key os",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMatrix()
+			tt.modify(&m)
+			err := m.validate(tt.checks)
+			if tt.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.ElementsMatch(t, tt.expected, strings.Split(err.Error(), "\n"))
+		})
+	}
+}
+
+func Test_Validate_RepositoryMatrix_IsValid(t *testing.T) {
+	m := readMatrix(t, filepath.Join("..", "..", ciMatrixPath))
+	require.NoError(t, m.validate(matrixChecks{suitesDir: filepath.Join("..", "..", "functional_tests")}))
+}
+
+func Test_WithVersions_DroppedKindVersion_RemovesExcludeRules(t *testing.T) {
+	m := testMatrix()
+	m.FunctionalTestV2.Exclude = []map[string]string{{testJob: "istio", kubeKindVersion: "v1.34.8"}, {testJob: "functional"}}
+
+	updated := m.withVersions([]string{"v1.36.1", "v1.35.5"}, nil, nil)
+	assert.Equal(t, []map[string]string{{testJob: "functional"}}, updated.FunctionalTestV2.Exclude)
+	assert.Equal(t, []string{"v1.36.1", "v1.35.5"}, updated.MigrationTests.KindVersions)
+	assert.Equal(t, []string{"v1.35.6", "v1.34.9"}, updated.FunctionalTest.MinikubeVersions)
+	assert.Len(t, m.FunctionalTestV2.Exclude, 2)
+}
+
+func Test_MatrixChangeSummary_Changes_ReturnsMarkdown(t *testing.T) {
+	before := testMatrix()
+	before.FunctionalTestV2.Exclude = []map[string]string{{testJob: "istio", kubeKindVersion: "v1.34.8"}}
+	after := before.withVersions([]string{"v1.36.1", "v1.35.5"}, nil, map[string][]string{"gke": {"1.36", "1.35"}})
+
+	assert.Equal(t, `## Kubernetes version changes
+
+| Suite | Key | Added | Dropped |
+|---|---|---|---|
+| functional_test_v2 | k8s-kind-version | v1.36.1 | v1.34.8 |
+| managed_k8s_versions | gke | 1.36 | 1.34 |
+| migration_tests | k8s-kind-version | v1.36.1 | v1.34.8 |
+| managed_k8s_versions | eks | - | 1.35, 1.34 |
+
+Removed 1 functional_test_v2 exclude rules of dropped kind versions.
+`, matrixChangeSummary(before, after))
+	assert.Equal(t, "## Kubernetes version changes\n\nNo versions were added or dropped.\n", matrixChangeSummary(before, before))
+}
+
+func Test_Run_DryRun_PrintsSummaryWithoutWriting(t *testing.T) {
+	matrixPath := copyMatrix(t)
+	before, err := os.ReadFile(matrixPath)
+	require.NoError(t, err)
+	cfg := defaultConfig().withFixtures(filepath.Join("testdata", "fixtures"))
+	cfg.now = func() time.Time { return time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC) }
+
+	var out strings.Builder
+	require.NoError(t, run(cfg, options{matrixPath: matrixPath, dryRun: true, out: &out}))
+	assert.Contains(t, out.String(), "| functional_test | k8s-minikube-version | v1.36.2 | v1.33.12 |\n")
+	assert.Contains(t, out.String(), "| functional_test_v2 | k8s-kind-version | v1.36.1 | v1.33.11 |\n")
+	assert.Contains(t, out.String(), "| managed_k8s_versions | aks | 1.36, 1.35, 1.34, 1.33 | - |\n")
+	assert.NotContains(t, out.String(), "`")
+	after, err := os.ReadFile(matrixPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func Test_Run_InvalidUpdatedMatrix_ReturnsError(t *testing.T) {
+	cfg := defaultConfig().withFixtures(filepath.Join("testdata", "fixtures"))
+	cfg.now = func() time.Time { return time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC) }
+
+	err := run(cfg, options{matrixPath: copyMatrix(t), checks: matrixChecks{kindTags: []string{"v1.35.5", "v1.34.8"}}})
+	require.ErrorContains(t, err, "functional_test_v2.k8s-kind-version v1.36.1 is not a kindest/node tag")
+}
+
+func Test_ReadKindTags_SkipsCommentsAndBlankLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kind-tags.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# kindest/node\nv1.36.1\n\n v1.35.5 \n"), 0o600))
+	tags, err := readKindTags(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.36.1", "v1.35.5"}, tags)
+}
+
+func testMatrix() ciMatrix {
+	return ciMatrix{
+		FunctionalTest: minikubeSuite{
+			ContainerRuntime: []string{"docker", "containerd"},
+			MinikubeVersions: []string{"v1.35.6", "v1.34.9"},
+			SplunkVersions:   []string{"9.4.4", "8.2.12"},
+		},
+		FunctionalTestV2: kindSuite{
+			KindVersions: []string{"v1.35.5", "v1.34.8"},
+			TestJobs:     []string{"functional", "istio"},
+			Exclude:      []map[string]string{},
+		},
+		KubeconformTests: kubeconformSuite{K8sVersions: []string{"1.32.5", "1.33.1"}},
+		ManagedVersions:  map[string][]string{"eks": {"1.35", "1.34"}, "gke": {"1.35", "1.34"}},
+		MigrationTests:   migrationSuite{KindVersions: []string{"v1.35.5", "v1.34.8"}},
+	}
+}
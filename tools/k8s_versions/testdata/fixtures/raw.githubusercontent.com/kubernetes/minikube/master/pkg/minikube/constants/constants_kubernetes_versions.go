@@ -0,0 +1,23 @@
+/*
+Copyright 2025 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+*/
+
+package constants
+
+// ValidKubernetesVersions is a list of Kubernetes versions in order from newest to oldest
+// This is used when outputting Kubernetes versions and to select the latest patch version when unspecified
+var ValidKubernetesVersions = []string{
+	"v1.37.0-alpha.1",
+	"v1.36.2",
+	"v1.36.1",
+	"v1.36.0",
+	"v1.35.6",
+	"v1.35.5",
+	"v1.34.9",
+	"v1.34.8",
+	"v1.33.12",
+	"v1.33.11",
+}
@@ -44,9 +44,9 @@ type DockerImage struct {
 }
 
 // getSupportedKubernetesVersions returns the supported Kubernetes versions
-// by checking the EOL date of the collected versions.
-func getSupportedKubernetesVersions(url string) ([]KubernetesVersion, error) {
-	body, err := getRequestBody(url)
+// by checking the EOL date of the collected versions against the configured clock.
+func getSupportedKubernetesVersions(cfg config) ([]KubernetesVersion, error) {
+	body, err := getRequestBody(cfg.client, cfg.endOfLifeURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get k8s versions: %w", err)
 	}
@@ -55,7 +55,7 @@ func getSupportedKubernetesVersions(url string) ([]KubernetesVersion, error) {
 		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
 
-	now := time.Now()
+	now := cfg.now()
 	for _, kubernetesVersion := range kubernetesVersions {
 		eolDate, parseErr := time.Parse(time.DateOnly, kubernetesVersion.EOLDate)
 		if parseErr != nil {
@@ -72,8 +72,8 @@ func getSupportedKubernetesVersions(url string) ([]KubernetesVersion, error) {
 
 // getLatestSupportedMinikubeVersions iterates through the K8s supported versions and find the latest minikube after parsing
 // the sorted ValidKubernetesVersions slice from constants_kubernetes_versions.go
-func getLatestSupportedMinikubeVersions(url string, k8sVersions []KubernetesVersion) ([]string, error) {
-	body, err := getRequestBody(url)
+func getLatestSupportedMinikubeVersions(cfg config, k8sVersions []KubernetesVersion) ([]string, error) {
+	body, err := getRequestBody(cfg.client, cfg.minikubeURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get minikube versions: %w", err)
 	}
@@ -106,12 +106,12 @@ func getLatestSupportedMinikubeVersions(url string, k8sVersions []KubernetesVers
 
 // getLatestSupportedKindImages iterates through the K8s supported versions and find the latest kind
 // tag that supports that version
-func getLatestSupportedKindImages(url string, k8sVersions []KubernetesVersion) ([]string, error) {
+func getLatestSupportedKindImages(cfg config, k8sVersions []KubernetesVersion) ([]string, error) {
 	var supportedKindVersions []string
 	for _, k8sVersion := range k8sVersions {
 		tag := k8sVersion.Latest
 		for {
-			exists, err := imageTagExists(url, tag)
+			exists, err := imageTagExists(cfg, tag)
 			if err != nil {
 				return supportedKindVersions, fmt.Errorf("failed to check image tag existence: %w", err)
 			}
@@ -134,8 +134,8 @@ func getLatestSupportedKindImages(url string, k8sVersions []KubernetesVersion) (
 	return supportedKindVersions, nil
 }
 
-func imageTagExists(url string, tag string) (bool, error) {
-	body, err := getRequestBody(url + tag)
+func imageTagExists(cfg config, tag string) (bool, error) {
+	body, err := getRequestBody(cfg.client, cfg.kindTagsURL+tag)
 	if err != nil {
 		return false, fmt.Errorf("failed to get image tag: %w", err)
 	}
@@ -170,39 +170,6 @@ func decrementPatchVersion(version string) (string, error) {
 	return strings.Join(parts, "."), nil
 }
 
-func updateMatrixFile(filePath string, kindVersions []string, minikubeVersions []string) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	var testMatrix map[string]map[string][]string
-	if err = json.Unmarshal(content, &testMatrix); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %w", err)
-	}
-
-	for _, value := range testMatrix {
-		if len(kindVersions) > 0 && value[kubeKindVersion] != nil {
-			value[kubeKindVersion] = kindVersions
-		} else if len(minikubeVersions) > 0 && value[kubeMinikubeVersion] != nil {
-			value[kubeMinikubeVersion] = minikubeVersions
-		}
-	}
-	// Marshal the updated test matrix back to JSON
-	updatedContent, err := json.MarshalIndent(testMatrix, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated JSON: %w", err)
-	}
-
-	// Ensure the file ends with a new line to make the pre-commit check happy
-	updatedContent = append(updatedContent, '\n')
-
-	if err = os.WriteFile(filePath, updatedContent, 0o644); err != nil { //nolint:gosec
-		return fmt.Errorf("failed to write updated file: %w", err)
-	}
-	return nil
-}
-
 func sortVersions(versions []string) {
 	sort.Slice(versions, func(i, j int) bool {
 		vi := strings.Split(versions[i][1:], ".") // Remove "v" and split by "."
@@ -217,13 +184,13 @@ func sortVersions(versions []string) {
 	})
 }
 
-func getRequestBody(uRL string) ([]byte, error) {
+func getRequestBody(client *http.Client, uRL string) ([]byte, error) {
 	u, err := url.Parse(uRL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	resp, err := http.Get(u.String())
+	resp, err := client.Get(u.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch URL: %w", err)
 	}
@@ -246,49 +213,196 @@ func logDebug(format string, v ...any) {
 	}
 }
 
-func main() {
-	// setup logging
-	flag.BoolVar(&debug, "debug", false, "Enable debug logging")
-	flag.Parse()
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+// options are the repository files run updates and checks. The chart, README and UPGRADING.md
+// checks are skipped when their path is empty.
+type options struct {
+	matrixPath    string
+	chartPath     string
+	readmePath    string
+	upgradingPath string
+	// updateChart rewrites the kubeVersion of the chart instead of only warning about it.
+	updateChart bool
+	// checks are what the updated matrix is validated against before it is written.
+	checks matrixChecks
+	// dryRun prints the version changes to out as markdown instead of writing the matrix and the
+	// chart.
+	dryRun bool
+	out    io.Writer
+}
 
-	k8sVersions, err := getSupportedKubernetesVersions(endOfLifeURL)
-	if err != nil || len(k8sVersions) == 0 {
-		log.Fatalf("Failed to get k8s versions: %v", err)
+// run updates the matrix file with the kind and minikube versions of the supported Kubernetes
+// versions, and with the versions the managed Kubernetes services support. The updated matrix
+// is validated before it is written. It then checks the chart and its documentation claim
+// support for exactly these versions.
+func run(cfg config, opts options) error {
+	k8sVersions, err := getSupportedKubernetesVersions(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to get k8s versions: %w", err)
+	}
+	if len(k8sVersions) == 0 {
+		return errors.New("no supported k8s versions found")
 	}
 	logDebug("Supported Kubernetes versions %v", k8sVersions)
 
-	kindVersions, err := getLatestSupportedKindImages(kindDockerHubURL, k8sVersions)
+	kindVersions, err := getLatestSupportedKindImages(cfg, k8sVersions)
 	if err != nil {
-		log.Fatalf("Failed to get kind versions: %v", err)
+		return fmt.Errorf("failed to get kind versions: %w", err)
 	}
 	if len(kindVersions) == 0 {
-		log.Fatalf("No kind versions found")
+		return errors.New("no kind versions found")
 	}
 
 	// needs to be sorted so we don't end up with false positive diff in the json matrix file
 	sortVersions(kindVersions)
 	logDebug("Found supported kind images: %v", kindVersions)
 
-	minikubeVersions, err := getLatestSupportedMinikubeVersions(miniKubeURL, k8sVersions)
+	minikubeVersions, err := getLatestSupportedMinikubeVersions(cfg, k8sVersions)
 	if err != nil {
-		log.Fatalf("failed to get minikube versions: %v", err)
+		return fmt.Errorf("failed to get minikube versions: %w", err)
 	}
 	if len(minikubeVersions) == 0 {
-		log.Fatalf("No minikube versions found")
+		return errors.New("no minikube versions found")
 	}
-
 	logDebug("Found supported minikube versions: %v", minikubeVersions)
 
-	currentDir, err := os.Getwd()
+	managedVersions, err := getManagedKubernetesVersions(cfg)
 	if err != nil {
-		log.Fatalf("Failed to get current directory: %v ", err)
+		return fmt.Errorf("failed to get managed k8s versions: %w", err)
 	}
-	path := filepath.Join(currentDir, filepath.Clean(ciMatrixPath))
-	err = updateMatrixFile(path, kindVersions, minikubeVersions)
+	logDebug("Found supported managed Kubernetes versions: %v", managedVersions)
+
+	matrix, err := readCIMatrix(opts.matrixPath, true)
 	if err != nil {
-		log.Fatalf("Failed to update matrix file: %v", err)
+		return fmt.Errorf("failed to read matrix file: %w", err)
+	}
+	updated := matrix.withVersions(kindVersions, minikubeVersions, managedVersions)
+	if err = updated.validate(opts.checks); err != nil {
+		return fmt.Errorf("invalid updated matrix file: %w", err)
+	}
+	if opts.dryRun {
+		if _, err = io.WriteString(opts.out, matrixChangeSummary(matrix, updated)); err != nil {
+			return fmt.Errorf("failed to write the summary: %w", err)
+		}
+	} else if err = writeCIMatrix(opts.matrixPath, updated); err != nil {
+		return fmt.Errorf("failed to update matrix file: %w", err)
+	}
+
+	minors := supportedMinors(k8sVersions, managedVersions)
+	if opts.chartPath != "" {
+		if err = syncChartKubeVersion(opts.chartPath, minors, opts.updateChart && !opts.dryRun); err != nil {
+			return fmt.Errorf("failed to check chart kubeVersion: %w", err)
+		}
+	}
+	// The README has to state the supported versions, the upgrade notes only when they change.
+	docs := []struct {
+		path             string
+		extract          func(string) string
+		requireStatement bool
+	}{
+		{opts.readmePath, readmeSupportText, true},
+		{opts.upgradingPath, upgradingNotesText, false},
+	}
+	for _, doc := range docs {
+		if doc.path == "" {
+			continue
+		}
+		warnings, err := checkSupportDocs(doc.path, doc.extract, doc.requireStatement, minors)
+		if err != nil {
+			return fmt.Errorf("failed to check support docs: %w", err)
+		}
+		logWarnings(warnings)
+	}
+	return nil
+}
+
+// syncChartKubeVersion rewrites the kubeVersion of the chart to the supported range when update
+// is set, and warns about the versions it does not admit or wrongly admits otherwise.
+func syncChartKubeVersion(path string, minors []string, update bool) error {
+	if update && len(minors) > 0 {
+		constraint := kubeVersionRange(minors)
+		logDebug("Setting the chart kubeVersion to %s", constraint)
+		return updateChartKubeVersion(path, constraint)
+	}
+	constraint, err := readChartKubeVersion(path)
+	if err != nil {
+		return err
+	}
+	warnings, err := checkChartKubeVersion(constraint, minors)
+	if err != nil {
+		return err
+	}
+	logWarnings(warnings)
+	return nil
+}
+
+func logWarnings(warnings []string) {
+	for _, warning := range warnings {
+		log.Printf("WARNING: %s", warning)
+	}
+}
+
+func main() {
+	var fixturesDir, recordDir, date, checkEnv, checkVersion, kindTagsPath string
+	var opts options
+	// setup logging
+	flag.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flag.StringVar(&fixturesDir, "fixtures", "", "Read the recorded responses of this directory instead of fetching them")
+	flag.StringVar(&recordDir, "record", "", "Record the fetched responses into this directory, for -fixtures")
+	flag.StringVar(&date, "date", "", "Compare EOL dates to this date (YYYY-MM-DD) instead of today")
+	flag.StringVar(&opts.matrixPath, "matrix", ciMatrixPath, "Path of the matrix file to update")
+	flag.StringVar(&opts.chartPath, "chart", chartPath, "Path of the Chart.yaml whose kubeVersion is checked, empty to skip")
+	flag.StringVar(&opts.readmePath, "readme", readmePath, "Path of the README whose supported versions are checked, empty to skip")
+	flag.StringVar(&opts.upgradingPath, "upgrading", upgradingPath, "Path of the UPGRADING.md whose latest notes are checked for supported versions, empty to skip")
+	flag.BoolVar(&opts.updateChart, "update-chart", false, "Rewrite the kubeVersion of the chart to the supported range")
+	flag.StringVar(&opts.checks.suitesDir, "functional-tests", "functional_tests", "Directory of the functional test suites the test jobs must exist in, empty to skip")
+	flag.StringVar(&kindTagsPath, "kind-tags", "", "File listing the kindest/node tags, one per line, the kind versions must be in")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "Print the added and dropped versions as markdown instead of writing the matrix file and the chart")
+	flag.StringVar(&checkEnv, "check-env", "", "Instead of updating the matrix file, check the Kubernetes version of this cloud test environment (KUBE_TEST_ENV) is supported")
+	flag.StringVar(&checkVersion, "check-version", "", "Kubernetes version of the cluster checked with -check-env, e.g. v1.33.4-eks-1234")
+	flag.Parse()
+	log.SetOutput(os.Stdout)
+	if opts.dryRun {
+		// A dry run prints the summary to stdout, keep it free of logs.
+		log.SetOutput(os.Stderr)
+	}
+	opts.out = os.Stdout
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if checkEnv != "" {
+		if err := checkManagedVersion(filepath.Clean(opts.matrixPath), checkEnv, checkVersion); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg := defaultConfig()
+	if fixturesDir != "" && recordDir != "" {
+		log.Fatalf("-fixtures and -record are mutually exclusive")
+	}
+	if fixturesDir != "" {
+		cfg = cfg.withFixtures(fixturesDir)
+	}
+	if recordDir != "" {
+		cfg = cfg.withRecording(recordDir)
+	}
+	if date != "" {
+		day, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			log.Fatalf("Invalid -date: %v", err)
+		}
+		cfg.now = func() time.Time { return day }
+	}
+
+	if kindTagsPath != "" {
+		tags, err := readKindTags(kindTagsPath)
+		if err != nil {
+			log.Fatalf("Invalid -kind-tags: %v", err)
+		}
+		opts.checks.kindTags = tags
+	}
+
+	opts.matrixPath = filepath.Clean(opts.matrixPath)
+	if err := run(cfg, opts); err != nil {
+		log.Fatal(err)
 	}
-	os.Exit(0)
 }
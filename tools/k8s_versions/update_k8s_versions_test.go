@@ -3,7 +3,12 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +30,13 @@ func (mResponse mockResponse) setupMockServer() *httptest.Server {
 	}))
 }
 
+// mockConfig points every endpoint at url.
+func mockConfig(url string) config {
+	cfg := defaultConfig()
+	cfg.endOfLifeURL, cfg.kindTagsURL, cfg.minikubeURL = url, url, url
+	return cfg
+}
+
 func Test_FetchKubernetesVersions_ValidURL_ReturnsSupportedVersions(t *testing.T) {
 	mResponse := mockResponse{
 		responseBody: []byte(`	[{"cycle":"1.24","releaseDate":"2022-05-03","eol":"2023-10-03","latest":"1.24.8"},
@@ -36,14 +48,14 @@ func Test_FetchKubernetesVersions_ValidURL_ReturnsSupportedVersions(t *testing.T
 	mockServer := mResponse.setupMockServer()
 	defer mockServer.Close()
 
-	versions, err := getSupportedKubernetesVersions(mockServer.URL)
+	versions, err := getSupportedKubernetesVersions(mockConfig(mockServer.URL))
 	require.NoError(t, err)
 	require.Len(t, versions, 1)
 	assert.Equal(t, "1.34", versions[0].Cycle)
 }
 
 func Test_FetchKubernetesVersions_InvalidURL_ReturnsError(t *testing.T) {
-	_, err := getSupportedKubernetesVersions("http:/12.168.1.2:2025/invalid")
+	_, err := getSupportedKubernetesVersions(mockConfig("http:/12.168.1.2:2025/invalid"))
 	assert.Error(t, err)
 }
 
@@ -57,7 +69,7 @@ func Test_GetSupportedKubernetesVersions_EmptyResponse_ReturnsNoVersions(t *test
 	mockServer := mResponse.setupMockServer()
 	defer mockServer.Close()
 
-	versions, err := getSupportedKubernetesVersions(mockServer.URL)
+	versions, err := getSupportedKubernetesVersions(mockConfig(mockServer.URL))
 	require.NoError(t, err)
 	assert.Empty(t, versions)
 }
@@ -72,7 +84,7 @@ func Test_GetSupportedKubernetesVersions_InvalidEOL_ReturnsError(t *testing.T) {
 	mockServer := mResponse.setupMockServer()
 	defer mockServer.Close()
 
-	versions, err := getSupportedKubernetesVersions(mockServer.URL)
+	versions, err := getSupportedKubernetesVersions(mockConfig(mockServer.URL))
 	require.Error(t, err)
 	assert.Nil(t, versions)
 }
@@ -93,7 +105,7 @@ func Test_GetLatestSupportedMinikubeVersions_ValidResponse_ReturnsMatchingVersio
 		{Cycle: "1.25"},
 	}
 
-	versions, err := getLatestSupportedMinikubeVersions(mockServer.URL, k8sVersions)
+	versions, err := getLatestSupportedMinikubeVersions(mockConfig(mockServer.URL), k8sVersions)
 	require.NoError(t, err)
 	assert.Equal(t, []string{"v1.24.3-alpha1", "v1.23.5"}, versions)
 }
@@ -112,7 +124,7 @@ func Test_GetLatestSupportedMinikubeVersions_InvalidResponseFormat_ReturnsError(
 		{Cycle: "1.24"},
 	}
 
-	versions, err := getLatestSupportedMinikubeVersions(mockServer.URL, k8sVersions)
+	versions, err := getLatestSupportedMinikubeVersions(mockConfig(mockServer.URL), k8sVersions)
 	assert.Error(t, err)
 	assert.Nil(t, versions)
 }
@@ -138,7 +150,103 @@ func Test_GetLatestSupportedKindImages_ValidResponse_ReturnsMatchingImages(t *te
 		{Cycle: "1.23", Latest: "1.23.5"},
 	}
 
-	images, err := getLatestSupportedKindImages(mockServer.URL+"/image&name=", k8sVersions)
+	images, err := getLatestSupportedKindImages(mockConfig(mockServer.URL+"/image&name="), k8sVersions)
 	require.NoError(t, err)
 	assert.Equal(t, []string{"v1.24.2"}, images)
 }
+
+func Test_Run_Fixtures_UpdatesMatrixForDate(t *testing.T) {
+	tests := []struct {
+		date     string
+		kind     []string
+		minikube []string
+		managed  map[string][]string
+	}{
+		{"2026-06-01", []string{"v1.36.1", "v1.35.5", "v1.34.8", "v1.33.11"}, []string{"v1.36.2", "v1.35.6", "v1.34.9", "v1.33.12"}, map[string][]string{
+			"aks": {"1.36", "1.35", "1.34", "1.33", "1.30"},
+			"eks": {"1.36", "1.35", "1.34", "1.33", "1.32"},
+			"gke": {"1.36", "1.35", "1.34", "1.33"},
+		}},
+		{"2026-10-19", []string{"v1.36.1", "v1.35.5", "v1.34.8"}, []string{"v1.36.2", "v1.35.6", "v1.34.9"}, map[string][]string{
+			"aks": {"1.36", "1.35", "1.34", "1.33"},
+			"eks": {"1.36", "1.35", "1.34", "1.33"},
+			"gke": {"1.36", "1.35", "1.34", "1.33"},
+		}},
+		{"2027-01-01", []string{"v1.36.1", "v1.35.5"}, []string{"v1.36.2", "v1.35.6"}, map[string][]string{
+			"aks": {"1.36", "1.35", "1.33"},
+			"eks": {"1.36", "1.35", "1.34", "1.33"},
+			"gke": {"1.36", "1.35", "1.34", "1.33"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			matrixPath := copyMatrix(t)
+			cfg := defaultConfig().withFixtures(filepath.Join("testdata", "fixtures"))
+			day, err := time.Parse(time.DateOnly, tt.date)
+			require.NoError(t, err)
+			cfg.now = func() time.Time { return day }
+
+			require.NoError(t, run(cfg, options{matrixPath: matrixPath}))
+			matrix := readMatrix(t, matrixPath)
+			assert.Equal(t, tt.kind, matrix.FunctionalTestV2.KindVersions)
+			assert.Equal(t, tt.kind, matrix.MigrationTests.KindVersions)
+			assert.Equal(t, tt.minikube, matrix.FunctionalTest.MinikubeVersions)
+			assert.Equal(t, []string{"9.4.4", "8.2.12"}, matrix.FunctionalTest.SplunkVersions)
+			assert.Equal(t, tt.managed, matrix.ManagedVersions)
+		})
+	}
+}
+
+func Test_Run_MissingFixture_ReturnsError(t *testing.T) {
+	cfg := defaultConfig().withFixtures(t.TempDir())
+	err := run(cfg, options{matrixPath: copyMatrix(t)})
+	require.ErrorContains(t, err, "no fixture")
+	require.ErrorContains(t, err, filepath.Join("endoflife.date", "api", "kubernetes.json"))
+}
+
+func Test_Run_Record_ReplaysWithFixtures(t *testing.T) {
+	fixtures := filepath.Join("testdata", "fixtures")
+	// The stand-in serves the recorded fixtures over HTTP, as the real endpoints would.
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		body, err := os.ReadFile(fixturePath(fixtures, &url.URL{Host: host, Path: "/" + path, RawQuery: r.URL.RawQuery}))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer mockServer.Close()
+
+	recorded := t.TempDir()
+	cfg := defaultConfig()
+	cfg.endOfLifeURL = mockServer.URL + "/endoflife.date/api/kubernetes.json"
+	cfg.minikubeURL = mockServer.URL + "/raw.githubusercontent.com/kubernetes/minikube/master/pkg/minikube/constants/constants_kubernetes_versions.go"
+	cfg.kindTagsURL = mockServer.URL + "/hub.docker.com/v2/repositories/kindest/node/tags?page_size=1&name="
+	cfg.versionSources = []versionSource{endOfLifeSource{name: "eks", url: mockServer.URL + "/endoflife.date/api/amazon-eks.json"}}
+	cfg.now = func() time.Time { return time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC) }
+	liveMatrix := copyMatrix(t)
+	require.NoError(t, run(cfg.withRecording(recorded), options{matrixPath: liveMatrix}))
+
+	replayed := defaultConfig().withFixtures(filepath.Join(recorded, strings.TrimPrefix(mockServer.URL, "http://")))
+	replayed.now = cfg.now
+	replayed.versionSources = []versionSource{endOfLifeSource{name: "eks", url: endOfLifeAPIURL + "amazon-eks.json"}}
+	replayMatrix := copyMatrix(t)
+	require.NoError(t, run(replayed, options{matrixPath: replayMatrix}))
+	assert.Equal(t, readMatrix(t, liveMatrix), readMatrix(t, replayMatrix))
+	assert.Equal(t, []string{"1.36", "1.35", "1.34", "1.33"}, readMatrix(t, replayMatrix).ManagedVersions["eks"])
+}
+
+func copyMatrix(t *testing.T) string {
+	content, err := os.ReadFile(filepath.Join("testdata", "ci-matrix.json"))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "ci-matrix.json")
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	return path
+}
+
+func readMatrix(t *testing.T, path string) ciMatrix {
+	matrix, err := readCIMatrix(path, true)
+	require.NoError(t, err)
+	return matrix
+}